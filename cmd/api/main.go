@@ -8,13 +8,17 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/bilalabdelkadir/chis/internal/blobstore"
 	"github.com/bilalabdelkadir/chis/internal/config"
 	"github.com/bilalabdelkadir/chis/internal/database"
 	"github.com/bilalabdelkadir/chis/internal/email"
+	"github.com/bilalabdelkadir/chis/internal/export"
 	"github.com/bilalabdelkadir/chis/internal/handler"
 	"github.com/bilalabdelkadir/chis/internal/logger"
+	"github.com/bilalabdelkadir/chis/internal/logstream"
 	"github.com/bilalabdelkadir/chis/internal/middleware"
 	"github.com/bilalabdelkadir/chis/internal/queue"
+	"github.com/bilalabdelkadir/chis/internal/relay"
 	"github.com/bilalabdelkadir/chis/internal/repository"
 	"github.com/bilalabdelkadir/chis/internal/router"
 	pb "github.com/bilalabdelkadir/chis/proto/delivery"
@@ -23,6 +27,8 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 )
 
+var QueueName = "main"
+
 func healthHandler(w http.ResponseWriter, r *http.Request) error {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
@@ -66,8 +72,24 @@ func main() {
 	apiKeyRepo := repository.NewApiKeyRepository(pool)
 	messageRepo := repository.NewMessageRepository(pool)
 	deliveryAttemptRepo := repository.NewDeliveryAttemptsRepository(pool)
+	endpointRepo := repository.NewEndpointRepository(pool)
+	signingKeyRepo := repository.NewSigningKeyRepository(pool)
+	notificationRepo := repository.NewNotificationRepository(pool)
+	planRepo := repository.NewPlanRepository(pool)
+	usageRepo := repository.NewUsageRepository(pool)
+	analyticsRepo := repository.NewAnalyticsRepository(pool)
+	exportRepo := repository.NewExportRepository(pool)
+
+	blobs, err := blobstore.New(cfg)
+	if err != nil {
+		slog.Error("failed to set up blob store", "error", err)
+		os.Exit(1)
+	}
 
-	conn, err := grpc.NewClient(cfg.GrpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(cfg.GrpcAddr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(cfg.MaxPayloadBytes+64<<10)),
+	)
 	if err != nil {
 		slog.Error("failed to connect to delivery service", "error", err)
 		os.Exit(1)
@@ -85,11 +107,23 @@ func main() {
 
 	// Handlers
 	authHandler := handler.NewAuthHandler(userRepo, accountRepo, orgRepo, membershipRepo, cfg.JwtSecret)
-	apiKeyHandler := handler.NewApiKeyHandler(apiKeyRepo)
-	webhookHandler := handler.NewWebhookHandler(deliveryClient)
-	dashboardHandler := handler.NewDashboardHandler(messageRepo, deliveryAttemptRepo)
-	orgHandler := handler.NewOrganizationHandler(orgRepo, membershipRepo)
+	apiKeyHandler := handler.NewApiKeyHandler(apiKeyRepo, planRepo)
+	webhookHandler := handler.NewWebhookHandler(deliveryClient, orgRepo, messageRepo, planRepo, usageRepo, cfg.MaxPayloadBytes)
+	dashboardHandler := handler.NewDashboardHandler(messageRepo, deliveryAttemptRepo, analyticsRepo)
+	orgHandler := handler.NewOrganizationHandler(orgRepo, membershipRepo, cfg.SigningSecretGracePeriod,
+		queue.NewQueue(rdb, QueueName, cfg.QueueFairQuantum, cfg.QueueMaxWait))
 	invitationHandler := handler.NewInvitationHandler(invitationRepo, membershipRepo, userRepo, emailService)
+	logStream := logstream.New(rdb)
+	endpointHandler := handler.NewEndpointHandler(endpointRepo, orgRepo, messageRepo, planRepo, membershipRepo, logStream, cfg.SigningSecretGracePeriod,
+		cfg.DeliveryTimeoutMin, cfg.DeliveryTimeoutMax)
+	signingKeyHandler := handler.NewSigningKeyHandler(orgRepo, signingKeyRepo)
+	messageHandler := handler.NewMessageHandler(messageRepo, deliveryAttemptRepo, planRepo, usageRepo, logStream)
+	listenHandler := handler.NewListenHandler(endpointRepo, relay.New(rdb))
+	notificationHandler := handler.NewNotificationHandler(notificationRepo)
+	usageHandler := handler.NewUsageHandler(planRepo, usageRepo, orgRepo)
+	reportHandler := handler.NewReportHandler(analyticsRepo)
+	exportHandler := handler.NewExportHandler(export.NewExporter(exportRepo, orgRepo, blobs), exportRepo, orgRepo, blobs)
+	logStreamHandler := handler.NewLogStreamHandler(logStream)
 
 	// Router
 	r := router.NewRouter()
@@ -104,7 +138,7 @@ func main() {
 		http.ListenAndServe(":9090", mux)
 	}()
 
-	router.Setup(r, authHandler, apiKeyHandler, webhookHandler, dashboardHandler, orgHandler, invitationHandler, apiKeyRepo, membershipRepo, cfg.JwtSecret)
+	router.Setup(r, authHandler, apiKeyHandler, webhookHandler, dashboardHandler, orgHandler, invitationHandler, endpointHandler, signingKeyHandler, messageHandler, listenHandler, notificationHandler, usageHandler, reportHandler, exportHandler, logStreamHandler, apiKeyRepo, membershipRepo, cfg.JwtSecret)
 
 	slog.Info("server starting", "port", cfg.Port)
 	err = http.ListenAndServe(":"+cfg.Port, r)
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/pkg/client"
+)
+
+var (
+	errNoAPIKey = errors.New("no API key configured; run `chis login --api-key <key>`")
+	errNoToken  = errors.New("not logged in; run `chis login --email <email> --password <password>`")
+)
+
+// dashboard calls the JWT-authenticated /api routes the web dashboard uses.
+// With apiKey set it calls the /webhook routes the SDK does not cover.
+type dashboard struct {
+	baseURL string
+	token   string
+	apiKey  string
+	orgID   string
+	http    *http.Client
+}
+
+func newDashboard(p *Profile) (*dashboard, error) {
+	if p.Token == "" {
+		return nil, errNoToken
+	}
+	return &dashboard{
+		baseURL: strings.TrimRight(p.APIURL, "/"),
+		token:   p.Token,
+		orgID:   p.OrgID,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// newKeyed returns a caller authenticated with the profile's API key.
+func newKeyed(p *Profile, timeout time.Duration) (*dashboard, error) {
+	if p.APIKey == "" {
+		return nil, errNoAPIKey
+	}
+	return &dashboard{
+		baseURL: strings.TrimRight(p.APIURL, "/"),
+		apiKey:  p.APIKey,
+		http:    &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func newClient(p *Profile) (*client.Client, error) {
+	if p.APIKey == "" {
+		return nil, errNoAPIKey
+	}
+	return client.New(p.APIURL, p.APIKey), nil
+}
+
+func (d *dashboard) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, d.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if d.token != "" {
+		req.Header.Set("Authorization", "Bearer "+d.token)
+	}
+	if d.apiKey != "" {
+		req.Header.Set("X-API-Key", d.apiKey)
+	}
+	if d.orgID != "" {
+		req.Header.Set("X-Org-ID", d.orgID)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		apiErr := &client.Error{StatusCode: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(apiErr)
+		return apiErr
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// login exchanges credentials for a token.
+func login(ctx context.Context, apiURL, email, password string) (string, error) {
+	d := &dashboard{baseURL: strings.TrimRight(apiURL, "/"), http: &http.Client{Timeout: 30 * time.Second}}
+
+	var res struct {
+		Token string `json:"token"`
+	}
+	req := map[string]string{"email": email, "password": password}
+	if err := d.do(ctx, http.MethodPost, "/auth/login", req, &res); err != nil {
+		return "", err
+	}
+	return res.Token, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Profile holds the credentials for one org in one environment. API key
+// commands use APIKey; dashboard commands use Token and OrgID.
+type Profile struct {
+	APIURL string `json:"apiUrl"`
+	APIKey string `json:"apiKey,omitempty"`
+	Token  string `json:"token,omitempty"`
+	OrgID  string `json:"orgId,omitempty"`
+}
+
+type Config struct {
+	Current  string              `json:"current"`
+	Profiles map[string]*Profile `json:"profiles"`
+}
+
+const defaultProfile = "default"
+
+func configPath() (string, error) {
+	if p := os.Getenv("CHIS_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "chis", "config.json"), nil
+}
+
+func loadConfig() (*Config, error) {
+	cfg := &Config{Current: defaultProfile, Profiles: map[string]*Profile{}}
+
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]*Profile{}
+	}
+	return cfg, nil
+}
+
+// save writes the config readable only by the user, since it holds
+// credentials.
+func (c *Config) save() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// profile resolves the named profile (or the current one) and applies
+// CHIS_API_URL, CHIS_API_KEY, CHIS_TOKEN and CHIS_ORG_ID overrides.
+func (c *Config) profile(name string) *Profile {
+	if name == "" {
+		name = os.Getenv("CHIS_PROFILE")
+	}
+	if name == "" {
+		name = c.Current
+	}
+
+	p := Profile{APIURL: "http://localhost:8080"}
+	if saved, ok := c.Profiles[name]; ok {
+		p = *saved
+	}
+	if v := os.Getenv("CHIS_API_URL"); v != "" {
+		p.APIURL = v
+	}
+	if v := os.Getenv("CHIS_API_KEY"); v != "" {
+		p.APIKey = v
+	}
+	if v := os.Getenv("CHIS_TOKEN"); v != "" {
+		p.Token = v
+	}
+	if v := os.Getenv("CHIS_ORG_ID"); v != "" {
+		p.OrgID = v
+	}
+	return &p
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/pkg/client"
+	"github.com/google/uuid"
+)
+
+const (
+	// listenPollTimeout must outlast the server's 25s long-poll.
+	listenPollTimeout = 35 * time.Second
+	listenRetryDelay  = 2 * time.Second
+	maxRelayBodyBytes = 64 << 10
+)
+
+type relayRequest struct {
+	ID        string      `json:"id"`
+	MessageID string      `json:"messageId"`
+	Method    string      `json:"method"`
+	URL       string      `json:"url"`
+	Headers   http.Header `json:"headers"`
+	Body      []byte      `json:"body"`
+}
+
+type relayResponse struct {
+	StatusCode int         `json:"statusCode,omitempty"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+func runListen(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("chis listen", flag.ContinueOnError)
+	var g globals
+	g.register(fs)
+	endpoint := fs.String("endpoint", "", "relay endpoint ID (required)")
+	forwardTo := fs.String("forward-to", "", "local URL to deliver to, e.g. http://localhost:3000/webhooks (required)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *endpoint == "" || *forwardTo == "" {
+		return errors.New("--endpoint and --forward-to are required")
+	}
+	id, err := uuid.Parse(*endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint ID: %w", err)
+	}
+
+	_, prof, _, err := g.setup()
+	if err != nil {
+		return err
+	}
+	c, err := newClient(prof)
+	if err != nil {
+		return err
+	}
+	d, err := newKeyed(prof, listenPollTimeout)
+	if err != nil {
+		return err
+	}
+
+	ep, err := c.GetEndpoint(ctx, id)
+	if err != nil {
+		return err
+	}
+	// Only org admins may switch relay on, so an API key can't divert an
+	// endpoint's deliveries by itself.
+	if !ep.Relay {
+		return fmt.Errorf("endpoint %s is not a relay endpoint; ask an org admin to set \"relay\": true on it", id)
+	}
+
+	fmt.Fprintf(os.Stderr, "Forwarding deliveries for %s to %s (Ctrl-C to stop)\n", ep.URL, *forwardTo)
+
+	local := &http.Client{Timeout: 25 * time.Second}
+	base := "/webhook/listen/" + id.String()
+	for {
+		var req relayRequest
+		err := d.do(ctx, http.MethodGet, base, nil, &req)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			var apiErr *client.Error
+			if errors.As(err, &apiErr) && apiErr.StatusCode < 500 {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "chis: poll failed: %v; retrying\n", err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(listenRetryDelay):
+			}
+			continue
+		}
+		if req.ID == "" {
+			continue
+		}
+
+		start := time.Now()
+		resp := forward(ctx, local, *forwardTo, &req)
+		result := resp.Error
+		if result == "" {
+			result = fmt.Sprint(resp.StatusCode)
+		}
+		fmt.Printf("%s  %-6s  %s  %-4s  %dms\n",
+			start.Format("15:04:05"), req.Method, req.MessageID, result, time.Since(start).Milliseconds())
+
+		if err := d.do(ctx, http.MethodPost, base+"/responses/"+req.ID, resp, nil); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "chis: failed to send response for %s: %v\n", req.MessageID, err)
+		}
+	}
+}
+
+// forward replays a relayed delivery against the local server.
+func forward(ctx context.Context, hc *http.Client, target string, rr *relayRequest) *relayResponse {
+	req, err := http.NewRequestWithContext(ctx, rr.Method, target, bytes.NewReader(rr.Body))
+	if err != nil {
+		return &relayResponse{Error: err.Error()}
+	}
+	for k, v := range rr.Headers {
+		if k == "Host" || k == "Content-Length" {
+			continue
+		}
+		req.Header[k] = v
+	}
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return &relayResponse{Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxRelayBodyBytes))
+	return &relayResponse{
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Header,
+		Body:       body,
+	}
+}
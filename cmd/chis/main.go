@@ -0,0 +1,100 @@
+// Command chis is the command-line client for day-to-day Chis operations.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"login", "Save credentials for a profile", runLogin},
+	{"profiles", "List saved profiles", runProfiles},
+	{"use", "Switch the current profile", runUse},
+	{"send", "Send a test event", runSend},
+	{"logs", "List or tail delivery logs", runLogs},
+	{"messages", "Inspect, replay or cancel a message", runMessages},
+	{"api-keys", "List, create or delete API keys", runAPIKeys},
+	{"secret", "Show or rotate a signing secret", runSecret},
+	{"listen", "Relay an endpoint's deliveries to a local server", runListen},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: chis <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run `chis <command> -h` for command flags.")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name, args := os.Args[1], os.Args[2:]
+	if name == "-h" || name == "--help" || name == "help" {
+		usage()
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		err := c.run(ctx, args)
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			fmt.Fprintln(os.Stderr, "chis:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "chis: unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
+
+// globals are the flags every command accepts.
+type globals struct {
+	profile string
+	output  string
+}
+
+func (g *globals) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.profile, "profile", "", "profile to use (default: current profile or $CHIS_PROFILE)")
+	fs.StringVar(&g.output, "o", outputTable, "output format: table or json")
+}
+
+// setup loads the selected profile and output printer after parsing.
+func (g *globals) setup() (*Config, *Profile, *printer, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	p, err := newPrinter(g.output)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return cfg, cfg.profile(g.profile), p, nil
+}
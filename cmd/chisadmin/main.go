@@ -0,0 +1,295 @@
+// Command chisadmin runs operator tasks straight against the database:
+// listing plans, moving orgs between them, exporting usage for billing and
+// managing how long an org's messages are kept.
+//
+//	DB_URL=postgres://... go run ./cmd/chisadmin usage-export -from 2026-09-01 -to 2026-09-30
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/database"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, pool *pgxpool.Pool, args []string) error
+}
+
+var commands = []command{
+	{"plans", "List plans and their limits", runPlans},
+	{"set-plan", "Move an organization to a plan", runSetPlan},
+	{"usage-export", "Write every org's daily usage as CSV", runUsageExport},
+	{"set-retention", "Override how many days an org's messages are kept", runSetRetention},
+	{"legal-hold", "Exempt an org from purging, or lift the exemption", runLegalHold},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: chisadmin <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "The database is read from $DB_URL.")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name, args := os.Args[1], os.Args[2:]
+	if name == "-h" || name == "--help" || name == "help" {
+		usage()
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+
+		dbURL := os.Getenv("DB_URL")
+		if dbURL == "" {
+			fmt.Fprintln(os.Stderr, "chisadmin: DB_URL is not set")
+			os.Exit(1)
+		}
+		pool, err := database.Connect(dbURL)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "chisadmin: connect to database:", err)
+			os.Exit(1)
+		}
+
+		err = c.run(ctx, pool, args)
+		pool.Close()
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			fmt.Fprintln(os.Stderr, "chisadmin:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "chisadmin: unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
+
+func runPlans(ctx context.Context, pool *pgxpool.Pool, args []string) error {
+	fs := flag.NewFlagSet("chisadmin plans", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	plans, err := repository.NewPlanRepository(pool).FindAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tMESSAGES/MONTH\tENDPOINTS\tAPI KEYS\tRETENTION\tMAX PAYLOAD")
+	for _, p := range plans {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%dd\t%s\n", p.ID, p.Name,
+			limit(p.MonthlyMessageQuota), limit(p.MaxEndpoints), limit(p.MaxApiKeys),
+			p.RetentionDays, limit(p.MaxPayloadBytes))
+	}
+	return tw.Flush()
+}
+
+func runSetPlan(ctx context.Context, pool *pgxpool.Pool, args []string) error {
+	fs := flag.NewFlagSet("chisadmin set-plan", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chisadmin set-plan <org-id> <plan-id>")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("expected an org ID and a plan ID")
+	}
+
+	orgID, err := uuid.Parse(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid org ID %q", fs.Arg(0))
+	}
+
+	err = repository.NewPlanRepository(pool).SetOrgPlan(ctx, orgID, fs.Arg(1))
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("no org %s or no plan %q", orgID, fs.Arg(1))
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Moved %s to the %s plan.\n", orgID, fs.Arg(1))
+	return nil
+}
+
+func runUsageExport(ctx context.Context, pool *pgxpool.Pool, args []string) error {
+	fs := flag.NewFlagSet("chisadmin usage-export", flag.ContinueOnError)
+	now := time.Now().UTC()
+	lastMonth := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC)
+	fromFlag := fs.String("from", lastMonth.Format(time.DateOnly), "first day to export (default: start of last month)")
+	toFlag := fs.String("to", lastMonth.AddDate(0, 1, -1).Format(time.DateOnly), "last day to export (default: end of last month)")
+	out := fs.String("out", "", "file to write (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	from, err := time.Parse(time.DateOnly, *fromFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -from %q", *fromFlag)
+	}
+	to, err := time.Parse(time.DateOnly, *toFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -to %q", *toFlag)
+	}
+	if to.Before(from) {
+		return errors.New("-to must not be before -from")
+	}
+
+	days, err := repository.NewUsageRepository(pool).FindAll(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"org_id", "day", "messages", "payload_bytes", "delivery_attempts"})
+	for _, d := range days {
+		cw.Write([]string{
+			d.OrgID.String(),
+			d.Day.Format(time.DateOnly),
+			strconv.FormatInt(d.Messages, 10),
+			strconv.FormatInt(d.PayloadBytes, 10),
+			strconv.FormatInt(d.DeliveryAttempts, 10),
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	if *out != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %d rows to %s.\n", len(days), *out)
+	}
+	return nil
+}
+
+func runSetRetention(ctx context.Context, pool *pgxpool.Pool, args []string) error {
+	fs := flag.NewFlagSet("chisadmin set-retention", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chisadmin set-retention <org-id> <days|plan>")
+		fmt.Fprintln(fs.Output(), "\"plan\" removes the override, so the org's plan decides.")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("expected an org ID and a number of days")
+	}
+
+	orgID, err := uuid.Parse(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid org ID %q", fs.Arg(0))
+	}
+	var days *int
+	if fs.Arg(1) != "plan" {
+		n, err := strconv.Atoi(fs.Arg(1))
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid number of days %q", fs.Arg(1))
+		}
+		days = &n
+	}
+
+	err = repository.NewOrganizationRepository(pool).SetRetentionDays(ctx, orgID, days)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("no org %s", orgID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if days == nil {
+		fmt.Printf("%s now keeps messages for as long as its plan allows.\n", orgID)
+	} else {
+		fmt.Printf("%s now keeps messages for %d days.\n", orgID, *days)
+	}
+	return nil
+}
+
+func runLegalHold(ctx context.Context, pool *pgxpool.Pool, args []string) error {
+	fs := flag.NewFlagSet("chisadmin legal-hold", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chisadmin legal-hold <org-id> <on|off>")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 || (fs.Arg(1) != "on" && fs.Arg(1) != "off") {
+		fs.Usage()
+		return errors.New("expected an org ID and on or off")
+	}
+
+	orgID, err := uuid.Parse(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid org ID %q", fs.Arg(0))
+	}
+	hold := fs.Arg(1) == "on"
+
+	err = repository.NewOrganizationRepository(pool).SetLegalHold(ctx, orgID, hold)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("no org %s", orgID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if hold {
+		fmt.Printf("%s is under legal hold; none of its messages will be purged.\n", orgID)
+	} else {
+		fmt.Printf("Lifted the legal hold on %s; messages past retention are purged on the next run.\n", orgID)
+	}
+	return nil
+}
+
+// limit formats a plan limit, where nil means unlimited.
+func limit(n *int) string {
+	if n == nil {
+		return "unlimited"
+	}
+	return strconv.Itoa(*n)
+}
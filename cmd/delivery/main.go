@@ -8,10 +8,12 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/bilalabdelkadir/chis/internal/blobstore"
 	"github.com/bilalabdelkadir/chis/internal/config"
 	"github.com/bilalabdelkadir/chis/internal/database"
 	"github.com/bilalabdelkadir/chis/internal/delivery"
 	"github.com/bilalabdelkadir/chis/internal/logger"
+	"github.com/bilalabdelkadir/chis/internal/logstream"
 	"github.com/bilalabdelkadir/chis/internal/queue"
 	"github.com/bilalabdelkadir/chis/internal/repository"
 	pb "github.com/bilalabdelkadir/chis/proto/delivery"
@@ -50,11 +52,22 @@ func main() {
 		slog.Error("failed to connect to redis", "error", err)
 		os.Exit(1)
 	}
-	q := queue.NewQueue(rdsClient, QueueName)
+	q := queue.NewQueue(rdsClient, QueueName, cfg.QueueFairQuantum, cfg.QueueMaxWait)
 
 	messageRepo := repository.NewMessageRepository(pool)
+	endpointRepo := repository.NewEndpointRepository(pool)
+	orgRepo := repository.NewOrganizationRepository(pool)
+	planRepo := repository.NewPlanRepository(pool)
+	usageRepo := repository.NewUsageRepository(pool)
 
-	deliveryService := delivery.NewDeliveryService(messageRepo, q)
+	blobs, err := blobstore.New(cfg)
+	if err != nil {
+		slog.Error("failed to set up blob store", "error", err)
+		os.Exit(1)
+	}
+
+	deliveryService := delivery.NewDeliveryService(messageRepo, endpointRepo, orgRepo, planRepo, usageRepo, q, blobs,
+		logstream.New(rdsClient), cfg.MaxPayloadBytes, cfg.PayloadOffloadThreshold)
 
 	lis, err := net.Listen("tcp", ":50051")
 	if err != nil {
@@ -72,7 +85,9 @@ func main() {
 		}
 	}()
 
-	grpcServer := grpc.NewServer()
+	// Leave headroom over the payload limit for the rest of the request so
+	// oversized payloads reach QueueMessage and get a proper error.
+	grpcServer := grpc.NewServer(grpc.MaxRecvMsgSize(cfg.MaxPayloadBytes + 64<<10))
 	pb.RegisterDeliveryServiceServer(grpcServer, deliveryService)
 	if err := grpcServer.Serve(lis); err != nil {
 		slog.Error("grpc_server_failed", "error", err)
@@ -7,12 +7,18 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/bilalabdelkadir/chis/internal/blobstore"
 	"github.com/bilalabdelkadir/chis/internal/config"
 	"github.com/bilalabdelkadir/chis/internal/database"
+	"github.com/bilalabdelkadir/chis/internal/email"
+	"github.com/bilalabdelkadir/chis/internal/export"
 	"github.com/bilalabdelkadir/chis/internal/logger"
+	"github.com/bilalabdelkadir/chis/internal/logstream"
+	"github.com/bilalabdelkadir/chis/internal/notify"
 	"github.com/bilalabdelkadir/chis/internal/queue"
 	"github.com/bilalabdelkadir/chis/internal/repository"
 	"github.com/bilalabdelkadir/chis/internal/scheduler"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var QueueName = "main"
@@ -41,6 +47,16 @@ func main() {
 	slog.Info("database_connected")
 
 	messageRepo := repository.NewMessageRepository(pool)
+	notificationRepo := repository.NewNotificationRepository(pool)
+	analyticsRepo := repository.NewAnalyticsRepository(pool)
+	exportRepo := repository.NewExportRepository(pool)
+	orgRepo := repository.NewOrganizationRepository(pool)
+
+	blobs, err := blobstore.New(cfg)
+	if err != nil {
+		slog.Error("failed to set up blob store", "error", err)
+		os.Exit(1)
+	}
 
 	ctx := context.Background()
 
@@ -49,18 +65,46 @@ func main() {
 		slog.Error("failed to connect to redis", "error", err)
 		os.Exit(1)
 	}
-	queue := queue.NewQueue(rdsClient, QueueName)
+	queue := queue.NewQueue(rdsClient, QueueName, cfg.QueueFairQuantum, cfg.QueueMaxWait)
 
 	slog.Info("scheduler_started")
 
 	go func() {
 		http.HandleFunc("/health", healthHandler)
+		http.Handle("/metrics", promhttp.Handler())
 		slog.Info("health_server_started", "port", 8084)
 		if err := http.ListenAndServe(":8084", nil); err != nil {
 			slog.Error("health_server_failed", "error", err)
 		}
 	}()
 
-	w := scheduler.NewScheduler(messageRepo, queue)
+	var emailService *email.EmailService
+	if cfg.ResendApiKey != "" {
+		emailService = email.NewEmailService(cfg.ResendApiKey, cfg.AppUrl)
+	} else {
+		slog.Warn("notification_emails_disabled", "reason", "RESEND_API_KEY not set")
+	}
+	notifier := notify.NewNotifier(notificationRepo, messageRepo, emailService, notify.Thresholds{
+		DeadLetters: cfg.NotifyDeadLetterThreshold,
+		SuccessRate: cfg.NotifySuccessRateThreshold,
+	})
+	go notifier.Start(ctx)
+
+	go scheduler.NewRollupRefresher(analyticsRepo).Start(ctx)
+	if cfg.RetentionPurgeEnabled {
+		go scheduler.NewRetentionPurger(orgRepo, messageRepo, blobs).Start(ctx)
+	} else {
+		slog.Warn("retention_purge_disabled", "reason", "RETENTION_PURGE_ENABLED not set")
+	}
+	go scheduler.NewRateSyncer(orgRepo, queue).Start(ctx)
+
+	if blobs != nil {
+		exporter := export.NewExporter(exportRepo, orgRepo, blobs)
+		go scheduler.NewExportRunner(exportRepo, exporter, blobs).Start(ctx)
+	} else {
+		slog.Warn("export_jobs_disabled", "reason", "BLOB_STORE not set")
+	}
+
+	w := scheduler.NewScheduler(messageRepo, queue, logstream.New(rdsClient))
 	w.Start(context.Background())
 }
@@ -6,13 +6,18 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/bilalabdelkadir/chis/internal/blobstore"
 	"github.com/bilalabdelkadir/chis/internal/config"
 	"github.com/bilalabdelkadir/chis/internal/database"
 	"github.com/bilalabdelkadir/chis/internal/logger"
+	"github.com/bilalabdelkadir/chis/internal/logstream"
 	"github.com/bilalabdelkadir/chis/internal/queue"
+	"github.com/bilalabdelkadir/chis/internal/relay"
 	"github.com/bilalabdelkadir/chis/internal/repository"
 	"github.com/bilalabdelkadir/chis/internal/worker"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var QueueName = "main"
@@ -43,6 +48,10 @@ func main() {
 	messageRepo := repository.NewMessageRepository(pool)
 	attemptRepo := repository.NewDeliveryAttemptsRepository(pool)
 	orgRepo := repository.NewOrganizationRepository(pool)
+	endpointRepo := repository.NewEndpointRepository(pool)
+	signingKeyRepo := repository.NewSigningKeyRepository(pool)
+	notificationRepo := repository.NewNotificationRepository(pool)
+	usageRepo := repository.NewUsageRepository(pool)
 
 	ctx := context.Background()
 
@@ -51,18 +60,26 @@ func main() {
 		slog.Error("failed to connect to redis", "error", err)
 		os.Exit(1)
 	}
-	queue := queue.NewQueue(rdsClient, QueueName)
+	queue := queue.NewQueue(rdsClient, QueueName, cfg.QueueFairQuantum, cfg.QueueMaxWait)
+	go queue.ReportDepth(ctx, 15*time.Second)
 
 	slog.Info("worker_started")
 
 	go func() {
 		http.HandleFunc("/health", healthHandler)
+		http.Handle("/metrics", promhttp.Handler())
 		slog.Info("health_server_started", "port", 8083)
 		if err := http.ListenAndServe(":8083", nil); err != nil {
 			slog.Error("health_server_failed", "error", err)
 		}
 	}()
 
-	w := worker.NewWorker(messageRepo, attemptRepo, orgRepo, queue)
+	blobs, err := blobstore.New(cfg)
+	if err != nil {
+		slog.Error("failed to set up blob store", "error", err)
+		os.Exit(1)
+	}
+
+	w := worker.NewWorker(messageRepo, attemptRepo, orgRepo, endpointRepo, signingKeyRepo, notificationRepo, usageRepo, blobs, queue, relay.New(rdsClient), logstream.New(rdsClient), cfg.EndpointDisableAfter, worker.LimitsFromConfig(cfg))
 	w.Start(context.Background())
 }
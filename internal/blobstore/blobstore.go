@@ -0,0 +1,44 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bilalabdelkadir/chis/internal/config"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps payloads that are too large to live inline in Postgres. Keys
+// are content hashes, so writing the same key twice is harmless.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// New builds the store selected by BLOB_STORE. It returns nil when blob
+// storage is disabled.
+func New(cfg *config.Config) (Store, error) {
+	switch cfg.BlobStore {
+	case "":
+		return nil, nil
+	case "local":
+		return NewLocalStore(cfg.BlobDir)
+	case "s3":
+		return NewS3Store(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey)
+	default:
+		return nil, fmt.Errorf("unknown blob store: %s", cfg.BlobStore)
+	}
+}
+
+// ContentKey returns the content-addressed key for data.
+func ContentKey(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
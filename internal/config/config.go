@@ -2,21 +2,89 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Port         string
-	DbUrl        string
-	JwtSecret    string
-	RedisUrl     string
-	GrpcAddr     string
-	Env          string
-	Origin       string
-	ResendApiKey string
-	AppUrl       string
+	Port            string
+	DbUrl           string
+	JwtSecret       string
+	RedisUrl        string
+	GrpcAddr        string
+	Env             string
+	Origin          string
+	ResendApiKey    string
+	AppUrl          string
+	MaxPayloadBytes int
+
+	// Payloads larger than PayloadOffloadThreshold are written to the blob
+	// store instead of messages.payload. BlobStore is "", "local" or "s3".
+	PayloadOffloadThreshold int
+	BlobStore               string
+	BlobDir                 string
+	S3Endpoint              string
+	S3Region                string
+	S3Bucket                string
+	S3AccessKey             string
+	S3SecretKey             string
+
+	// SigningSecretGracePeriod is how long a rotated signing secret keeps
+	// being used alongside the new one.
+	SigningSecretGracePeriod time.Duration
+
+	// EndpointDisableAfter is how long an endpoint may fail continuously
+	// before it is disabled. Zero never disables endpoints.
+	EndpointDisableAfter time.Duration
+
+	// DeliveryTimeout bounds each delivery attempt. Endpoints may set their
+	// own timeout between DeliveryTimeoutMin and DeliveryTimeoutMax. The
+	// connect, TLS and read timeouts apply within it; the read timeout is
+	// how long to wait for response headers once the request is sent.
+	DeliveryTimeout        time.Duration
+	DeliveryTimeoutMin     time.Duration
+	DeliveryTimeoutMax     time.Duration
+	DeliveryConnectTimeout time.Duration
+	DeliveryTLSTimeout     time.Duration
+	DeliveryReadTimeout    time.Duration
+	// MaxResponseBytes is how much of each response body is stored.
+	MaxResponseBytes int
+
+	// DeliveryConcurrency is how many deliveries each worker sends at once.
+	DeliveryConcurrency int
+
+	// The worker keeps up to DeliveryMaxIdleConnsPerHost connections open
+	// to each receiving host, DeliveryMaxIdleConns in all, and caches DNS
+	// answers for DeliveryDNSCacheTTL.
+	DeliveryMaxIdleConnsPerHost int
+	DeliveryMaxIdleConns        int
+	DeliveryIdleConnTimeout     time.Duration
+	DeliveryDNSCacheTTL         time.Duration
+
+	// RelayMaxInFlight is how many relayed deliveries a worker waits on at
+	// once, so slow local servers can't occupy all of it.
+	RelayMaxInFlight int
+
+	// Admins are emailed when more than NotifyDeadLetterThreshold messages
+	// fail for good, or fewer than NotifySuccessRateThreshold percent of
+	// attempts succeed, within an hour.
+	NotifyDeadLetterThreshold  int
+	NotifySuccessRateThreshold int
+
+	// QueueFairQuantum is how many messages a worker takes from one org
+	// before serving the next org waiting in the same lane.
+	QueueFairQuantum int
+	// QueueMaxWait is how long an org's oldest message may wait before it
+	// is served ahead of the other orgs. Zero leaves it to the turns.
+	QueueMaxWait time.Duration
+
+	// RetentionPurgeEnabled turns on the scheduler's retention purge. It is
+	// off by default because the purge permanently deletes messages.
+	RetentionPurgeEnabled bool
 }
 
 func LoadEnv() (*Config, error) {
@@ -60,16 +128,232 @@ func LoadEnv() (*Config, error) {
 		appUrl = "http://localhost:3000"
 	}
 
+	maxPayloadBytes, err := positiveInt("MAX_PAYLOAD_BYTES", 1<<20) // 1 MiB
+	if err != nil {
+		return nil, err
+	}
+
+	offloadThreshold, err := positiveInt("PAYLOAD_OFFLOAD_THRESHOLD", 64<<10) // 64 KiB
+	if err != nil {
+		return nil, err
+	}
+
+	blobStore := os.Getenv("BLOB_STORE")
+	if blobStore != "" && blobStore != "local" && blobStore != "s3" {
+		return nil, errors.New("BLOB_STORE must be one of: local, s3.")
+	}
+
+	blobDir := os.Getenv("BLOB_DIR")
+	if blobDir == "" {
+		blobDir = "data/blobs"
+	}
+
+	s3Region := os.Getenv("S3_REGION")
+	if s3Region == "" {
+		s3Region = "us-east-1"
+	}
+
+	gracePeriod, err := duration("SIGNING_SECRET_GRACE_PERIOD", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	disableAfter, err := duration("ENDPOINT_DISABLE_AFTER", 72*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveryTimeout, err := positiveDuration("DELIVERY_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveryTimeoutMin, err := positiveDuration("DELIVERY_TIMEOUT_MIN", time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveryTimeoutMax, err := positiveDuration("DELIVERY_TIMEOUT_MAX", 60*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if deliveryTimeout < deliveryTimeoutMin || deliveryTimeout > deliveryTimeoutMax {
+		return nil, errors.New("DELIVERY_TIMEOUT must be between DELIVERY_TIMEOUT_MIN and DELIVERY_TIMEOUT_MAX.")
+	}
+
+	connectTimeout, err := positiveDuration("DELIVERY_CONNECT_TIMEOUT", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsTimeout, err := positiveDuration("DELIVERY_TLS_TIMEOUT", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	readTimeout, err := positiveDuration("DELIVERY_READ_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	maxResponseBytes, err := positiveInt("MAX_RESPONSE_BYTES", 64<<10) // 64 KiB
+	if err != nil {
+		return nil, err
+	}
+
+	deliveryConcurrency, err := positiveInt("DELIVERY_CONCURRENCY", 16)
+	if err != nil {
+		return nil, err
+	}
+
+	maxIdleConnsPerHost, err := positiveInt("DELIVERY_MAX_IDLE_CONNS_PER_HOST", 64)
+	if err != nil {
+		return nil, err
+	}
+
+	maxIdleConns, err := positiveInt("DELIVERY_MAX_IDLE_CONNS", 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	idleConnTimeout, err := positiveDuration("DELIVERY_IDLE_CONN_TIMEOUT", 90*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	dnsCacheTTL, err := duration("DELIVERY_DNS_CACHE_TTL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	relayMaxInFlight, err := positiveInt("RELAY_MAX_IN_FLIGHT", 4)
+	if err != nil {
+		return nil, err
+	}
+
+	fairQuantum, err := positiveInt("QUEUE_FAIR_QUANTUM", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	queueMaxWait, err := duration("QUEUE_MAX_WAIT", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	retentionPurgeEnabled, err := boolean("RETENTION_PURGE_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetterThreshold, err := positiveInt("NOTIFY_DEAD_LETTER_THRESHOLD", 100)
+	if err != nil {
+		return nil, err
+	}
+
+	successRateThreshold, err := positiveInt("NOTIFY_SUCCESS_RATE_THRESHOLD", 90)
+	if err != nil {
+		return nil, err
+	}
+	if successRateThreshold > 100 {
+		return nil, errors.New("NOTIFY_SUCCESS_RATE_THRESHOLD must be a percentage between 1 and 100.")
+	}
+
 	return &Config{
-		Port:         port,
-		DbUrl:        dbUrl,
-		JwtSecret:    jwtSecret,
-		RedisUrl:     redisUlr,
-		GrpcAddr:     grpcAddr,
-		Env:          env,
-		Origin:       origin,
-		ResendApiKey: resendApiKey,
-		AppUrl:       appUrl,
+		Port:            port,
+		DbUrl:           dbUrl,
+		JwtSecret:       jwtSecret,
+		RedisUrl:        redisUlr,
+		GrpcAddr:        grpcAddr,
+		Env:             env,
+		Origin:          origin,
+		ResendApiKey:    resendApiKey,
+		AppUrl:          appUrl,
+		MaxPayloadBytes: maxPayloadBytes,
+
+		PayloadOffloadThreshold: offloadThreshold,
+		BlobStore:               blobStore,
+		BlobDir:                 blobDir,
+		S3Endpoint:              os.Getenv("S3_ENDPOINT"),
+		S3Region:                s3Region,
+		S3Bucket:                os.Getenv("S3_BUCKET"),
+		S3AccessKey:             os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretKey:             os.Getenv("S3_SECRET_ACCESS_KEY"),
+
+		SigningSecretGracePeriod: gracePeriod,
+		EndpointDisableAfter:     disableAfter,
+
+		DeliveryTimeout:        deliveryTimeout,
+		DeliveryTimeoutMin:     deliveryTimeoutMin,
+		DeliveryTimeoutMax:     deliveryTimeoutMax,
+		DeliveryConnectTimeout: connectTimeout,
+		DeliveryTLSTimeout:     tlsTimeout,
+		DeliveryReadTimeout:    readTimeout,
+		MaxResponseBytes:       maxResponseBytes,
+
+		DeliveryConcurrency: deliveryConcurrency,
+
+		DeliveryMaxIdleConnsPerHost: maxIdleConnsPerHost,
+		DeliveryMaxIdleConns:        maxIdleConns,
+		DeliveryIdleConnTimeout:     idleConnTimeout,
+		DeliveryDNSCacheTTL:         dnsCacheTTL,
+
+		RelayMaxInFlight: relayMaxInFlight,
+
+		NotifyDeadLetterThreshold:  deadLetterThreshold,
+		NotifySuccessRateThreshold: successRateThreshold,
+
+		QueueFairQuantum: fairQuantum,
+		QueueMaxWait:     queueMaxWait,
+
+		RetentionPurgeEnabled: retentionPurgeEnabled,
 	}, nil
 
 }
+
+func positiveInt(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer.", name)
+	}
+	return n, nil
+}
+
+func boolean(name string, def bool) (bool, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false.", name)
+	}
+	return b, nil
+}
+
+func duration(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s must be a duration such as 30s or 24h.", name)
+	}
+	return d, nil
+}
+
+func positiveDuration(name string, def time.Duration) (time.Duration, error) {
+	d, err := duration(name, def)
+	if err != nil {
+		return 0, err
+	}
+	if d == 0 {
+		return 0, fmt.Errorf("%s must be greater than zero.", name)
+	}
+	return d, nil
+}
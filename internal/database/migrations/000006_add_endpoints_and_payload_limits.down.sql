@@ -0,0 +1,3 @@
+ALTER TABLE organizations DROP COLUMN IF EXISTS max_payload_bytes;
+ALTER TABLE messages DROP COLUMN IF EXISTS endpoint_id;
+DROP TABLE IF EXISTS endpoints;
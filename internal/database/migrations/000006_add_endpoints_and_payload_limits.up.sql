@@ -0,0 +1,31 @@
+CREATE TABLE endpoints (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    content_encoding TEXT NOT NULL DEFAULT 'identity' CHECK (content_encoding IN ('identity', 'gzip', 'zstd')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (org_id, url)
+);
+
+CREATE TRIGGER endpoints_update_at
+BEFORE UPDATE ON endpoints
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at();
+
+ALTER TABLE messages
+ADD COLUMN endpoint_id UUID REFERENCES endpoints(id) ON DELETE SET NULL;
+
+INSERT INTO endpoints (org_id, url)
+SELECT DISTINCT org_id, url FROM messages
+ON CONFLICT (org_id, url) DO NOTHING;
+
+UPDATE messages m
+SET endpoint_id = e.id
+FROM endpoints e
+WHERE e.org_id = m.org_id AND e.url = m.url;
+
+CREATE INDEX idx_messages_endpoint_id ON messages(endpoint_id);
+
+ALTER TABLE organizations
+ADD COLUMN max_payload_bytes INT CHECK (max_payload_bytes > 0);
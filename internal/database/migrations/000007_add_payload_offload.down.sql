@@ -0,0 +1,5 @@
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_payload_present;
+ALTER TABLE messages
+DROP COLUMN IF EXISTS payload_ref,
+DROP COLUMN IF EXISTS payload_size;
+ALTER TABLE messages ALTER COLUMN payload SET NOT NULL;
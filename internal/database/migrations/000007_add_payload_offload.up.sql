@@ -0,0 +1,10 @@
+ALTER TABLE messages ALTER COLUMN payload DROP NOT NULL;
+
+ALTER TABLE messages
+ADD COLUMN payload_ref TEXT,
+ADD COLUMN payload_size INT;
+
+ALTER TABLE messages
+ADD CONSTRAINT messages_payload_present CHECK (payload IS NOT NULL OR payload_ref IS NOT NULL);
+
+CREATE INDEX idx_messages_payload_ref ON messages(payload_ref) WHERE payload_ref IS NOT NULL;
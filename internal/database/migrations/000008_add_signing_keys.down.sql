@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS signing_keys;
+ALTER TABLE organizations DROP COLUMN IF EXISTS signature_scheme;
@@ -0,0 +1,17 @@
+ALTER TABLE organizations
+ADD COLUMN signature_scheme TEXT NOT NULL DEFAULT 'hmac' CHECK (signature_scheme IN ('hmac', 'ed25519', 'both'));
+
+CREATE TABLE signing_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    public_key TEXT NOT NULL,
+    private_key TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'retired')),
+    retired_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_signing_keys_org_id ON signing_keys(org_id);
+
+-- At most one active key per org
+CREATE UNIQUE INDEX idx_signing_keys_active ON signing_keys(org_id) WHERE status = 'active';
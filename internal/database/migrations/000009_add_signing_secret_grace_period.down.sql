@@ -0,0 +1,3 @@
+ALTER TABLE organizations
+DROP COLUMN IF EXISTS previous_signing_secret,
+DROP COLUMN IF EXISTS previous_secret_expires_at;
@@ -0,0 +1,3 @@
+ALTER TABLE organizations
+ADD COLUMN previous_signing_secret TEXT,
+ADD COLUMN previous_secret_expires_at TIMESTAMP WITH TIME ZONE;
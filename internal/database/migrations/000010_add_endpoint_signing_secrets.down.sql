@@ -0,0 +1,4 @@
+ALTER TABLE endpoints
+DROP COLUMN IF EXISTS signing_secret,
+DROP COLUMN IF EXISTS previous_signing_secret,
+DROP COLUMN IF EXISTS previous_secret_expires_at;
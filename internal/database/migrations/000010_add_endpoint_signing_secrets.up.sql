@@ -0,0 +1,5 @@
+-- NULL signing_secret means the endpoint uses the org-wide secret
+ALTER TABLE endpoints
+ADD COLUMN signing_secret TEXT,
+ADD COLUMN previous_signing_secret TEXT,
+ADD COLUMN previous_secret_expires_at TIMESTAMP WITH TIME ZONE;
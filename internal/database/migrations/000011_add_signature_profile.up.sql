@@ -0,0 +1,2 @@
+ALTER TABLE organizations
+ADD COLUMN signature_profile TEXT NOT NULL DEFAULT 'chis' CHECK (signature_profile IN ('chis', 'standard'));
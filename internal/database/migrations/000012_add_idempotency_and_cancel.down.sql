@@ -0,0 +1,8 @@
+-- Postgres cannot drop an enum value, so 'cancelled' stays on message_status.
+UPDATE messages SET status = 'failed' WHERE status = 'cancelled';
+
+DROP INDEX IF EXISTS idx_messages_org_idempotency_key;
+
+ALTER TABLE messages
+DROP COLUMN IF EXISTS idempotency_key,
+DROP COLUMN IF EXISTS replayed_from;
@@ -0,0 +1,14 @@
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_enum WHERE enumlabel = 'cancelled' AND enumtypid = 'message_status'::regtype) THEN
+        ALTER TYPE message_status ADD VALUE 'cancelled';
+    END IF;
+END
+$$;
+
+ALTER TABLE messages
+ADD COLUMN idempotency_key TEXT,
+ADD COLUMN replayed_from UUID REFERENCES messages(id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX idx_messages_org_idempotency_key ON messages(org_id, idempotency_key)
+WHERE idempotency_key IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Relay endpoints are delivered through a `chis listen` session instead of HTTP
+ALTER TABLE endpoints
+ADD COLUMN relay BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,9 @@
+-- Postgres cannot drop an enum value, so 'held' stays on message_status.
+UPDATE messages SET status = 'failed' WHERE status = 'held';
+
+ALTER TABLE endpoints
+DROP COLUMN IF EXISTS consecutive_failures,
+DROP COLUMN IF EXISTS failing_since,
+DROP COLUMN IF EXISTS last_success_at,
+DROP COLUMN IF EXISTS disabled_at,
+DROP COLUMN IF EXISTS disabled_reason;
@@ -0,0 +1,16 @@
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_enum WHERE enumlabel = 'held' AND enumtypid = 'message_status'::regtype) THEN
+        ALTER TYPE message_status ADD VALUE 'held';
+    END IF;
+END
+$$;
+
+-- Health counters are kept up to date by the worker; success rates are
+-- computed from delivery_attempts on read.
+ALTER TABLE endpoints
+ADD COLUMN consecutive_failures INT NOT NULL DEFAULT 0,
+ADD COLUMN failing_since TIMESTAMP WITH TIME ZONE,
+ADD COLUMN last_success_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN disabled_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN disabled_reason TEXT;
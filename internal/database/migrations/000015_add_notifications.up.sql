@@ -0,0 +1,30 @@
+-- Notifications are org events worth telling admins about. They are mailed
+-- in per-user digests rather than one email per event.
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('endpoint_disabled', 'dead_letters', 'success_rate')),
+    endpoint_id UUID REFERENCES endpoints(id) ON DELETE SET NULL,
+    summary TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_notifications_org_created_at ON notifications(org_id, created_at);
+
+CREATE TABLE notification_preferences (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    endpoint_disabled BOOLEAN NOT NULL DEFAULT TRUE,
+    dead_letters BOOLEAN NOT NULL DEFAULT TRUE,
+    success_rate BOOLEAN NOT NULL DEFAULT TRUE,
+    digest_interval_minutes INT NOT NULL DEFAULT 60 CHECK (digest_interval_minutes IN (15, 60, 1440)),
+    last_digest_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (user_id, org_id)
+);
+
+CREATE TRIGGER notification_preferences_update_at
+BEFORE UPDATE ON notification_preferences
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at();
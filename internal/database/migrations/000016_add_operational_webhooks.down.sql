@@ -0,0 +1,5 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS event_type;
+
+DROP INDEX IF EXISTS idx_endpoints_operational;
+
+ALTER TABLE endpoints DROP COLUMN IF EXISTS operational;
@@ -0,0 +1,9 @@
+-- Operational endpoints receive system events about the org's deliveries.
+-- Messages carrying those events have event_type set.
+ALTER TABLE endpoints
+ADD COLUMN operational BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_endpoints_operational ON endpoints(org_id) WHERE operational;
+
+ALTER TABLE messages
+ADD COLUMN event_type TEXT;
@@ -0,0 +1,3 @@
+ALTER TABLE delivery_attempts DROP COLUMN IF EXISTS response_truncated;
+
+ALTER TABLE endpoints DROP COLUMN IF EXISTS timeout_ms;
@@ -0,0 +1,7 @@
+-- timeout_ms overrides the platform delivery timeout for one endpoint.
+ALTER TABLE endpoints
+ADD COLUMN timeout_ms INTEGER CHECK (timeout_ms > 0);
+
+-- Response bodies are stored up to a size limit; longer ones are cut short.
+ALTER TABLE delivery_attempts
+ADD COLUMN response_truncated BOOLEAN NOT NULL DEFAULT FALSE;
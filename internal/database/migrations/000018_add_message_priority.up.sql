@@ -0,0 +1,4 @@
+-- Each priority is queued on its own Redis list.
+ALTER TABLE messages
+ADD COLUMN priority TEXT NOT NULL DEFAULT 'normal'
+    CHECK (priority IN ('high', 'normal', 'low'));
@@ -0,0 +1 @@
+ALTER TABLE organizations DROP COLUMN IF EXISTS max_deliveries_per_second;
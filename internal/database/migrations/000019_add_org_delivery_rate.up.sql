@@ -0,0 +1,4 @@
+-- Caps how many deliveries per second the worker sends for the org.
+-- NULL leaves the org uncapped.
+ALTER TABLE organizations
+ADD COLUMN max_deliveries_per_second INTEGER CHECK (max_deliveries_per_second > 0);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS usage_daily;
+
+ALTER TABLE organizations DROP COLUMN IF EXISTS plan_id;
+
+DROP TRIGGER IF EXISTS plans_update_at ON plans;
+DROP TABLE IF EXISTS plans;
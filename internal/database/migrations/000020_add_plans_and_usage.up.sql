@@ -0,0 +1,41 @@
+-- Plans limit what an org may use. NULL limits are unlimited; a NULL
+-- max_payload_bytes falls back to the platform limit.
+CREATE TABLE plans (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    monthly_message_quota INTEGER CHECK (monthly_message_quota > 0),
+    max_endpoints INTEGER CHECK (max_endpoints > 0),
+    max_api_keys INTEGER CHECK (max_api_keys > 0),
+    retention_days INTEGER NOT NULL CHECK (retention_days > 0),
+    max_payload_bytes INTEGER CHECK (max_payload_bytes > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TRIGGER plans_update_at
+BEFORE UPDATE ON plans
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at();
+
+INSERT INTO plans (id, name, monthly_message_quota, max_endpoints, max_api_keys, retention_days, max_payload_bytes) VALUES
+    ('free', 'Free', 10000, 5, 2, 7, 262144),
+    ('pro', 'Pro', 1000000, 50, 20, 30, NULL),
+    ('unlimited', 'Unlimited', NULL, NULL, NULL, 90, NULL);
+
+-- Orgs created before plans existed keep working without limits.
+ALTER TABLE organizations
+ADD COLUMN plan_id TEXT NOT NULL DEFAULT 'unlimited' REFERENCES plans(id);
+
+ALTER TABLE organizations ALTER COLUMN plan_id SET DEFAULT 'free';
+
+-- usage_daily meters each org per UTC day for quotas and billing.
+CREATE TABLE usage_daily (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    messages BIGINT NOT NULL DEFAULT 0,
+    payload_bytes BIGINT NOT NULL DEFAULT 0,
+    delivery_attempts BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (org_id, day)
+);
+
+CREATE INDEX idx_usage_daily_day ON usage_daily(day);
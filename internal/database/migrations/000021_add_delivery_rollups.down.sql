@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS delivery_rollups_hourly;
+DROP FUNCTION IF EXISTS latency_bucket(INTEGER);
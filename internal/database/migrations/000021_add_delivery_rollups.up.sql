@@ -0,0 +1,59 @@
+-- latency_bucket maps an attempt duration to the upper bound of its
+-- histogram bucket in milliseconds, so percentiles can be read from
+-- aggregated counts. Durations past a minute are bucketed per minute;
+-- attempts without a duration get -1.
+CREATE FUNCTION latency_bucket(ms INTEGER) RETURNS INTEGER AS $$
+    SELECT CASE
+        WHEN ms IS NULL THEN -1
+        WHEN ms > 60000 THEN ((ms + 59999) / 60000) * 60000
+        ELSE (
+            SELECT MIN(b) FROM unnest(ARRAY[
+                1, 2, 5, 10, 25, 50, 75, 100, 150, 200, 300, 500, 750,
+                1000, 1500, 2000, 3000, 5000, 7500, 10000, 15000, 20000, 30000, 60000
+            ]) AS b WHERE b >= ms
+        )
+    END
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- delivery_rollups_hourly counts delivery attempts per org and hour, split
+-- by endpoint, event type, status code and latency bucket. Messages without
+-- an endpoint use the nil UUID and attempts without a response status 0.
+-- An attempt is failed when it was the message's last, retried when
+-- another followed or will follow.
+CREATE TABLE delivery_rollups_hourly (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    hour TIMESTAMP WITH TIME ZONE NOT NULL,
+    endpoint_id UUID NOT NULL,
+    event_type TEXT NOT NULL,
+    status_code INTEGER NOT NULL,
+    latency_bucket INTEGER NOT NULL,
+    succeeded BIGINT NOT NULL DEFAULT 0,
+    retried BIGINT NOT NULL DEFAULT 0,
+    failed BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (org_id, hour, endpoint_id, event_type, status_code, latency_bucket)
+);
+
+-- Backfill everything attempted so far; the scheduler keeps the current
+-- hours up to date from here on.
+INSERT INTO delivery_rollups_hourly
+    (org_id, hour, endpoint_id, event_type, status_code, latency_bucket, succeeded, retried, failed)
+SELECT org_id, hour, endpoint_id, event_type, status_code, latency_bucket,
+    COUNT(*) FILTER (WHERE outcome = 'succeeded'),
+    COUNT(*) FILTER (WHERE outcome = 'retried'),
+    COUNT(*) FILTER (WHERE outcome = 'failed')
+FROM (
+    SELECT m.org_id,
+        date_trunc('hour', da.attempted_at) AS hour,
+        COALESCE(m.endpoint_id, '00000000-0000-0000-0000-000000000000') AS endpoint_id,
+        COALESCE(m.event_type, m.method) AS event_type,
+        COALESCE(da.status_code, 0) AS status_code,
+        latency_bucket(da.duration_ms) AS latency_bucket,
+        CASE
+            WHEN da.status_code BETWEEN 200 AND 299 THEN 'succeeded'
+            WHEN m.status = 'failed' AND da.attempt_number > m.attempt_count THEN 'failed'
+            ELSE 'retried'
+        END AS outcome
+    FROM delivery_attempts da
+    JOIN messages m ON m.id = da.message_id
+) a
+GROUP BY org_id, hour, endpoint_id, event_type, status_code, latency_bucket;
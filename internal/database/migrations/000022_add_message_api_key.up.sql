@@ -0,0 +1,4 @@
+-- api_key_id records which API key created a message. Messages created by
+-- the system, such as operational events, have none.
+ALTER TABLE messages
+ADD COLUMN api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL;
@@ -0,0 +1,9 @@
+-- Serves payload containment (@>) and JSON path (@@) searches over webhook
+-- logs. jsonb_path_ops is smaller and faster than the default operator
+-- class but cannot answer key-exists queries, which search doesn't use.
+--
+-- CONCURRENTLY keeps deliveries writing to messages while the index builds.
+-- It can't run inside a transaction, so this file must stay a single
+-- statement. A failed build leaves an invalid index behind; drop it before
+-- running the migration again.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_messages_payload ON messages USING GIN (payload jsonb_path_ops);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_invitations_org_created_at_id;
+DROP INDEX IF EXISTS idx_api_keys_org_created_at_id;
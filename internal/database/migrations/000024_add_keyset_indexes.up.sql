@@ -0,0 +1,6 @@
+-- Lists page by (created_at, id), or (attempted_at, id) for attempts, after
+-- a cursor instead of by offset. These indexes serve both the order and the
+-- cursor condition. The ones on messages and delivery_attempts are built
+-- concurrently in later migrations.
+CREATE INDEX idx_api_keys_org_created_at_id ON api_keys(org_id, created_at, id);
+CREATE INDEX idx_invitations_org_created_at_id ON invitations(org_id, created_at, id);
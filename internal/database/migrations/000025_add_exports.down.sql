@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS export_jobs;
+
+ALTER TABLE organizations DROP COLUMN IF EXISTS redact_paths;
@@ -0,0 +1,35 @@
+-- redact_paths are dotted payload paths, such as customer.email, whose
+-- values exports replace with "[REDACTED]".
+ALTER TABLE organizations
+ADD COLUMN redact_paths TEXT[] NOT NULL DEFAULT '{}';
+
+-- Export jobs write large exports to the blob store in the background.
+-- Their filters are kept so the job can be rerun or audited.
+CREATE TABLE export_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    format TEXT NOT NULL CHECK (format IN ('csv', 'ndjson')),
+    from_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    to_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    message_status TEXT NOT NULL DEFAULT '',
+    query TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed')),
+    messages BIGINT NOT NULL DEFAULT 0,
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    file_ref TEXT,
+    error TEXT,
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_export_jobs_org_created_at_id ON export_jobs(org_id, created_at, id);
+CREATE INDEX idx_export_jobs_pending ON export_jobs(created_at) WHERE status = 'pending';
+
+CREATE TRIGGER export_jobs_update_at
+BEFORE UPDATE ON export_jobs
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at();
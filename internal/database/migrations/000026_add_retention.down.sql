@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_messages_replayed_from;
+
+ALTER TABLE organizations
+DROP COLUMN IF EXISTS legal_hold,
+DROP COLUMN IF EXISTS retention_days;
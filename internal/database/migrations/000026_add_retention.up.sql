@@ -0,0 +1,9 @@
+-- Messages are kept for the plan's retention_days unless the org overrides
+-- it. Orgs under legal hold are never purged.
+ALTER TABLE organizations
+ADD COLUMN retention_days INTEGER CHECK (retention_days > 0),
+ADD COLUMN legal_hold BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Purging a message clears replayed_from on its replays, which needs this
+-- to avoid scanning messages for each one.
+CREATE INDEX idx_messages_replayed_from ON messages(replayed_from) WHERE replayed_from IS NOT NULL;
@@ -0,0 +1,4 @@
+-- Lets payload searches check cheaply whether an org has payloads in the
+-- blob store, which they can't look into. Built concurrently, so this file
+-- must stay a single statement.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_messages_org_offloaded ON messages(org_id) WHERE payload_ref IS NOT NULL;
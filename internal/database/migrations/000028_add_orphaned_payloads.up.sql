@@ -0,0 +1,7 @@
+-- Blob keys are content hashes shared by every message with the same
+-- payload, so purging a message can't delete its blob. The purge records the
+-- key here instead, and the blob is deleted once no message refers to it.
+CREATE TABLE orphaned_payloads (
+    payload_ref TEXT PRIMARY KEY,
+    orphaned_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
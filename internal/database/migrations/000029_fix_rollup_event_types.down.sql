@@ -0,0 +1,3 @@
+-- The method each merged row came from is not kept, so there is nothing
+-- to restore.
+SELECT 1;
@@ -0,0 +1,15 @@
+-- Rollups filed messages without an event type under their HTTP method.
+-- They now use an empty event type, so merge the method rows into it.
+INSERT INTO delivery_rollups_hourly
+    (org_id, hour, endpoint_id, event_type, status_code, latency_bucket, succeeded, retried, failed)
+SELECT org_id, hour, endpoint_id, '', status_code, latency_bucket, SUM(succeeded), SUM(retried), SUM(failed)
+FROM delivery_rollups_hourly
+WHERE event_type IN ('GET', 'POST', 'PUT', 'DELETE', 'HTTP_METHOD_UNSPECIFIED')
+GROUP BY org_id, hour, endpoint_id, status_code, latency_bucket
+ON CONFLICT (org_id, hour, endpoint_id, event_type, status_code, latency_bucket) DO UPDATE
+SET succeeded = delivery_rollups_hourly.succeeded + EXCLUDED.succeeded,
+    retried = delivery_rollups_hourly.retried + EXCLUDED.retried,
+    failed = delivery_rollups_hourly.failed + EXCLUDED.failed;
+
+DELETE FROM delivery_rollups_hourly
+WHERE event_type IN ('GET', 'POST', 'PUT', 'DELETE', 'HTTP_METHOD_UNSPECIFIED');
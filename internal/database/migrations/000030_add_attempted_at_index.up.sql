@@ -0,0 +1,4 @@
+-- Lets the rollup refresh find the attempts of the hours it recomputes.
+-- Built concurrently so deliveries keep writing attempts meanwhile, so this
+-- file must stay a single statement.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_delivery_attempts_attempted_at ON delivery_attempts(attempted_at);
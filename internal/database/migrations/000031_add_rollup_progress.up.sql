@@ -0,0 +1,8 @@
+-- rollup_progress holds the single point the hourly rollups are current up
+-- to, so the scheduler can catch up on the hours it missed while down.
+CREATE TABLE rollup_progress (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    rolled_up_to TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+INSERT INTO rollup_progress (rolled_up_to) VALUES (date_trunc('hour', NOW()));
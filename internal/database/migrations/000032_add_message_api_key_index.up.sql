@@ -0,0 +1,3 @@
+-- Serves the API key report and the api_key: log search. Built concurrently,
+-- so this file must stay a single statement.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_messages_api_key_id ON messages(api_key_id);
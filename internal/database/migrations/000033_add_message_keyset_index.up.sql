@@ -0,0 +1,4 @@
+-- Serves an org's messages by time, for reports and for webhook logs paged
+-- by (created_at, id). Built concurrently, so this file must stay a single
+-- statement.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_messages_org_created_at_id ON messages(org_id, created_at, id);
@@ -0,0 +1 @@
+DROP INDEX CONCURRENTLY IF EXISTS idx_delivery_attempts_message_attempted_at_id;
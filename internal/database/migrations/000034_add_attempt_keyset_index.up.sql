@@ -0,0 +1,3 @@
+-- Serves a message's attempts paged by (attempted_at, id). Built
+-- concurrently, so this file must stay a single statement.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_delivery_attempts_message_attempted_at_id ON delivery_attempts(message_id, attempted_at, id);
@@ -1,12 +1,18 @@
 package delivery
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 
+	"github.com/bilalabdelkadir/chis/internal/blobstore"
+	"github.com/bilalabdelkadir/chis/internal/logstream"
 	"github.com/bilalabdelkadir/chis/internal/model"
 	"github.com/bilalabdelkadir/chis/internal/queue"
 	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/bilalabdelkadir/chis/pkg/helper"
 	"github.com/google/uuid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -15,15 +21,35 @@ import (
 )
 
 type ServiceRepo struct {
-	messageRepo repository.MessageRepository
-	queue       *queue.Queue
+	messageRepo      repository.MessageRepository
+	endpointRepo     repository.EndpointRepository
+	orgRepo          repository.OrganizationRepository
+	planRepo         repository.PlanRepository
+	usageRepo        repository.UsageRepository
+	queue            *queue.Queue
+	blobs            blobstore.Store
+	logStream        *logstream.Stream
+	maxPayloadBytes  int
+	offloadThreshold int
 	pb.UnimplementedDeliveryServiceServer
 }
 
-func NewDeliveryService(messageRepo repository.MessageRepository, queue *queue.Queue) *ServiceRepo {
+func NewDeliveryService(messageRepo repository.MessageRepository, endpointRepo repository.EndpointRepository,
+	orgRepo repository.OrganizationRepository, planRepo repository.PlanRepository,
+	usageRepo repository.UsageRepository, queue *queue.Queue, blobs blobstore.Store,
+	logStream *logstream.Stream, maxPayloadBytes int, offloadThreshold int,
+) *ServiceRepo {
 	return &ServiceRepo{
-		messageRepo: messageRepo,
-		queue:       queue,
+		messageRepo:      messageRepo,
+		endpointRepo:     endpointRepo,
+		orgRepo:          orgRepo,
+		planRepo:         planRepo,
+		usageRepo:        usageRepo,
+		queue:            queue,
+		blobs:            blobs,
+		logStream:        logStream,
+		maxPayloadBytes:  maxPayloadBytes,
+		offloadThreshold: offloadThreshold,
 	}
 }
 
@@ -34,23 +60,106 @@ func (s *ServiceRepo) QueueMessage(ctx context.Context, req *pb.QueueMessageRequ
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, "invalid org_id")
 	}
+	var apiKeyID *uuid.UUID
+	if req.ApiKeyId != "" {
+		id, err := uuid.Parse(req.ApiKeyId)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid api_key_id")
+		}
+		apiKeyID = &id
+	}
 	method := req.Method.String()
 
+	priority := req.Priority
+	if priority == "" {
+		priority = model.PriorityNormal
+	}
+	if priority != model.PriorityHigh && priority != model.PriorityNormal && priority != model.PriorityLow {
+		return nil, status.Error(codes.InvalidArgument, "priority must be one of: high, normal, low")
+	}
+
+	if req.IdempotencyKey != "" {
+		existing, err := s.messageRepo.FindByIdempotencyKey(ctx, orgId, req.IdempotencyKey)
+		if err == nil {
+			slog.Info("message_deduplicated", "message_id", existing.ID, "org_id", orgId)
+			return &pb.QueueMessageResponse{MessageId: existing.ID.String(), Status: existing.Status}, nil
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return nil, status.Error(codes.Internal, "failed to check idempotency key")
+		}
+	}
+
+	orgMax, err := s.orgRepo.GetMaxPayloadBytes(ctx, orgId)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to load organization limits")
+	}
+	if limit := helper.PayloadLimit(s.maxPayloadBytes, orgMax); len(req.Payload) > limit {
+		slog.Warn("message_rejected_payload_too_large", "org_id", orgId, "size", len(req.Payload), "limit", limit)
+		return nil, status.Error(codes.ResourceExhausted, fmt.Sprintf("payload exceeds maximum size of %d bytes", limit))
+	}
+
+	if err := s.checkEndpointLimit(ctx, orgId, req.Url); err != nil {
+		return nil, err
+	}
+
+	endpoint, err := s.endpointRepo.Upsert(ctx, orgId, req.Url)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to resolve endpoint")
+	}
+
 	message := &model.Message{
-		OrgID:   orgId,
-		Method:  method,
-		URL:     req.Url,
-		Payload: req.Payload,
+		OrgID:      orgId,
+		EndpointID: &endpoint.ID,
+		Method:     method,
+		URL:        req.Url,
+		Payload:    req.Payload,
+		Priority:   priority,
+		ApiKeyID:   apiKeyID,
+	}
+	if req.IdempotencyKey != "" {
+		message.IdempotencyKey = &req.IdempotencyKey
+	}
+	if req.EventType != "" {
+		message.EventType = &req.EventType
+	}
+
+	if s.blobs != nil && len(req.Payload) > s.offloadThreshold {
+		if err := s.offloadPayload(ctx, message); err != nil {
+			slog.Error("payload_offload_failed", "org_id", orgId, "size", len(req.Payload), "error", err)
+			return nil, status.Error(codes.Internal, "failed to store payload")
+		}
 	}
 
 	err = s.messageRepo.Create(ctx, message)
 	if err != nil {
+		// A concurrent request with the same key won the insert.
+		if errors.Is(err, repository.ErrDuplicate) {
+			existing, findErr := s.messageRepo.FindByIdempotencyKey(ctx, orgId, req.IdempotencyKey)
+			if findErr == nil {
+				return &pb.QueueMessageResponse{MessageId: existing.ID.String(), Status: existing.Status}, nil
+			}
+		}
 		return nil, status.Error(codes.Internal, "failed to save message")
 	}
 	slog.Info("message_saved", "message_id", message.ID, "org_id", message.OrgID)
 
-	s.queue.Push(ctx, message.ID.String())
-	slog.Info("message_queued", "message_id", message.ID, "org_id", message.OrgID)
+	if err := s.usageRepo.Record(ctx, orgId, 1, int64(len(req.Payload)), 0); err != nil {
+		slog.Error("usage_record_failed", "message_id", message.ID, "org_id", orgId, "error", err)
+	}
+
+	// Disabled endpoints keep their messages until they are re-enabled.
+	if endpoint.DisabledAt != nil {
+		if _, err := s.messageRepo.UpdateStatus(ctx, message.ID, "held"); err != nil {
+			return nil, status.Error(codes.Internal, "failed to hold message")
+		}
+		s.logStream.PublishStatus(ctx, message, "held")
+		slog.Info("message_held", "message_id", message.ID, "org_id", message.OrgID, "endpoint_id", endpoint.ID)
+		return &pb.QueueMessageResponse{MessageId: message.ID.String(), Status: "held"}, nil
+	}
+
+	s.queue.Push(ctx, message.OrgID, message.ID.String(), message.Priority)
+	s.logStream.PublishStatus(ctx, message, message.Status)
+	slog.Info("message_queued", "message_id", message.ID, "org_id", message.OrgID, "priority", message.Priority)
 
 	res := pb.QueueMessageResponse{
 		MessageId: message.ID.String(),
@@ -60,3 +169,62 @@ func (s *ServiceRepo) QueueMessage(ctx context.Context, req *pb.QueueMessageRequ
 	return &res, nil
 
 }
+
+// checkEndpointLimit stops a send to a new URL from creating an endpoint
+// past the plan's limit. URLs that already have an endpoint are always fine.
+func (s *ServiceRepo) checkEndpointLimit(ctx context.Context, orgId uuid.UUID, url string) error {
+	plan, err := s.planRepo.FindByOrgID(ctx, orgId)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to load plan")
+	}
+	if plan.MaxEndpoints == nil {
+		return nil
+	}
+
+	count, err := s.endpointRepo.CountByOrgID(ctx, orgId)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to count endpoints")
+	}
+	if count < *plan.MaxEndpoints {
+		return nil
+	}
+
+	_, err = s.endpointRepo.FindByURL(ctx, orgId, url)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return status.Error(codes.Internal, "failed to resolve endpoint")
+	}
+	return status.Error(codes.FailedPrecondition,
+		fmt.Sprintf("the %s plan allows at most %d endpoints", plan.Name, *plan.MaxEndpoints))
+}
+
+// offloadPayload moves the payload into the blob store and leaves a content
+// hash reference on the message. Identical payloads share one blob.
+func (s *ServiceRepo) offloadPayload(ctx context.Context, message *model.Message) error {
+	key := blobstore.ContentKey(message.Payload)
+	size := len(message.Payload)
+
+	// A purged message may have left this blob for the retention purge to
+	// delete. Claiming it first means the check below sees it either kept
+	// or already gone, never deleted after the message points at it.
+	if err := s.messageRepo.ClaimPayload(ctx, key); err != nil {
+		return err
+	}
+
+	exists, err := s.blobs.Exists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := s.blobs.Put(ctx, key, bytes.NewReader(message.Payload), int64(size)); err != nil {
+			return err
+		}
+	}
+
+	message.Payload = nil
+	message.PayloadRef = &key
+	message.PayloadSize = &size
+	return nil
+}
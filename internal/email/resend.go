@@ -2,6 +2,7 @@ package email
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/resend/resend-go/v2"
 )
@@ -20,23 +21,14 @@ func NewEmailService(apiKey string, appUrl string) *EmailService {
 }
 
 func (s *EmailService) SendInvitation(toEmail, orgName, inviterName, token string) error {
-	inviteLink := fmt.Sprintf("%s/invite/accept?token=%s", s.appUrl, token)
-
-	htmlBody := fmt.Sprintf(`
-		<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto; padding: 20px;">
-			<h2>You've been invited to join %s</h2>
-			<p>%s has invited you to join <strong>%s</strong> on Chis.</p>
-			<p>Click the button below to accept the invitation:</p>
-			<div style="text-align: center; margin: 30px 0;">
-				<a href="%s"
-				   style="background-color: #4F46E5; color: white; padding: 12px 24px; text-decoration: none; border-radius: 6px; font-weight: bold;">
-					Accept Invitation
-				</a>
-			</div>
-			<p style="color: #666; font-size: 14px;">This invitation will expire in 7 days.</p>
-			<p style="color: #666; font-size: 14px;">If you didn't expect this invitation, you can ignore this email.</p>
-		</div>
-	`, orgName, inviterName, orgName, inviteLink)
+	htmlBody, err := render(invitationEmail, map[string]string{
+		"OrgName":     orgName,
+		"InviterName": inviterName,
+		"Link":        fmt.Sprintf("%s/invite/accept?token=%s", s.appUrl, token),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render invitation email: %w", err)
+	}
 
 	params := &resend.SendEmailRequest{
 		From:    "Chis <noreply@trychis.com>",
@@ -45,10 +37,62 @@ func (s *EmailService) SendInvitation(toEmail, orgName, inviterName, token strin
 		Html:    htmlBody,
 	}
 
-	_, err := s.client.Emails.Send(params)
+	_, err = s.client.Emails.Send(params)
 	if err != nil {
 		return fmt.Errorf("failed to send invitation email: %w", err)
 	}
 
 	return nil
 }
+
+type DigestItem struct {
+	Title     string
+	Summary   string
+	CreatedAt time.Time
+}
+
+// Digest batches an org's notifications into one email. Items holds the
+// newest ones; Total counts all of them.
+type Digest struct {
+	FirstName string
+	OrgName   string
+	Since     time.Time
+	Items     []DigestItem
+	Total     int
+}
+
+func (s *EmailService) SendDigest(toEmail string, d *Digest) error {
+	htmlBody, err := render(digestEmail, struct {
+		*Digest
+		More            int
+		DashboardLink   string
+		PreferencesLink string
+	}{
+		Digest:          d,
+		More:            d.Total - len(d.Items),
+		DashboardLink:   s.appUrl,
+		PreferencesLink: s.appUrl + "/settings/notifications",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render digest email: %w", err)
+	}
+
+	subject := fmt.Sprintf("[Chis] %d alerts for %s", d.Total, d.OrgName)
+	if d.Total == 1 {
+		subject = fmt.Sprintf("[Chis] %s: %s", d.OrgName, d.Items[0].Title)
+	}
+
+	params := &resend.SendEmailRequest{
+		From:    "Chis <noreply@trychis.com>",
+		To:      []string{toEmail},
+		Subject: subject,
+		Html:    htmlBody,
+	}
+
+	_, err = s.client.Emails.Send(params)
+	if err != nil {
+		return fmt.Errorf("failed to send digest email: %w", err)
+	}
+
+	return nil
+}
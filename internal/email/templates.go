@@ -0,0 +1,64 @@
+package email
+
+import (
+	"bytes"
+	"html/template"
+)
+
+const layoutTemplate = `{{define "layout"}}
+		<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto; padding: 20px;">
+			{{template "content" .}}
+		</div>
+{{end}}`
+
+const invitationTemplate = `{{define "content"}}
+			<h2>You've been invited to join {{.OrgName}}</h2>
+			<p>{{.InviterName}} has invited you to join <strong>{{.OrgName}}</strong> on Chis.</p>
+			<p>Click the button below to accept the invitation:</p>
+			<div style="text-align: center; margin: 30px 0;">
+				<a href="{{.Link}}"
+				   style="background-color: #4F46E5; color: white; padding: 12px 24px; text-decoration: none; border-radius: 6px; font-weight: bold;">
+					Accept Invitation
+				</a>
+			</div>
+			<p style="color: #666; font-size: 14px;">This invitation will expire in 7 days.</p>
+			<p style="color: #666; font-size: 14px;">If you didn't expect this invitation, you can ignore this email.</p>
+{{end}}`
+
+const digestTemplate = `{{define "content"}}
+			<h2>{{.Total}} {{if eq .Total 1}}alert{{else}}alerts{{end}} for {{.OrgName}}</h2>
+			<p>Hi {{.FirstName}}, here is what happened with your webhook deliveries since {{.Since.UTC.Format "Jan 2, 15:04 MST"}}.</p>
+			<table style="width: 100%; border-collapse: collapse; margin: 20px 0;">
+				{{range .Items}}
+				<tr>
+					<td style="padding: 8px; border-bottom: 1px solid #eee; color: #666; font-size: 14px; white-space: nowrap; vertical-align: top;">{{.CreatedAt.UTC.Format "Jan 2, 15:04"}}</td>
+					<td style="padding: 8px; border-bottom: 1px solid #eee;"><strong>{{.Title}}</strong><br>{{.Summary}}</td>
+				</tr>
+				{{end}}
+			</table>
+			{{if gt .Total (len .Items)}}<p>And {{.More}} more in the dashboard.</p>{{end}}
+			<div style="text-align: center; margin: 30px 0;">
+				<a href="{{.DashboardLink}}"
+				   style="background-color: #4F46E5; color: white; padding: 12px 24px; text-decoration: none; border-radius: 6px; font-weight: bold;">
+					Open Dashboard
+				</a>
+			</div>
+			<p style="color: #666; font-size: 14px;">You get this because you are an admin of {{.OrgName}}. <a href="{{.PreferencesLink}}">Change notification settings</a>.</p>
+{{end}}`
+
+var (
+	invitationEmail = mustParse(invitationTemplate)
+	digestEmail     = mustParse(digestTemplate)
+)
+
+func mustParse(content string) *template.Template {
+	return template.Must(template.Must(template.New("layout").Parse(layoutTemplate)).Parse(content))
+}
+
+func render(t *template.Template, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
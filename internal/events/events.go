@@ -0,0 +1,107 @@
+// Package events emits system events about an org's deliveries to its
+// operational endpoints. Events are ordinary messages with event_type set,
+// so they are signed, retried and logged like any other delivery.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/queue"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	MessageAttemptExhausted = "message.attempt.exhausted"
+	EndpointDisabled        = "endpoint.disabled"
+	EndpointRecovered       = "endpoint.recovered"
+)
+
+// Event is the JSON body operational endpoints receive.
+type Event struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      any       `json:"data"`
+}
+
+type MessageAttemptExhaustedData struct {
+	MessageID      uuid.UUID  `json:"messageId"`
+	EndpointID     *uuid.UUID `json:"endpointId"`
+	URL            string     `json:"url"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode *int       `json:"lastStatusCode"`
+	LastError      *string    `json:"lastError"`
+}
+
+type EndpointDisabledData struct {
+	EndpointID          uuid.UUID  `json:"endpointId"`
+	URL                 string     `json:"url"`
+	Reason              string     `json:"reason"`
+	FailingSince        *time.Time `json:"failingSince"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+}
+
+type EndpointRecoveredData struct {
+	EndpointID   uuid.UUID  `json:"endpointId"`
+	URL          string     `json:"url"`
+	FailingSince *time.Time `json:"failingSince"`
+}
+
+type Emitter struct {
+	messageRepo  repository.MessageRepository
+	endpointRepo repository.EndpointRepository
+	queue        *queue.Queue
+}
+
+func NewEmitter(messageRepo repository.MessageRepository, endpointRepo repository.EndpointRepository, queue *queue.Queue) *Emitter {
+	return &Emitter{
+		messageRepo:  messageRepo,
+		endpointRepo: endpointRepo,
+		queue:        queue,
+	}
+}
+
+// Emit queues the event for each of the org's operational endpoints.
+// Failures are logged rather than returned: an event must never hold up
+// the delivery that caused it.
+func (e *Emitter) Emit(ctx context.Context, orgID uuid.UUID, eventType string, data any) {
+	endpoints, err := e.endpointRepo.FindOperational(ctx, orgID)
+	if err != nil {
+		slog.Error("event_endpoints_lookup_failed", "org_id", orgID, "event_type", eventType, "error", err)
+		return
+	}
+	if len(endpoints) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(Event{Type: eventType, Timestamp: time.Now().UTC(), Data: data})
+	if err != nil {
+		slog.Error("event_encode_failed", "org_id", orgID, "event_type", eventType, "error", err)
+		return
+	}
+
+	for _, endpoint := range endpoints {
+		msg := &model.Message{
+			OrgID:      orgID,
+			EndpointID: &endpoint.ID,
+			Method:     http.MethodPost,
+			URL:        endpoint.URL,
+			Payload:    payload,
+			EventType:  &eventType,
+		}
+		if err := e.messageRepo.Create(ctx, msg); err != nil {
+			slog.Error("event_save_failed", "org_id", orgID, "endpoint_id", endpoint.ID, "event_type", eventType, "error", err)
+			continue
+		}
+		if err := e.queue.Push(ctx, orgID, msg.ID.String(), msg.Priority); err != nil {
+			slog.Error("event_queue_failed", "message_id", msg.ID, "event_type", eventType, "error", err)
+			continue
+		}
+		slog.Info("event_queued", "message_id", msg.ID, "org_id", orgID, "endpoint_id", endpoint.ID, "event_type", eventType)
+	}
+}
@@ -0,0 +1,248 @@
+// Package export writes an org's messages and their delivery attempts as
+// CSV or NDJSON, for support and compliance requests. Messages are streamed
+// from the database in batches and written as they arrive, so an export of
+// any size runs in constant memory.
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/blobstore"
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ContentType returns the media type of format.
+func ContentType(format string) string {
+	if format == model.ExportFormatCSV {
+		return "text/csv"
+	}
+	return "application/x-ndjson"
+}
+
+// Record is how a message is written to NDJSON exports, one per line.
+type Record struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	Status         string          `json:"status"`
+	Priority       string          `json:"priority"`
+	Method         string          `json:"method"`
+	URL            string          `json:"url"`
+	EndpointID     *uuid.UUID      `json:"endpointId"`
+	EventType      *string         `json:"eventType"`
+	ApiKeyID       *uuid.UUID      `json:"apiKeyId"`
+	IdempotencyKey *string         `json:"idempotencyKey"`
+	ReplayedFrom   *uuid.UUID      `json:"replayedFrom"`
+	AttemptCount   int             `json:"attemptCount"`
+	Payload        json.RawMessage `json:"payload"`
+	// PayloadRef is set when the payload is offloaded and could not be
+	// read from the blob store.
+	PayloadRef *string   `json:"payloadRef,omitempty"`
+	Attempts   []Attempt `json:"attempts"`
+}
+
+type Attempt struct {
+	AttemptNumber     int       `json:"attemptNumber"`
+	StatusCode        *int      `json:"statusCode"`
+	DurationMS        *int      `json:"durationMs"`
+	ErrorMessage      *string   `json:"errorMessage"`
+	ResponseBody      *string   `json:"responseBody"`
+	ResponseTruncated bool      `json:"responseTruncated"`
+	AttemptedAt       time.Time `json:"attemptedAt"`
+}
+
+// csvHeader lists the CSV columns. CSV exports have a row per attempt, with
+// the message's columns repeated, and a row with empty attempt columns for
+// messages that have none.
+var csvHeader = []string{
+	"message_id", "created_at", "status", "priority", "method", "url", "endpoint_id", "event_type",
+	"api_key_id", "idempotency_key", "replayed_from", "attempt_count", "payload",
+	"attempt_number", "status_code", "duration_ms", "error_message", "response_body", "response_truncated", "attempted_at",
+}
+
+type Exporter struct {
+	exportRepo repository.ExportRepository
+	orgRepo    repository.OrganizationRepository
+	// blobs reads offloaded payloads; nil when blob storage is disabled.
+	blobs blobstore.Store
+}
+
+func NewExporter(exportRepo repository.ExportRepository, orgRepo repository.OrganizationRepository, blobs blobstore.Store) *Exporter {
+	return &Exporter{
+		exportRepo: exportRepo,
+		orgRepo:    orgRepo,
+		blobs:      blobs,
+	}
+}
+
+// Write writes the messages the filter selects to w in format, oldest
+// first, with the org's redaction paths applied to payloads and JSON
+// response bodies. It returns how many messages were written.
+func (e *Exporter) Write(ctx context.Context, w io.Writer, format string, f repository.ExportFilter) (int64, error) {
+	paths, err := e.orgRepo.GetRedactPaths(ctx, f.OrgID)
+	if err != nil {
+		return 0, fmt.Errorf("load redaction paths: %w", err)
+	}
+
+	var (
+		write func(*Record) error
+		flush func() error
+	)
+	switch format {
+	case model.ExportFormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return 0, err
+		}
+		write = func(rec *Record) error { return writeCSV(cw, rec) }
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case model.ExportFormatNDJSON:
+		enc := json.NewEncoder(w)
+		write = func(rec *Record) error { return enc.Encode(rec) }
+		flush = func() error { return nil }
+	default:
+		return 0, fmt.Errorf("unknown export format: %s", format)
+	}
+
+	n, err := e.exportRepo.Stream(ctx, f, func(r *repository.ExportRecord) error {
+		return write(e.record(ctx, r, paths))
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, flush()
+}
+
+func (e *Exporter) record(ctx context.Context, r *repository.ExportRecord, paths []string) *Record {
+	msg := r.Message
+	rec := &Record{
+		ID:             msg.ID,
+		CreatedAt:      msg.CreatedAt,
+		Status:         msg.Status,
+		Priority:       msg.Priority,
+		Method:         msg.Method,
+		URL:            msg.URL,
+		EndpointID:     msg.EndpointID,
+		EventType:      msg.EventType,
+		ApiKeyID:       msg.ApiKeyID,
+		IdempotencyKey: msg.IdempotencyKey,
+		ReplayedFrom:   msg.ReplayedFrom,
+		AttemptCount:   msg.AttemptCount,
+		Payload:        msg.Payload,
+		Attempts:       make([]Attempt, len(r.Attempts)),
+	}
+	if msg.PayloadRef != nil {
+		payload, err := e.loadPayload(ctx, *msg.PayloadRef)
+		if err != nil {
+			slog.Warn("export_payload_unavailable", "message_id", msg.ID, "payload_ref", *msg.PayloadRef, "error", err)
+			rec.PayloadRef = msg.PayloadRef
+		}
+		rec.Payload = payload
+	}
+	if payload, ok := Redact(rec.Payload, paths); ok {
+		rec.Payload = payload
+	} else {
+		rec.Payload = redactedJSON
+	}
+
+	for i, a := range r.Attempts {
+		body := a.ResponseBody
+		if body != nil && len(paths) > 0 {
+			redacted := Redacted
+			if out, ok := Redact([]byte(*body), paths); ok {
+				redacted = string(out)
+			}
+			body = &redacted
+		}
+		rec.Attempts[i] = Attempt{
+			AttemptNumber:     a.AttemptNumber,
+			StatusCode:        a.StatusCode,
+			DurationMS:        a.DurationMS,
+			ErrorMessage:      a.ErrorMessage,
+			ResponseBody:      body,
+			ResponseTruncated: a.ResponseTruncated,
+			AttemptedAt:       a.AttemptedAt,
+		}
+	}
+	return rec
+}
+
+func (e *Exporter) loadPayload(ctx context.Context, ref string) (json.RawMessage, error) {
+	if e.blobs == nil {
+		return nil, fmt.Errorf("blob storage is disabled")
+	}
+	rc, err := e.blobs.Get(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func writeCSV(cw *csv.Writer, rec *Record) error {
+	message := []string{
+		rec.ID.String(),
+		rec.CreatedAt.UTC().Format(time.RFC3339Nano),
+		rec.Status,
+		rec.Priority,
+		rec.Method,
+		rec.URL,
+		uuidString(rec.EndpointID),
+		deref(rec.EventType),
+		uuidString(rec.ApiKeyID),
+		deref(rec.IdempotencyKey),
+		uuidString(rec.ReplayedFrom),
+		strconv.Itoa(rec.AttemptCount),
+		string(rec.Payload),
+	}
+	if len(rec.Attempts) == 0 {
+		return cw.Write(append(message, "", "", "", "", "", "", ""))
+	}
+	for _, a := range rec.Attempts {
+		row := append(message[:len(message):len(message)],
+			strconv.Itoa(a.AttemptNumber),
+			intString(a.StatusCode),
+			intString(a.DurationMS),
+			deref(a.ErrorMessage),
+			deref(a.ResponseBody),
+			strconv.FormatBool(a.ResponseTruncated),
+			a.AttemptedAt.UTC().Format(time.RFC3339Nano),
+		)
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func intString(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+func uuidString(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
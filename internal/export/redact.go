@@ -0,0 +1,96 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Redacted replaces the values redaction paths name, and whole documents
+// that can't be searched for them.
+const Redacted = "[REDACTED]"
+
+// redactedJSON stands in for a payload that can't be redacted.
+var redactedJSON = json.RawMessage(`"` + Redacted + `"`)
+
+const (
+	MaxRedactPaths      = 50
+	maxRedactPathLength = 200
+)
+
+// ValidatePath checks a redaction path: dot-separated object keys, such as
+// customer.email.
+func ValidatePath(path string) error {
+	if path == "" || len(path) > maxRedactPathLength {
+		return fmt.Errorf("redaction paths must be 1 to %d characters", maxRedactPathLength)
+	}
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			return fmt.Errorf("invalid redaction path %q", path)
+		}
+	}
+	return nil
+}
+
+// Redact returns doc with the value at each path replaced with Redacted.
+// Arrays along a path have it applied to each of their elements. doc is
+// returned unchanged when nothing matched. It reports false when there are
+// paths but doc is not a single JSON value, such as a truncated response
+// body: the values to hide can't be found, so doc must not be exported.
+func Redact(doc []byte, paths []string) ([]byte, bool) {
+	if len(paths) == 0 || len(doc) == 0 {
+		return doc, true
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, false
+	}
+
+	redacted := false
+	for _, path := range paths {
+		if redact(v, strings.Split(path, ".")) {
+			redacted = true
+		}
+	}
+	if !redacted {
+		return doc, true
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+func redact(v any, keys []string) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		child, ok := v[keys[0]]
+		if !ok {
+			return false
+		}
+		if len(keys) == 1 {
+			v[keys[0]] = Redacted
+			return true
+		}
+		return redact(child, keys[1:])
+	case []any:
+		redacted := false
+		for _, elem := range v {
+			if redact(elem, keys) {
+				redacted = true
+			}
+		}
+		return redacted
+	}
+	return false
+}
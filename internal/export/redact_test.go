@@ -0,0 +1,41 @@
+package export
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	paths := []string{"customer.email", "cards.number"}
+	tests := []struct {
+		name   string
+		doc    string
+		paths  []string
+		want   string
+		wantOK bool
+	}{
+		{"no paths", `not json`, nil, `not json`, true},
+		{"empty", ``, paths, ``, true},
+		{"nested", `{"customer":{"email":"a@b.c","id":1}}`, paths,
+			`{"customer":{"email":"[REDACTED]","id":1}}`, true},
+		{"arrays", `{"cards":[{"number":"4242"},{"number":"5555"},{"brand":"x"}]}`, paths,
+			`{"cards":[{"number":"[REDACTED]"},{"number":"[REDACTED]"},{"brand":"x"}]}`, true},
+		{"whole object", `{"customer":{"email":"a@b.c"}}`, []string{"customer"}, `{"customer":"[REDACTED]"}`, true},
+		{"no match is left as it was", `{"id": 12345678901234567890}`, paths, `{"id": 12345678901234567890}`, true},
+		{"numbers keep their precision", `{"customer":{"email":"x"},"id":12345678901234567890}`, paths,
+			`{"customer":{"email":"[REDACTED]"},"id":12345678901234567890}`, true},
+		{"trailing space", `{"customer":{"email":"x"}}` + "\n", paths, `{"customer":{"email":"[REDACTED]"}}`, true},
+		{"not json", `email=a@b.c`, paths, ``, false},
+		{"truncated", `{"customer":{"email":"a@b.c","na`, paths, ``, false},
+		{"trailing data", `{"id":1} {"customer":{"email":"a@b.c"}}`, paths, ``, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Redact([]byte(tt.doc), tt.paths)
+			if ok != tt.wantOK {
+				t.Fatalf("Redact(%q) ok = %t, want %t", tt.doc, ok, tt.wantOK)
+			}
+			if ok && string(got) != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.doc, got, tt.want)
+			}
+		})
+	}
+}
@@ -16,13 +16,16 @@ import (
 
 type ApiKeyHandler struct {
 	apiKeyRepo repository.ApiKeyRepository
+	planRepo   repository.PlanRepository
 }
 
 func NewApiKeyHandler(
 	apiKeyRepo repository.ApiKeyRepository,
+	planRepo repository.PlanRepository,
 ) *ApiKeyHandler {
 	return &ApiKeyHandler{
 		apiKeyRepo: apiKeyRepo,
+		planRepo:   planRepo,
 	}
 }
 
@@ -60,6 +63,18 @@ func (h *ApiKeyHandler) Create(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	plan, err := h.planRepo.FindByOrgID(r.Context(), orgID)
+	if err != nil {
+		return apperror.Internal("failed to load plan")
+	}
+	count, err := h.apiKeyRepo.CountByOrgID(r.Context(), orgID)
+	if err != nil {
+		return apperror.Internal("failed to count api keys")
+	}
+	if err := checkPlanLimit(plan, plan.MaxApiKeys, count, "API keys"); err != nil {
+		return err
+	}
+
 	fullApiKey, err := helper.GenerateRandomApiKey("chis_sk_")
 	if err != nil {
 		return apperror.Internal("couldn't generate api key")
@@ -104,13 +119,17 @@ func (h *ApiKeyHandler) List(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	keys, err := h.apiKeyRepo.FindByOrgID(r.Context(), orgID)
+	page, err := parsePageRequest(r)
+	if err != nil {
+		return err
+	}
+
+	keys, err := h.apiKeyRepo.FindByOrgID(r.Context(), orgID, page)
 	if err != nil {
 		return apperror.Internal("failed to fetch api keys")
 	}
 
-	items := make([]ListApiKeyItem, 0, len(keys))
-	for _, k := range keys {
+	items := mapPage(keys, func(k *model.APIKey) ListApiKeyItem {
 		item := ListApiKeyItem{
 			ID:        k.ID.String(),
 			Name:      k.Name,
@@ -121,8 +140,8 @@ func (h *ApiKeyHandler) List(w http.ResponseWriter, r *http.Request) error {
 			formatted := k.LastUsedAt.Format(time.RFC3339)
 			item.LastUsedAt = &formatted
 		}
-		items = append(items, item)
-	}
+		return item
+	})
 
 	response.WriteJSON(w, http.StatusOK, items)
 	return nil
@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"context"
 	"net/http"
-	"strconv"
 
 	"github.com/bilalabdelkadir/chis/internal/middleware"
+	"github.com/bilalabdelkadir/chis/internal/model"
 	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/bilalabdelkadir/chis/internal/search"
 	"github.com/bilalabdelkadir/chis/pkg/apperror"
 	"github.com/bilalabdelkadir/chis/pkg/response"
 	"github.com/go-chi/chi/v5"
@@ -15,15 +17,18 @@ import (
 type DashboardHandler struct {
 	messageRepo         repository.MessageRepository
 	deliveryAttemptRepo repository.DeliveryAttemptRepository
+	analyticsRepo       repository.AnalyticsRepository
 }
 
 func NewDashboardHandler(
 	messageRepo repository.MessageRepository,
 	deliveryAttemptRepo repository.DeliveryAttemptRepository,
+	analyticsRepo repository.AnalyticsRepository,
 ) *DashboardHandler {
 	return &DashboardHandler{
 		messageRepo:         messageRepo,
 		deliveryAttemptRepo: deliveryAttemptRepo,
+		analyticsRepo:       analyticsRepo,
 	}
 }
 
@@ -42,6 +47,14 @@ func (h *DashboardHandler) Stats(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// WebhookLogsResponse is a page of webhook logs. PayloadSearchIncomplete is
+// set when the query searched payloads and some of the org's payloads are
+// in the blob store, which payload searches don't look into.
+type WebhookLogsResponse struct {
+	*repository.Page[repository.WebhookLogEntry]
+	PayloadSearchIncomplete bool `json:"payloadSearchIncomplete,omitempty"`
+}
+
 func (h *DashboardHandler) WebhookLogs(w http.ResponseWriter, r *http.Request) error {
 	orgID, err := extractOrgID(r)
 	if err != nil {
@@ -50,28 +63,33 @@ func (h *DashboardHandler) WebhookLogs(w http.ResponseWriter, r *http.Request) e
 
 	query := r.URL.Query()
 	status := query.Get("status")
-	search := query.Get("search")
+	urlSearch := query.Get("search")
 
-	page := 1
-	if v := query.Get("page"); v != "" {
-		if p, err := strconv.Atoi(v); err == nil && p > 0 {
-			page = p
-		}
+	filter, err := search.Parse(query.Get("q"), repository.WebhookLogSchema)
+	if err != nil {
+		return apperror.BadRequest(err.Error())
 	}
 
-	limit := 20
-	if v := query.Get("limit"); v != "" {
-		if l, err := strconv.Atoi(v); err == nil && l > 0 && l <= 100 {
-			limit = l
-		}
+	page, err := parsePageRequest(r)
+	if err != nil {
+		return err
 	}
 
-	result, err := h.messageRepo.FindWebhookLogs(r.Context(), orgID, status, search, page, limit)
+	result, err := h.messageRepo.FindWebhookLogs(r.Context(), orgID, status, urlSearch, filter, page)
 	if err != nil {
 		return apperror.Internal("failed to fetch webhook logs")
 	}
 
-	response.WriteJSON(w, http.StatusOK, result)
+	res := WebhookLogsResponse{Page: result}
+	if filter.References(repository.WebhookLogSchema.Payload) {
+		offloaded, err := h.messageRepo.HasOffloadedPayloads(r.Context(), orgID)
+		if err != nil {
+			return apperror.Internal("failed to fetch webhook logs")
+		}
+		res.PayloadSearchIncomplete = offloaded
+	}
+
+	response.WriteJSON(w, http.StatusOK, res)
 	return nil
 }
 
@@ -87,31 +105,69 @@ func (h *DashboardHandler) WebhookLogDetail(w http.ResponseWriter, r *http.Reque
 		return apperror.BadRequest("invalid log ID")
 	}
 
-	msg, err := h.messageRepo.FindById(r.Context(), msgID)
+	detail, err := webhookLogDetail(r.Context(), h.messageRepo, h.deliveryAttemptRepo, orgID, msgID)
 	if err != nil {
-		return apperror.NotFound("webhook log not found")
+		return err
 	}
 
-	if msg.OrgID != orgID {
+	response.WriteJSON(w, http.StatusOK, detail)
+	return nil
+}
+
+// WebhookLogAttempts pages through a message's delivery attempts, oldest
+// first, for messages with more attempts than the detail view shows at once.
+func (h *DashboardHandler) WebhookLogAttempts(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	msgID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return apperror.BadRequest("invalid log ID")
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		return err
+	}
+
+	msg, err := h.messageRepo.FindById(r.Context(), msgID)
+	if err != nil || msg.OrgID != orgID {
 		return apperror.NotFound("webhook log not found")
 	}
 
-	attempts, err := h.deliveryAttemptRepo.FindByMessageID(r.Context(), msgID)
+	attempts, err := h.deliveryAttemptRepo.ListByMessageID(r.Context(), msgID, page)
 	if err != nil {
 		return apperror.Internal("failed to fetch delivery attempts")
 	}
 
+	response.WriteJSON(w, http.StatusOK, mapPage(attempts, deliveryAttemptDetail))
+	return nil
+}
+
+// webhookLogDetail loads a message of the org together with its delivery
+// attempts.
+func webhookLogDetail(ctx context.Context, messageRepo repository.MessageRepository,
+	deliveryAttemptRepo repository.DeliveryAttemptRepository, orgID, msgID uuid.UUID,
+) (*repository.WebhookLogDetail, error) {
+	msg, err := messageRepo.FindById(ctx, msgID)
+	if err != nil {
+		return nil, apperror.NotFound("webhook log not found")
+	}
+
+	if msg.OrgID != orgID {
+		return nil, apperror.NotFound("webhook log not found")
+	}
+
+	attempts, err := deliveryAttemptRepo.FindByMessageID(ctx, msgID)
+	if err != nil {
+		return nil, apperror.Internal("failed to fetch delivery attempts")
+	}
+
 	attemptDetails := make([]repository.DeliveryAttemptDetail, len(attempts))
 	for i, a := range attempts {
-		attemptDetails[i] = repository.DeliveryAttemptDetail{
-			ID:            a.ID,
-			AttemptNumber: a.AttemptNumber,
-			StatusCode:    a.StatusCode,
-			ResponseBody:  a.ResponseBody,
-			ErrorMessage:  a.ErrorMessage,
-			DurationMS:    a.DurationMS,
-			AttemptedAt:   a.AttemptedAt.Format("2006-01-02T15:04:05Z"),
-		}
+		attemptDetails[i] = deliveryAttemptDetail(a)
 	}
 
 	var nextRetry *string
@@ -120,21 +176,36 @@ func (h *DashboardHandler) WebhookLogDetail(w http.ResponseWriter, r *http.Reque
 		nextRetry = &t
 	}
 
-	detail := repository.WebhookLogDetail{
+	return &repository.WebhookLogDetail{
 		ID:               msg.ID,
 		Method:           msg.Method,
 		URL:              msg.URL,
 		Status:           msg.Status,
+		Priority:         msg.Priority,
 		Payload:          msg.Payload,
+		PayloadRef:       msg.PayloadRef,
+		IdempotencyKey:   msg.IdempotencyKey,
+		ReplayedFrom:     msg.ReplayedFrom,
+		ApiKeyID:         msg.ApiKeyID,
 		AttemptCount:     msg.AttemptCount,
 		CreatedAt:        msg.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		UpdatedAt:        msg.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 		NextRetryAt:      nextRetry,
 		DeliveryAttempts: attemptDetails,
-	}
+	}, nil
+}
 
-	response.WriteJSON(w, http.StatusOK, detail)
-	return nil
+func deliveryAttemptDetail(a *model.DeliveryAttempt) repository.DeliveryAttemptDetail {
+	return repository.DeliveryAttemptDetail{
+		ID:                a.ID,
+		AttemptNumber:     a.AttemptNumber,
+		StatusCode:        a.StatusCode,
+		ResponseBody:      a.ResponseBody,
+		ResponseTruncated: a.ResponseTruncated,
+		ErrorMessage:      a.ErrorMessage,
+		DurationMS:        a.DurationMS,
+		AttemptedAt:       a.AttemptedAt.Format("2006-01-02T15:04:05Z"),
+	}
 }
 
 func extractUserID(r *http.Request) (uuid.UUID, error) {
@@ -149,6 +220,16 @@ func extractUserID(r *http.Request) (uuid.UUID, error) {
 	return userID, nil
 }
 
+// apiKeyIDFromContext returns the API key the request was made with, or nil
+// for dashboard requests.
+func apiKeyIDFromContext(r *http.Request) *uuid.UUID {
+	id, ok := r.Context().Value(middleware.ApiKeyIDKey).(uuid.UUID)
+	if !ok {
+		return nil
+	}
+	return &id
+}
+
 func extractOrgID(r *http.Request) (uuid.UUID, error) {
 	val := r.Context().Value(middleware.OrgIDKey)
 	if val == nil {
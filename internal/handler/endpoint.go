@@ -0,0 +1,467 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/logstream"
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/bilalabdelkadir/chis/pkg/apperror"
+	"github.com/bilalabdelkadir/chis/pkg/helper"
+	"github.com/bilalabdelkadir/chis/pkg/response"
+	"github.com/bilalabdelkadir/chis/pkg/validator"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type EndpointHandler struct {
+	endpointRepo      repository.EndpointRepository
+	organizationRepo  repository.OrganizationRepository
+	messageRepo       repository.MessageRepository
+	planRepo          repository.PlanRepository
+	membershipRepo    repository.MembershipRepository
+	logStream         *logstream.Stream
+	secretGracePeriod time.Duration
+	minTimeout        time.Duration
+	maxTimeout        time.Duration
+}
+
+// healthWindow is how far back the success rate in Health looks.
+const healthWindow = 24 * time.Hour
+
+func NewEndpointHandler(
+	endpointRepo repository.EndpointRepository,
+	organizationRepo repository.OrganizationRepository,
+	messageRepo repository.MessageRepository,
+	planRepo repository.PlanRepository,
+	membershipRepo repository.MembershipRepository,
+	logStream *logstream.Stream,
+	secretGracePeriod time.Duration,
+	minTimeout, maxTimeout time.Duration,
+) *EndpointHandler {
+	return &EndpointHandler{
+		endpointRepo:      endpointRepo,
+		organizationRepo:  organizationRepo,
+		messageRepo:       messageRepo,
+		planRepo:          planRepo,
+		membershipRepo:    membershipRepo,
+		logStream:         logStream,
+		secretGracePeriod: secretGracePeriod,
+		minTimeout:        minTimeout,
+		maxTimeout:        maxTimeout,
+	}
+}
+
+type CreateEndpointRequest struct {
+	URL             string `json:"url" validate:"required,url"`
+	ContentEncoding string `json:"contentEncoding" validate:"omitempty,oneof=identity gzip zstd"`
+	Relay           bool   `json:"relay"`
+	Operational     bool   `json:"operational"`
+	TimeoutMS       *int   `json:"timeoutMs"`
+}
+
+type UpdateEndpointRequest struct {
+	ContentEncoding string `json:"contentEncoding" validate:"omitempty,oneof=identity gzip zstd"`
+	Relay           *bool  `json:"relay"`
+	Operational     *bool  `json:"operational"`
+	// TimeoutMS of 0 goes back to the platform default.
+	TimeoutMS *int `json:"timeoutMs"`
+}
+
+type EnableEndpointRequest struct {
+	// ReplayHeld retries messages held while the endpoint was disabled;
+	// otherwise they are marked failed.
+	ReplayHeld bool `json:"replayHeld"`
+}
+
+type EnableEndpointResponse struct {
+	Endpoint     *model.Endpoint `json:"endpoint"`
+	HeldMessages int64           `json:"heldMessages"`
+	Replayed     bool            `json:"replayed"`
+}
+
+func (h *EndpointHandler) Create(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	var req CreateEndpointRequest
+	if err := validator.DecodeAndValidate(r, &req); err != nil {
+		return err
+	}
+
+	if req.Relay || req.Operational {
+		if err := h.requireAdmin(r, orgID); err != nil {
+			return err
+		}
+	}
+
+	encoding := req.ContentEncoding
+	if encoding == "" {
+		encoding = helper.EncodingIdentity
+	}
+
+	if req.TimeoutMS != nil {
+		if err := h.checkTimeout(*req.TimeoutMS); err != nil {
+			return err
+		}
+	}
+
+	plan, err := h.planRepo.FindByOrgID(r.Context(), orgID)
+	if err != nil {
+		return apperror.Internal("failed to load plan")
+	}
+	count, err := h.endpointRepo.CountByOrgID(r.Context(), orgID)
+	if err != nil {
+		return apperror.Internal("failed to count endpoints")
+	}
+	if err := checkPlanLimit(plan, plan.MaxEndpoints, count, "endpoints"); err != nil {
+		return err
+	}
+
+	endpoint := &model.Endpoint{
+		OrgID:           orgID,
+		URL:             req.URL,
+		ContentEncoding: encoding,
+		Relay:           req.Relay,
+		Operational:     req.Operational,
+		TimeoutMS:       req.TimeoutMS,
+	}
+
+	if err := h.endpointRepo.Create(r.Context(), endpoint); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return apperror.Conflict("endpoint already exists")
+		}
+		return apperror.Internal("failed to create endpoint")
+	}
+
+	response.WriteJSON(w, http.StatusCreated, endpoint)
+	return nil
+}
+
+func (h *EndpointHandler) List(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	endpoints, err := h.endpointRepo.FindByOrgID(r.Context(), orgID)
+	if err != nil {
+		return apperror.Internal("failed to fetch endpoints")
+	}
+
+	if endpoints == nil {
+		endpoints = []*model.Endpoint{}
+	}
+
+	response.WriteJSON(w, http.StatusOK, endpoints)
+	return nil
+}
+
+func (h *EndpointHandler) Get(w http.ResponseWriter, r *http.Request) error {
+	endpoint, err := h.findOrgEndpoint(r)
+	if err != nil {
+		return err
+	}
+
+	response.WriteJSON(w, http.StatusOK, endpoint)
+	return nil
+}
+
+func (h *EndpointHandler) Update(w http.ResponseWriter, r *http.Request) error {
+	endpoint, err := h.findOrgEndpoint(r)
+	if err != nil {
+		return err
+	}
+
+	var req UpdateEndpointRequest
+	if err := validator.DecodeAndValidate(r, &req); err != nil {
+		return err
+	}
+
+	if req.ContentEncoding == "" && req.Relay == nil && req.Operational == nil && req.TimeoutMS == nil {
+		return apperror.BadRequest("nothing to update")
+	}
+	if req.Relay != nil || req.Operational != nil {
+		if err := h.requireAdmin(r, endpoint.OrgID); err != nil {
+			return err
+		}
+	}
+	if req.ContentEncoding != "" {
+		endpoint.ContentEncoding = req.ContentEncoding
+	}
+	if req.Relay != nil {
+		endpoint.Relay = *req.Relay
+	}
+	if req.Operational != nil {
+		endpoint.Operational = *req.Operational
+	}
+	if req.TimeoutMS != nil {
+		if *req.TimeoutMS == 0 {
+			endpoint.TimeoutMS = nil
+		} else {
+			if err := h.checkTimeout(*req.TimeoutMS); err != nil {
+				return err
+			}
+			endpoint.TimeoutMS = req.TimeoutMS
+		}
+	}
+
+	if err := h.endpointRepo.Update(r.Context(), endpoint); err != nil {
+		return apperror.Internal("failed to update endpoint")
+	}
+
+	response.WriteJSON(w, http.StatusOK, endpoint)
+	return nil
+}
+
+// requireAdmin guards the relay and operational flags. Relay hands the
+// endpoint's deliveries to whoever runs chis listen and operational
+// endpoints receive events about every delivery in the org, so neither may
+// be set by members or API keys.
+func (h *EndpointHandler) requireAdmin(r *http.Request, orgID uuid.UUID) error {
+	if apiKeyIDFromContext(r) != nil {
+		return apperror.Forbidden("relay and operational can only be changed by an org admin")
+	}
+	userID, err := extractUserID(r)
+	if err != nil {
+		return err
+	}
+	membership, err := h.membershipRepo.FindByUserAndOrgID(r.Context(), userID, orgID)
+	if err != nil {
+		return apperror.Forbidden("access denied")
+	}
+	if membership.Role != "admin" {
+		return apperror.Forbidden("admin access required to change relay or operational")
+	}
+	return nil
+}
+
+func (h *EndpointHandler) Delete(w http.ResponseWriter, r *http.Request) error {
+	endpoint, err := h.findOrgEndpoint(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.endpointRepo.Delete(r.Context(), endpoint.ID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.NotFound("endpoint not found")
+		}
+		return apperror.Internal("failed to delete endpoint")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Health reports the endpoint's recent success rate and failure streak.
+func (h *EndpointHandler) Health(w http.ResponseWriter, r *http.Request) error {
+	endpoint, err := h.findOrgEndpoint(r)
+	if err != nil {
+		return err
+	}
+
+	health, err := h.endpointRepo.GetHealth(r.Context(), endpoint.ID, time.Now().Add(-healthWindow))
+	if err != nil {
+		return apperror.Internal("failed to fetch endpoint health")
+	}
+
+	response.WriteJSON(w, http.StatusOK, health)
+	return nil
+}
+
+// Disable stops deliveries to the endpoint and holds its undelivered
+// messages until it is enabled again.
+func (h *EndpointHandler) Disable(w http.ResponseWriter, r *http.Request) error {
+	endpoint, err := h.findOrgEndpoint(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.endpointRepo.Disable(r.Context(), endpoint.ID, "disabled manually"); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.Conflict("endpoint is already disabled")
+		}
+		return apperror.Internal("failed to disable endpoint")
+	}
+	held, err := h.messageRepo.HoldByEndpoint(r.Context(), endpoint.ID)
+	if err != nil {
+		return apperror.Internal("failed to hold messages")
+	}
+	h.publish(r, held)
+
+	endpoint, err = h.endpointRepo.FindByID(r.Context(), endpoint.ID)
+	if err != nil {
+		return apperror.Internal("failed to fetch endpoint")
+	}
+
+	response.WriteJSON(w, http.StatusOK, endpoint)
+	return nil
+}
+
+// Enable resumes deliveries to a disabled endpoint and either replays or
+// fails the messages held in the meantime.
+func (h *EndpointHandler) Enable(w http.ResponseWriter, r *http.Request) error {
+	endpoint, err := h.findOrgEndpoint(r)
+	if err != nil {
+		return err
+	}
+	if endpoint.DisabledAt == nil {
+		return apperror.Conflict("endpoint is not disabled")
+	}
+
+	var req EnableEndpointRequest
+	if r.ContentLength != 0 {
+		if err := validator.DecodeAndValidate(r, &req); err != nil {
+			return err
+		}
+	}
+
+	if err := h.endpointRepo.Enable(r.Context(), endpoint.ID); err != nil {
+		return apperror.Internal("failed to enable endpoint")
+	}
+
+	var held []*model.Message
+	if req.ReplayHeld {
+		held, err = h.messageRepo.ReleaseHeld(r.Context(), endpoint.ID)
+	} else {
+		held, err = h.messageRepo.FailHeld(r.Context(), endpoint.ID)
+	}
+	if err != nil {
+		return apperror.Internal("failed to release held messages")
+	}
+	h.publish(r, held)
+
+	endpoint, err = h.endpointRepo.FindByID(r.Context(), endpoint.ID)
+	if err != nil {
+		return apperror.Internal("failed to fetch endpoint")
+	}
+
+	response.WriteJSON(w, http.StatusOK, EnableEndpointResponse{
+		Endpoint:     endpoint,
+		HeldMessages: int64(len(held)),
+		Replayed:     req.ReplayHeld,
+	})
+	return nil
+}
+
+// publish sends the new status of each message to the live log stream.
+func (h *EndpointHandler) publish(r *http.Request, messages []*model.Message) {
+	for _, msg := range messages {
+		h.logStream.PublishStatus(r.Context(), msg, msg.Status)
+	}
+}
+
+// GetSigningSecret returns the secret deliveries to this endpoint are signed
+// with, falling back to the org secret until the endpoint is rotated.
+func (h *EndpointHandler) GetSigningSecret(w http.ResponseWriter, r *http.Request) error {
+	endpoint, err := h.findOrgEndpoint(r)
+	if err != nil {
+		return err
+	}
+
+	if endpoint.SigningSecret != nil {
+		res := signingSecretResponse(*endpoint.SigningSecret, endpoint.PreviousSecretExpiresAt)
+		res.Source = "endpoint"
+		response.WriteJSON(w, http.StatusOK, res)
+		return nil
+	}
+
+	org, err := h.organizationRepo.FindByID(r.Context(), endpoint.OrgID)
+	if err != nil {
+		return apperror.Internal("failed to fetch signing secret")
+	}
+
+	res := signingSecretResponse(org.SigningSecret, org.PreviousSecretExpiresAt)
+	res.Source = "organization"
+	response.WriteJSON(w, http.StatusOK, res)
+	return nil
+}
+
+// RotateSigningSecret gives the endpoint its own secret. Rotating other
+// endpoints or the org secret is unaffected.
+func (h *EndpointHandler) RotateSigningSecret(w http.ResponseWriter, r *http.Request) error {
+	endpoint, err := h.findOrgEndpoint(r)
+	if err != nil {
+		return err
+	}
+
+	previousExpiresAt, err := previousSecretExpiry(r, h.secretGracePeriod)
+	if err != nil {
+		return err
+	}
+
+	newSecret, err := helper.GenerateSigningSecret()
+	if err != nil {
+		return apperror.Internal("failed to generate signing secret")
+	}
+
+	if err := h.endpointRepo.RotateSigningSecret(r.Context(), endpoint.ID, newSecret, previousExpiresAt); err != nil {
+		return apperror.Internal("failed to rotate signing secret")
+	}
+
+	res := signingSecretResponse(newSecret, previousExpiresAt)
+	res.Source = "endpoint"
+	response.WriteJSON(w, http.StatusOK, res)
+	return nil
+}
+
+func (h *EndpointHandler) ExpirePreviousSigningSecret(w http.ResponseWriter, r *http.Request) error {
+	endpoint, err := h.findOrgEndpoint(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.endpointRepo.ExpirePreviousSigningSecret(r.Context(), endpoint.ID); err != nil {
+		return apperror.Internal("failed to expire previous signing secret")
+	}
+
+	response.WriteJSON(w, http.StatusOK, map[string]string{"message": "previous signing secret expired"})
+	return nil
+}
+
+// findOrgEndpoint loads the endpoint named in the URL and makes sure it
+// belongs to the org in context.
+func (h *EndpointHandler) findOrgEndpoint(r *http.Request) (*model.Endpoint, error) {
+	return findOrgEndpoint(r, h.endpointRepo)
+}
+
+func findOrgEndpoint(r *http.Request, endpointRepo repository.EndpointRepository) (*model.Endpoint, error) {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	endpointID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return nil, apperror.BadRequest("invalid endpoint ID")
+	}
+
+	endpoint, err := endpointRepo.FindByID(r.Context(), endpointID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, apperror.NotFound("endpoint not found")
+		}
+		return nil, apperror.Internal("failed to fetch endpoint")
+	}
+
+	if endpoint.OrgID != orgID {
+		return nil, apperror.NotFound("endpoint not found")
+	}
+
+	return endpoint, nil
+}
+
+// checkTimeout rejects endpoint timeouts outside the platform bounds.
+func (h *EndpointHandler) checkTimeout(ms int) error {
+	timeout := time.Duration(ms) * time.Millisecond
+	if timeout < h.minTimeout || timeout > h.maxTimeout {
+		return apperror.BadRequest(fmt.Sprintf("timeoutMs must be between %d and %d",
+			h.minTimeout.Milliseconds(), h.maxTimeout.Milliseconds()))
+	}
+	return nil
+}
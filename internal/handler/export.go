@@ -0,0 +1,297 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/blobstore"
+	"github.com/bilalabdelkadir/chis/internal/export"
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/bilalabdelkadir/chis/internal/search"
+	"github.com/bilalabdelkadir/chis/pkg/apperror"
+	"github.com/bilalabdelkadir/chis/pkg/response"
+	"github.com/bilalabdelkadir/chis/pkg/validator"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxStreamExportRange bounds exports streamed in the request; longer
+	// ones run as jobs.
+	maxStreamExportRange = 7 * 24 * time.Hour
+	maxExportJobRange    = 366 * 24 * time.Hour
+)
+
+type ExportHandler struct {
+	exporter         *export.Exporter
+	exportRepo       repository.ExportRepository
+	organizationRepo repository.OrganizationRepository
+	// blobs holds export job files; nil when blob storage is disabled, which
+	// leaves only streamed exports.
+	blobs blobstore.Store
+}
+
+func NewExportHandler(
+	exporter *export.Exporter,
+	exportRepo repository.ExportRepository,
+	organizationRepo repository.OrganizationRepository,
+	blobs blobstore.Store,
+) *ExportHandler {
+	return &ExportHandler{
+		exporter:         exporter,
+		exportRepo:       exportRepo,
+		organizationRepo: organizationRepo,
+		blobs:            blobs,
+	}
+}
+
+type CreateExportRequest struct {
+	Format string    `json:"format" validate:"required,oneof=csv ndjson"`
+	From   time.Time `json:"from" validate:"required"`
+	To     time.Time `json:"to" validate:"required"`
+	Status string    `json:"status" validate:"omitempty,oneof=pending retry success failed cancelled held"`
+	Query  string    `json:"q"`
+}
+
+// Stream writes the org's messages and their attempts as CSV or NDJSON in
+// the response, oldest first. Query parameters are format (csv or ndjson,
+// default ndjson), from and to (RFC 3339, default the last 24 hours, at most
+// 7 days apart), status and q, as for the webhook logs.
+func (h *ExportHandler) Stream(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = model.ExportFormatNDJSON
+	}
+	if format != model.ExportFormatCSV && format != model.ExportFormatNDJSON {
+		return apperror.BadRequest("format must be one of: csv, ndjson")
+	}
+
+	from, to, err := parseTimeRange(r, time.Second)
+	if err != nil {
+		return err
+	}
+	if to.Sub(from) > maxStreamExportRange {
+		return apperror.BadRequest(fmt.Sprintf("range must not exceed %d days; create an export job for longer ones",
+			maxStreamExportRange/(24*time.Hour)))
+	}
+
+	filter, err := search.Parse(query.Get("q"), repository.WebhookLogSchema)
+	if err != nil {
+		return apperror.BadRequest(err.Error())
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chis-export-%s.%s"`, from.Format("20060102"), format))
+
+	n, err := h.exporter.Write(r.Context(), w, format, repository.ExportFilter{
+		OrgID:  orgID,
+		From:   from,
+		To:     to,
+		Status: query.Get("status"),
+		Filter: filter,
+	})
+	if err != nil {
+		// The status line is gone; cutting the connection short is the
+		// only way left to tell the client the file is incomplete.
+		slog.Error("export_stream_failed", "org_id", orgID, "messages", n, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+	return nil
+}
+
+// CreateJob queues an export to be written to a file in the background,
+// for ranges too long to stream.
+func (h *ExportHandler) CreateJob(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+	userID, err := extractUserID(r)
+	if err != nil {
+		return err
+	}
+	if h.blobs == nil {
+		return apperror.ServiceUnavailable("export jobs need blob storage; stream the export instead")
+	}
+
+	var req CreateExportRequest
+	if err := validator.DecodeAndValidate(r, &req); err != nil {
+		return err
+	}
+	if !req.From.Before(req.To) {
+		return apperror.BadRequest("from must be before to")
+	}
+	if req.To.Sub(req.From) > maxExportJobRange {
+		return apperror.BadRequest(fmt.Sprintf("range must not exceed %d days", maxExportJobRange/(24*time.Hour)))
+	}
+	if _, err := search.Parse(req.Query, repository.WebhookLogSchema); err != nil {
+		return apperror.BadRequest(err.Error())
+	}
+
+	job := &model.ExportJob{
+		OrgID:         orgID,
+		RequestedBy:   &userID,
+		Format:        req.Format,
+		From:          req.From.UTC(),
+		To:            req.To.UTC(),
+		MessageStatus: req.Status,
+		Query:         req.Query,
+	}
+	if err := h.exportRepo.CreateJob(r.Context(), job); err != nil {
+		return apperror.Internal("failed to create export job")
+	}
+
+	response.WriteJSON(w, http.StatusAccepted, job)
+	return nil
+}
+
+func (h *ExportHandler) ListJobs(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := h.exportRepo.FindJobsByOrgID(r.Context(), orgID, page)
+	if err != nil {
+		return apperror.Internal("failed to fetch export jobs")
+	}
+
+	response.WriteJSON(w, http.StatusOK, jobs)
+	return nil
+}
+
+func (h *ExportHandler) GetJob(w http.ResponseWriter, r *http.Request) error {
+	job, err := h.findJob(r)
+	if err != nil {
+		return err
+	}
+
+	response.WriteJSON(w, http.StatusOK, job)
+	return nil
+}
+
+// Download serves a finished job's file.
+func (h *ExportHandler) Download(w http.ResponseWriter, r *http.Request) error {
+	job, err := h.findJob(r)
+	if err != nil {
+		return err
+	}
+	if job.Status != model.ExportDone || job.FileRef == nil {
+		return apperror.Conflict("export is not ready")
+	}
+	// The file may outlive its expiry until the scheduler manages to delete it.
+	if job.ExpiresAt != nil && job.ExpiresAt.Before(time.Now()) {
+		return apperror.NotFound("export file has expired")
+	}
+	if h.blobs == nil {
+		return apperror.ServiceUnavailable("export files need blob storage")
+	}
+
+	rc, err := h.blobs.Get(r.Context(), *job.FileRef)
+	if err != nil {
+		if errors.Is(err, blobstore.ErrNotFound) {
+			return apperror.NotFound("export file has expired")
+		}
+		return apperror.Internal("failed to read export file")
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", export.ContentType(job.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chis-export-%s.%s"`, job.ID, job.Format))
+	w.Header().Set("Content-Length", fmt.Sprint(job.SizeBytes))
+	if _, err := io.Copy(w, rc); err != nil {
+		slog.Error("export_download_failed", "job_id", job.ID, "error", err)
+	}
+	return nil
+}
+
+// findJob loads the job in the URL, which must belong to the request's org.
+func (h *ExportHandler) findJob(r *http.Request) (*model.ExportJob, error) {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	jobID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return nil, apperror.BadRequest("invalid export ID")
+	}
+
+	job, err := h.exportRepo.FindJobByID(r.Context(), jobID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, apperror.NotFound("export not found")
+		}
+		return nil, apperror.Internal("failed to fetch export")
+	}
+	if job.OrgID != orgID {
+		return nil, apperror.NotFound("export not found")
+	}
+	return job, nil
+}
+
+type UpdateRedactionRequest struct {
+	Paths []string `json:"paths"`
+}
+
+// GetRedaction returns the payload paths exports redact.
+func (h *ExportHandler) GetRedaction(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	paths, err := h.organizationRepo.GetRedactPaths(r.Context(), orgID)
+	if err != nil {
+		return apperror.Internal("failed to fetch redaction paths")
+	}
+
+	response.WriteJSON(w, http.StatusOK, map[string][]string{"paths": paths})
+	return nil
+}
+
+// UpdateRedaction replaces the payload paths exports redact.
+func (h *ExportHandler) UpdateRedaction(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	var req UpdateRedactionRequest
+	if err := validator.DecodeAndValidate(r, &req); err != nil {
+		return err
+	}
+	if len(req.Paths) > export.MaxRedactPaths {
+		return apperror.BadRequest(fmt.Sprintf("at most %d redaction paths", export.MaxRedactPaths))
+	}
+	paths := make([]string, 0, len(req.Paths))
+	for _, p := range req.Paths {
+		if err := export.ValidatePath(p); err != nil {
+			return apperror.BadRequest(err.Error())
+		}
+		paths = append(paths, p)
+	}
+
+	if err := h.organizationRepo.UpdateRedactPaths(r.Context(), orgID, paths); err != nil {
+		return apperror.Internal("failed to update redaction paths")
+	}
+
+	response.WriteJSON(w, http.StatusOK, map[string][]string{"paths": paths})
+	return nil
+}
@@ -163,14 +163,18 @@ func (h *InvitationHandler) ListInvitations(w http.ResponseWriter, r *http.Reque
 		return err
 	}
 
-	invitations, err := h.invitationRepo.FindByOrgID(r.Context(), orgID)
+	page, err := parsePageRequest(r)
+	if err != nil {
+		return err
+	}
+
+	invitations, err := h.invitationRepo.FindByOrgID(r.Context(), orgID, page)
 	if err != nil {
 		return apperror.Internal("failed to fetch invitations")
 	}
 
-	result := make([]InvitationResponse, len(invitations))
-	for i, inv := range invitations {
-		result[i] = InvitationResponse{
+	result := mapPage(invitations, func(inv *model.Invitation) InvitationResponse {
+		return InvitationResponse{
 			ID:        inv.ID.String(),
 			Email:     inv.Email,
 			Role:      inv.Role,
@@ -178,7 +182,7 @@ func (h *InvitationHandler) ListInvitations(w http.ResponseWriter, r *http.Reque
 			ExpiresAt: inv.ExpiresAt.Format(time.RFC3339),
 			CreatedAt: inv.CreatedAt.Format(time.RFC3339),
 		}
-	}
+	})
 
 	response.WriteJSON(w, http.StatusOK, result)
 	return nil
@@ -197,19 +201,14 @@ func (h *InvitationHandler) CancelInvitation(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Verify the invitation belongs to this org
-	invitations, err := h.invitationRepo.FindByOrgID(r.Context(), orgID)
+	invitation, err := h.invitationRepo.FindByID(r.Context(), invitationID)
 	if err != nil {
-		return apperror.Internal("failed to fetch invitations")
-	}
-
-	found := false
-	for _, inv := range invitations {
-		if inv.ID == invitationID {
-			found = true
-			break
+		if err == repository.ErrNotFound {
+			return apperror.NotFound("invitation not found")
 		}
+		return apperror.Internal("failed to fetch invitation")
 	}
-	if !found {
+	if invitation.OrgID != orgID {
 		return apperror.NotFound("invitation not found")
 	}
 
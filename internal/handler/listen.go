@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/relay"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/bilalabdelkadir/chis/pkg/apperror"
+	"github.com/bilalabdelkadir/chis/pkg/response"
+	"github.com/bilalabdelkadir/chis/pkg/validator"
+	"github.com/go-chi/chi/v5"
+)
+
+// listenPollWait is how long a poll is held open waiting for a delivery.
+const listenPollWait = 25 * time.Second
+
+// ListenHandler serves the long-poll session used by `chis listen` to
+// receive deliveries for a relay endpoint.
+type ListenHandler struct {
+	endpointRepo repository.EndpointRepository
+	relay        *relay.Relay
+}
+
+func NewListenHandler(
+	endpointRepo repository.EndpointRepository,
+	relay *relay.Relay,
+) *ListenHandler {
+	return &ListenHandler{
+		endpointRepo: endpointRepo,
+		relay:        relay,
+	}
+}
+
+type ListenResponseRequest struct {
+	StatusCode int         `json:"statusCode" validate:"omitempty,gte=100,lte=599"`
+	Headers    http.Header `json:"headers"`
+	Body       []byte      `json:"body"`
+	Error      string      `json:"error"`
+}
+
+// Poll returns the next delivery for the endpoint, or 204 when none
+// arrived while the request was held open.
+func (h *ListenHandler) Poll(w http.ResponseWriter, r *http.Request) error {
+	endpoint, err := findOrgEndpoint(r, h.endpointRepo)
+	if err != nil {
+		return err
+	}
+	if !endpoint.Relay {
+		return apperror.Conflict("endpoint is not a relay endpoint")
+	}
+
+	req, err := h.relay.Next(r.Context(), endpoint.ID, listenPollWait)
+	if err != nil {
+		if r.Context().Err() != nil {
+			return nil
+		}
+		return apperror.Internal("failed to poll for deliveries")
+	}
+	if req == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	response.WriteJSON(w, http.StatusOK, req)
+	return nil
+}
+
+// Respond records the local server's response to a relayed delivery.
+func (h *ListenHandler) Respond(w http.ResponseWriter, r *http.Request) error {
+	endpoint, err := findOrgEndpoint(r, h.endpointRepo)
+	if err != nil {
+		return err
+	}
+
+	var req ListenResponseRequest
+	if err := validator.DecodeAndValidate(r, &req); err != nil {
+		return err
+	}
+	if req.StatusCode == 0 && req.Error == "" {
+		return apperror.BadRequest("statusCode or error is required")
+	}
+
+	resp := &relay.Response{
+		StatusCode: req.StatusCode,
+		Headers:    req.Headers,
+		Body:       req.Body,
+		Error:      req.Error,
+	}
+	if err := h.relay.Respond(r.Context(), endpoint.ID, chi.URLParam(r, "requestId"), resp); err != nil {
+		return apperror.Internal("failed to record response")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
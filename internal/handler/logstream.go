@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/logstream"
+	"github.com/bilalabdelkadir/chis/pkg/apperror"
+	"github.com/google/uuid"
+)
+
+// logStreamHeartbeat keeps idle streams from being closed by proxies.
+const logStreamHeartbeat = 15 * time.Second
+
+var (
+	logStreamTypes    = []string{logstream.MessageStatus, logstream.MessageAttempt}
+	logStreamStatuses = []string{"pending", "retry", "success", "failed", "cancelled", "held"}
+)
+
+// LogStreamHandler pushes the org's message status changes and delivery
+// attempts to the dashboard as Server-Sent Events.
+type LogStreamHandler struct {
+	stream *logstream.Stream
+}
+
+func NewLogStreamHandler(stream *logstream.Stream) *LogStreamHandler {
+	return &LogStreamHandler{
+		stream: stream,
+	}
+}
+
+// Stream holds the request open and writes an event for each change until
+// the client disconnects. Query parameters narrow what is sent: type and
+// status take comma-separated lists, endpointId and eventType one value.
+func (h *LogStreamHandler) Stream(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	filter, err := parseLogStreamFilter(r)
+	if err != nil {
+		return err
+	}
+
+	sub, err := h.stream.Subscribe(r.Context(), orgID)
+	if err != nil {
+		return apperror.Internal("failed to subscribe to log events")
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Stops nginx from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	fmt.Fprint(w, ": connected\n\n")
+	if err := rc.Flush(); err != nil {
+		slog.Error("log_stream_flush_failed", "org_id", orgID, "error", err)
+		return nil
+	}
+
+	heartbeat := time.NewTicker(logStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return nil
+			}
+			if !filter.Match(ev) {
+				continue
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+		}
+		if err := rc.Flush(); err != nil {
+			return nil
+		}
+	}
+}
+
+func parseLogStreamFilter(r *http.Request) (logstream.Filter, error) {
+	query := r.URL.Query()
+	var filter logstream.Filter
+
+	if v := query.Get("type"); v != "" {
+		filter.Types = strings.Split(v, ",")
+		for _, t := range filter.Types {
+			if !slices.Contains(logStreamTypes, t) {
+				return filter, apperror.BadRequest("type must be one of: " + strings.Join(logStreamTypes, ", "))
+			}
+		}
+	}
+	if v := query.Get("status"); v != "" {
+		filter.Statuses = strings.Split(v, ",")
+		for _, s := range filter.Statuses {
+			if !slices.Contains(logStreamStatuses, s) {
+				return filter, apperror.BadRequest("status must be one of: " + strings.Join(logStreamStatuses, ", "))
+			}
+		}
+	}
+	if v := query.Get("endpointId"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return filter, apperror.BadRequest("invalid endpointId")
+		}
+		filter.EndpointID = &id
+	}
+	filter.EventType = query.Get("eventType")
+	return filter, nil
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/bilalabdelkadir/chis/internal/logstream"
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/bilalabdelkadir/chis/pkg/apperror"
+	"github.com/bilalabdelkadir/chis/pkg/response"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// MessageHandler serves message lookup, replay and cancel to API key
+// clients.
+type MessageHandler struct {
+	messageRepo         repository.MessageRepository
+	deliveryAttemptRepo repository.DeliveryAttemptRepository
+	planRepo            repository.PlanRepository
+	usageRepo           repository.UsageRepository
+	logStream           *logstream.Stream
+}
+
+func NewMessageHandler(
+	messageRepo repository.MessageRepository,
+	deliveryAttemptRepo repository.DeliveryAttemptRepository,
+	planRepo repository.PlanRepository,
+	usageRepo repository.UsageRepository,
+	logStream *logstream.Stream,
+) *MessageHandler {
+	return &MessageHandler{
+		messageRepo:         messageRepo,
+		deliveryAttemptRepo: deliveryAttemptRepo,
+		planRepo:            planRepo,
+		usageRepo:           usageRepo,
+		logStream:           logStream,
+	}
+}
+
+func (h *MessageHandler) Get(w http.ResponseWriter, r *http.Request) error {
+	orgID, msgID, err := messageParams(r)
+	if err != nil {
+		return err
+	}
+
+	detail, err := webhookLogDetail(r.Context(), h.messageRepo, h.deliveryAttemptRepo, orgID, msgID)
+	if err != nil {
+		return err
+	}
+
+	response.WriteJSON(w, http.StatusOK, detail)
+	return nil
+}
+
+// Replay queues a copy of a finished message. The copy is a new message
+// whose replayedFrom points at the original.
+func (h *MessageHandler) Replay(w http.ResponseWriter, r *http.Request) error {
+	orgID, msgID, err := messageParams(r)
+	if err != nil {
+		return err
+	}
+
+	msg, err := h.findOrgMessage(r, orgID, msgID)
+	if err != nil {
+		return err
+	}
+
+	// A replay is a new message, so it counts toward the quota.
+	remaining, plan, err := remainingMessages(r.Context(), h.planRepo, h.usageRepo, orgID)
+	if err != nil {
+		return err
+	}
+	if remaining == 0 {
+		return quotaExceeded(plan)
+	}
+
+	replay, err := h.messageRepo.Replay(r.Context(), msgID, apiKeyIDFromContext(r))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.Conflict("message is still being delivered")
+		}
+		return apperror.Internal("failed to replay message")
+	}
+
+	size := int64(len(msg.Payload))
+	if msg.PayloadSize != nil {
+		size = int64(*msg.PayloadSize)
+	}
+	if err := h.usageRepo.Record(r.Context(), orgID, 1, size, 0); err != nil {
+		slog.Error("usage_record_failed", "org_id", orgID, "message_id", replay.ID, "error", err)
+	}
+	h.logStream.PublishStatus(r.Context(), replay, replay.Status)
+
+	response.WriteJSON(w, http.StatusCreated, SendWebhookResponse{
+		MessageID: replay.ID,
+		Status:    replay.Status,
+	})
+	return nil
+}
+
+// Cancel stops a message that is pending or waiting for a retry.
+func (h *MessageHandler) Cancel(w http.ResponseWriter, r *http.Request) error {
+	orgID, msgID, err := messageParams(r)
+	if err != nil {
+		return err
+	}
+
+	msg, err := h.findOrgMessage(r, orgID, msgID)
+	if err != nil {
+		return err
+	}
+
+	if err := h.messageRepo.Cancel(r.Context(), msgID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.Conflict("message has already been delivered or cancelled")
+		}
+		return apperror.Internal("failed to cancel message")
+	}
+	msg.NextRetryAt = nil
+	h.logStream.PublishStatus(r.Context(), msg, "cancelled")
+
+	response.WriteJSON(w, http.StatusOK, SendWebhookResponse{
+		MessageID: msgID,
+		Status:    "cancelled",
+	})
+	return nil
+}
+
+func (h *MessageHandler) findOrgMessage(r *http.Request, orgID, msgID uuid.UUID) (*model.Message, error) {
+	msg, err := h.messageRepo.FindById(r.Context(), msgID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, apperror.NotFound("message not found")
+		}
+		return nil, apperror.Internal("failed to fetch message")
+	}
+	if msg.OrgID != orgID {
+		return nil, apperror.NotFound("message not found")
+	}
+	return msg, nil
+}
+
+func messageParams(r *http.Request) (uuid.UUID, uuid.UUID, error) {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	msgID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, apperror.BadRequest("invalid message ID")
+	}
+
+	return orgID, msgID, nil
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/bilalabdelkadir/chis/pkg/apperror"
+	"github.com/bilalabdelkadir/chis/pkg/response"
+	"github.com/bilalabdelkadir/chis/pkg/validator"
+)
+
+// notificationHistory is how far back List looks.
+const notificationHistory = 30 * 24 * time.Hour
+
+type NotificationHandler struct {
+	notificationRepo repository.NotificationRepository
+}
+
+func NewNotificationHandler(notificationRepo repository.NotificationRepository) *NotificationHandler {
+	return &NotificationHandler{
+		notificationRepo: notificationRepo,
+	}
+}
+
+type UpdateNotificationPreferencesRequest struct {
+	EndpointDisabled      *bool `json:"endpointDisabled"`
+	DeadLetters           *bool `json:"deadLetters"`
+	SuccessRate           *bool `json:"successRate"`
+	DigestIntervalMinutes *int  `json:"digestIntervalMinutes" validate:"omitempty,oneof=15 60 1440"`
+}
+
+type NotificationsResponse struct {
+	Data  []*model.Notification `json:"data"`
+	Total int                   `json:"total"`
+}
+
+// List returns the org's notifications from the last 30 days, newest first.
+func (h *NotificationHandler) List(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	kinds := []string{model.NotificationEndpointDisabled, model.NotificationDeadLetters, model.NotificationSuccessRate}
+	now := time.Now()
+	notifications, total, err := h.notificationRepo.FindBetween(r.Context(), orgID, kinds, now.Add(-notificationHistory), now, 100)
+	if err != nil {
+		return apperror.Internal("failed to fetch notifications")
+	}
+
+	if notifications == nil {
+		notifications = []*model.Notification{}
+	}
+
+	response.WriteJSON(w, http.StatusOK, NotificationsResponse{Data: notifications, Total: total})
+	return nil
+}
+
+// GetPreferences returns the caller's notification settings for the org.
+// Only admins are emailed.
+func (h *NotificationHandler) GetPreferences(w http.ResponseWriter, r *http.Request) error {
+	prefs, err := h.preferences(r)
+	if err != nil {
+		return err
+	}
+
+	response.WriteJSON(w, http.StatusOK, prefs)
+	return nil
+}
+
+func (h *NotificationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) error {
+	var req UpdateNotificationPreferencesRequest
+	if err := validator.DecodeAndValidate(r, &req); err != nil {
+		return err
+	}
+
+	prefs, err := h.preferences(r)
+	if err != nil {
+		return err
+	}
+
+	if req.EndpointDisabled != nil {
+		prefs.EndpointDisabled = *req.EndpointDisabled
+	}
+	if req.DeadLetters != nil {
+		prefs.DeadLetters = *req.DeadLetters
+	}
+	if req.SuccessRate != nil {
+		prefs.SuccessRate = *req.SuccessRate
+	}
+	if req.DigestIntervalMinutes != nil {
+		prefs.DigestIntervalMinutes = *req.DigestIntervalMinutes
+	}
+
+	if err := h.notificationRepo.UpsertPreferences(r.Context(), prefs); err != nil {
+		return apperror.Internal("failed to update notification preferences")
+	}
+
+	response.WriteJSON(w, http.StatusOK, prefs)
+	return nil
+}
+
+func (h *NotificationHandler) preferences(r *http.Request) (*model.NotificationPreferences, error) {
+	userID, err := extractUserID(r)
+	if err != nil {
+		return nil, err
+	}
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs, err := h.notificationRepo.GetPreferences(r.Context(), userID, orgID)
+	if err != nil {
+		return nil, apperror.Internal("failed to fetch notification preferences")
+	}
+	return prefs, nil
+}
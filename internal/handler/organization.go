@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/queue"
 	"github.com/bilalabdelkadir/chis/internal/repository"
 	"github.com/bilalabdelkadir/chis/pkg/apperror"
 	"github.com/bilalabdelkadir/chis/pkg/helper"
@@ -12,17 +15,23 @@ import (
 )
 
 type OrganizationHandler struct {
-	organizationRepo repository.OrganizationRepository
-	membershipRepo   repository.MembershipRepository
+	organizationRepo  repository.OrganizationRepository
+	membershipRepo    repository.MembershipRepository
+	secretGracePeriod time.Duration
+	queue             *queue.Queue
 }
 
 func NewOrganizationHandler(
 	organizationRepo repository.OrganizationRepository,
 	membershipRepo repository.MembershipRepository,
+	secretGracePeriod time.Duration,
+	queue *queue.Queue,
 ) *OrganizationHandler {
 	return &OrganizationHandler{
-		organizationRepo: organizationRepo,
-		membershipRepo:   membershipRepo,
+		organizationRepo:  organizationRepo,
+		membershipRepo:    membershipRepo,
+		secretGracePeriod: secretGracePeriod,
+		queue:             queue,
 	}
 }
 
@@ -143,18 +152,34 @@ func (h *OrganizationHandler) CreateOrg(w http.ResponseWriter, r *http.Request)
 	return nil
 }
 
+type SigningSecretResponse struct {
+	SigningSecret string `json:"signingSecret"`
+	// StandardSigningSecret is the same key in the "whsec_<base64>" form
+	// Standard Webhooks libraries take.
+	StandardSigningSecret   string  `json:"standardSigningSecret,omitempty"`
+	PreviousSecretExpiresAt *string `json:"previousSecretExpiresAt"`
+	// Source is "endpoint" or "organization" for endpoint secrets.
+	Source string `json:"source,omitempty"`
+}
+
+type RotateSigningSecretRequest struct {
+	// GracePeriodSeconds overrides how long the old secret stays valid.
+	// Zero revokes it immediately.
+	GracePeriodSeconds *int `json:"gracePeriodSeconds" validate:"omitempty,gte=0,lte=2592000"`
+}
+
 func (h *OrganizationHandler) GetSigningSecret(w http.ResponseWriter, r *http.Request) error {
 	orgID, err := extractOrgID(r)
 	if err != nil {
 		return err
 	}
 
-	secret, err := h.organizationRepo.GetSigningSecret(r.Context(), orgID)
+	org, err := h.organizationRepo.FindByID(r.Context(), orgID)
 	if err != nil {
 		return apperror.Internal("failed to fetch signing secret")
 	}
 
-	response.WriteJSON(w, http.StatusOK, map[string]string{"signingSecret": secret})
+	response.WriteJSON(w, http.StatusOK, signingSecretResponse(org.SigningSecret, org.PreviousSecretExpiresAt))
 	return nil
 }
 
@@ -164,15 +189,123 @@ func (h *OrganizationHandler) RotateSigningSecret(w http.ResponseWriter, r *http
 		return err
 	}
 
+	previousExpiresAt, err := previousSecretExpiry(r, h.secretGracePeriod)
+	if err != nil {
+		return err
+	}
+
 	newSecret, err := helper.GenerateSigningSecret()
 	if err != nil {
 		return apperror.Internal("failed to generate signing secret")
 	}
 
-	if err := h.organizationRepo.RotateSigningSecret(r.Context(), orgID, newSecret); err != nil {
+	if err := h.organizationRepo.RotateSigningSecret(r.Context(), orgID, newSecret, previousExpiresAt); err != nil {
 		return apperror.Internal("failed to rotate signing secret")
 	}
 
-	response.WriteJSON(w, http.StatusOK, map[string]string{"signingSecret": newSecret})
+	response.WriteJSON(w, http.StatusOK, signingSecretResponse(newSecret, previousExpiresAt))
+	return nil
+}
+
+// ExpirePreviousSigningSecret stops signing with the pre-rotation secret
+// before its grace period is over.
+func (h *OrganizationHandler) ExpirePreviousSigningSecret(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.organizationRepo.ExpirePreviousSigningSecret(r.Context(), orgID); err != nil {
+		return apperror.Internal("failed to expire previous signing secret")
+	}
+
+	response.WriteJSON(w, http.StatusOK, map[string]string{"message": "previous signing secret expired"})
+	return nil
+}
+
+// previousSecretExpiry reads the optional rotate body and returns how long
+// the outgoing secret stays valid, or nil to revoke it immediately.
+func previousSecretExpiry(r *http.Request, defaultGracePeriod time.Duration) (*time.Time, error) {
+	var req RotateSigningSecretRequest
+	if r.ContentLength != 0 {
+		if err := validator.DecodeAndValidate(r, &req); err != nil {
+			return nil, err
+		}
+	}
+
+	gracePeriod := defaultGracePeriod
+	if req.GracePeriodSeconds != nil {
+		gracePeriod = time.Duration(*req.GracePeriodSeconds) * time.Second
+	}
+
+	if gracePeriod <= 0 {
+		return nil, nil
+	}
+	t := time.Now().Add(gracePeriod)
+	return &t, nil
+}
+
+func signingSecretResponse(secret string, previousExpiresAt *time.Time) SigningSecretResponse {
+	res := SigningSecretResponse{SigningSecret: secret}
+	if standard, err := helper.StandardSigningSecret(secret); err == nil {
+		res.StandardSigningSecret = standard
+	}
+	if previousExpiresAt != nil && previousExpiresAt.After(time.Now()) {
+		t := previousExpiresAt.Format(time.RFC3339)
+		res.PreviousSecretExpiresAt = &t
+	}
+	return res
+}
+
+type UpdatePayloadLimitRequest struct {
+	MaxPayloadBytes *int `json:"maxPayloadBytes" validate:"omitempty,gte=1"`
+}
+
+func (h *OrganizationHandler) UpdatePayloadLimit(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	var req UpdatePayloadLimitRequest
+	if err := validator.DecodeAndValidate(r, &req); err != nil {
+		return err
+	}
+
+	if err := h.organizationRepo.UpdateMaxPayloadBytes(r.Context(), orgID, req.MaxPayloadBytes); err != nil {
+		return apperror.Internal("failed to update payload limit")
+	}
+
+	response.WriteJSON(w, http.StatusOK, map[string]*int{"maxPayloadBytes": req.MaxPayloadBytes})
+	return nil
+}
+
+type UpdateDeliveryRateRequest struct {
+	MaxDeliveriesPerSecond *int `json:"maxDeliveriesPerSecond" validate:"omitempty,gte=1"`
+}
+
+// UpdateDeliveryRate caps how fast the org's webhooks are delivered. A null
+// rate removes the cap.
+func (h *OrganizationHandler) UpdateDeliveryRate(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	var req UpdateDeliveryRateRequest
+	if err := validator.DecodeAndValidate(r, &req); err != nil {
+		return err
+	}
+
+	if err := h.organizationRepo.UpdateMaxDeliveriesPerSecond(r.Context(), orgID, req.MaxDeliveriesPerSecond); err != nil {
+		return apperror.Internal("failed to update delivery rate")
+	}
+	// The scheduler resyncs every org's cap, so a failure here only delays
+	// the change.
+	if err := h.queue.SetOrgRate(r.Context(), orgID, req.MaxDeliveriesPerSecond); err != nil {
+		slog.Error("org_rate_sync_failed", "org_id", orgID, "error", err)
+	}
+
+	response.WriteJSON(w, http.StatusOK, map[string]*int{"maxDeliveriesPerSecond": req.MaxDeliveriesPerSecond})
 	return nil
 }
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/bilalabdelkadir/chis/pkg/apperror"
+)
+
+// parsePageRequest reads the cursor, limit (20 by default, at most 100) and
+// count (exact or estimate, no total when absent) query parameters.
+func parsePageRequest(r *http.Request) (repository.PageRequest, error) {
+	query := r.URL.Query()
+	page := repository.PageRequest{Limit: 20}
+
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := repository.DecodeCursor(v)
+		if err != nil {
+			return page, apperror.BadRequest("invalid cursor")
+		}
+		page.After = cursor
+	}
+
+	if v := query.Get("limit"); v != "" {
+		if l, err := strconv.Atoi(v); err == nil && l > 0 && l <= 100 {
+			page.Limit = l
+		}
+	}
+
+	switch count := repository.Count(query.Get("count")); count {
+	case repository.CountNone, repository.CountExact, repository.CountEstimate:
+		page.Count = count
+	default:
+		return page, apperror.BadRequest("count must be one of: exact, estimate")
+	}
+
+	return page, nil
+}
+
+// mapPage converts the items of a page, keeping its cursor and total.
+func mapPage[T, U any](page *repository.Page[T], f func(T) U) repository.Page[U] {
+	data := make([]U, len(page.Data))
+	for i, item := range page.Data {
+		data[i] = f(item)
+	}
+	return repository.Page[U]{
+		Data:           data,
+		Limit:          page.Limit,
+		NextCursor:     page.NextCursor,
+		Total:          page.Total,
+		TotalEstimated: page.TotalEstimated,
+	}
+}
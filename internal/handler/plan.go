@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/bilalabdelkadir/chis/pkg/apperror"
+	"github.com/google/uuid"
+)
+
+// quotaPeriodStart is the start of the UTC calendar month monthly quotas
+// count from.
+func quotaPeriodStart(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// remainingMessages returns how many more messages the org's plan allows
+// this month, or -1 when the plan has no quota.
+func remainingMessages(ctx context.Context, planRepo repository.PlanRepository, usageRepo repository.UsageRepository,
+	orgID uuid.UUID,
+) (int64, *model.Plan, error) {
+	plan, err := planRepo.FindByOrgID(ctx, orgID)
+	if err != nil {
+		return 0, nil, apperror.Internal("failed to load plan")
+	}
+	if plan.MonthlyMessageQuota == nil {
+		return -1, plan, nil
+	}
+
+	used, err := usageRepo.MessagesSince(ctx, orgID, quotaPeriodStart(time.Now()))
+	if err != nil {
+		return 0, nil, apperror.Internal("failed to load usage")
+	}
+	return max(int64(*plan.MonthlyMessageQuota)-used, 0), plan, nil
+}
+
+func quotaExceeded(plan *model.Plan) error {
+	return apperror.PaymentRequired(fmt.Sprintf("monthly message quota of %d exceeded on the %s plan",
+		*plan.MonthlyMessageQuota, plan.Name))
+}
+
+// checkPlanLimit rejects adding one more of something the plan caps at
+// limit, given how many the org has.
+func checkPlanLimit(plan *model.Plan, limit *int, count int, what string) error {
+	if limit != nil && count >= *limit {
+		return apperror.Forbidden(fmt.Sprintf("the %s plan allows at most %d %s", plan.Name, *limit, what))
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/bilalabdelkadir/chis/pkg/apperror"
+	"github.com/bilalabdelkadir/chis/pkg/response"
+	"github.com/google/uuid"
+)
+
+// maxReportRange is the longest window a report covers.
+const maxReportRange = 90 * 24 * time.Hour
+
+type ReportHandler struct {
+	analyticsRepo repository.AnalyticsRepository
+}
+
+func NewReportHandler(analyticsRepo repository.AnalyticsRepository) *ReportHandler {
+	return &ReportHandler{
+		analyticsRepo: analyticsRepo,
+	}
+}
+
+type EndpointReportEntry struct {
+	EndpointID  uuid.UUID          `json:"endpointId"`
+	URL         *string            `json:"url"`
+	Attempts    int64              `json:"attempts"`
+	Failures    int64              `json:"failures"`
+	DeadLetters int64              `json:"deadLetters"`
+	FailureRate float64            `json:"failureRate"`
+	LatencyMs   LatencyPercentiles `json:"latencyMs"`
+}
+
+type EndpointReportResponse struct {
+	From time.Time             `json:"from"`
+	To   time.Time             `json:"to"`
+	Sort string                `json:"sort"`
+	Data []EndpointReportEntry `json:"data"`
+}
+
+type ApiKeyReportResponse struct {
+	From time.Time                    `json:"from"`
+	To   time.Time                    `json:"to"`
+	Data []repository.ApiKeyReportRow `json:"data"`
+}
+
+// Endpoints ranks the org's endpoints over the from..to window (whole
+// hours, default the last 24) by sort: failure_rate (the default) or
+// latency, the p95. Endpoints with fewer than minAttempts attempts are left
+// out so a single failure on a quiet endpoint does not top the list.
+func (h *ReportHandler) Endpoints(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = "failure_rate"
+	}
+	if sortBy != "failure_rate" && sortBy != "latency" {
+		return apperror.BadRequest("sort must be one of: failure_rate, latency")
+	}
+	minAttempts := int64(1)
+	if v := query.Get("minAttempts"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 {
+			return apperror.BadRequest("minAttempts must be a positive integer")
+		}
+		minAttempts = n
+	}
+
+	from, to, err := parseReportRange(r)
+	if err != nil {
+		return err
+	}
+
+	rows, err := h.analyticsRepo.FindEndpointReport(r.Context(), orgID, from, to)
+	if err != nil {
+		return apperror.Internal("failed to fetch endpoint report")
+	}
+
+	entries := make(map[uuid.UUID]*EndpointReportEntry)
+	latencies := make(map[uuid.UUID]map[int]int64)
+	for _, row := range rows {
+		e, ok := entries[row.EndpointID]
+		if !ok {
+			e = &EndpointReportEntry{EndpointID: row.EndpointID, URL: row.URL}
+			entries[row.EndpointID] = e
+			latencies[row.EndpointID] = make(map[int]int64)
+		}
+		e.Attempts += row.Attempts
+		e.Failures += row.Failures
+		e.DeadLetters += row.DeadLetters
+		if row.LatencyBucket >= 0 {
+			latencies[row.EndpointID][row.LatencyBucket] += row.Attempts
+		}
+	}
+
+	data := make([]EndpointReportEntry, 0, len(entries))
+	for id, e := range entries {
+		if e.Attempts < minAttempts {
+			continue
+		}
+		e.FailureRate = float64(e.Failures) / float64(e.Attempts)
+		e.LatencyMs = latencyPercentiles(latencies[id])
+		data = append(data, *e)
+	}
+
+	slices.SortFunc(data, func(a, b EndpointReportEntry) int {
+		var c int
+		if sortBy == "latency" {
+			c = cmp.Compare(deref(b.LatencyMs.P95), deref(a.LatencyMs.P95))
+		} else {
+			c = cmp.Compare(b.FailureRate, a.FailureRate)
+		}
+		if c == 0 {
+			c = cmp.Compare(b.Attempts, a.Attempts)
+		}
+		return c
+	})
+	if limit := reportLimit(r); len(data) > limit {
+		data = data[:limit]
+	}
+
+	response.WriteJSON(w, http.StatusOK, EndpointReportResponse{From: from, To: to, Sort: sortBy, Data: data})
+	return nil
+}
+
+// ApiKeys ranks the org's API keys by how many messages they created over
+// the from..to window. Messages no key is credited with, such as
+// operational events, are grouped under a null apiKeyId.
+func (h *ReportHandler) ApiKeys(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	from, to, err := parseReportRange(r)
+	if err != nil {
+		return err
+	}
+
+	rows, err := h.analyticsRepo.FindApiKeyReport(r.Context(), orgID, from, to, reportLimit(r))
+	if err != nil {
+		return apperror.Internal("failed to fetch api key report")
+	}
+	if rows == nil {
+		rows = []repository.ApiKeyReportRow{}
+	}
+
+	response.WriteJSON(w, http.StatusOK, ApiKeyReportResponse{From: from, To: to, Data: rows})
+	return nil
+}
+
+func parseReportRange(r *http.Request) (time.Time, time.Time, error) {
+	from, to, err := parseTimeRange(r, time.Hour)
+	if err != nil {
+		return from, to, err
+	}
+	if to.Sub(from) > maxReportRange {
+		return from, to, apperror.BadRequest(fmt.Sprintf("range must not exceed %d days", maxReportRange/(24*time.Hour)))
+	}
+	return from, to, nil
+}
+
+// reportLimit reads the limit query parameter: 20 by default, at most 100.
+func reportLimit(r *http.Request) int {
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if l, err := strconv.Atoi(v); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+	return limit
+}
+
+func deref(n *int) int {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/bilalabdelkadir/chis/pkg/apperror"
+	"github.com/bilalabdelkadir/chis/pkg/helper"
+	"github.com/bilalabdelkadir/chis/pkg/response"
+	"github.com/bilalabdelkadir/chis/pkg/validator"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type SigningKeyHandler struct {
+	organizationRepo repository.OrganizationRepository
+	signingKeyRepo   repository.SigningKeyRepository
+}
+
+func NewSigningKeyHandler(
+	organizationRepo repository.OrganizationRepository,
+	signingKeyRepo repository.SigningKeyRepository,
+) *SigningKeyHandler {
+	return &SigningKeyHandler{
+		organizationRepo: organizationRepo,
+		signingKeyRepo:   signingKeyRepo,
+	}
+}
+
+type UpdateSignatureSchemeRequest struct {
+	Scheme string `json:"scheme" validate:"required,oneof=hmac ed25519 both"`
+}
+
+type UpdateSignatureProfileRequest struct {
+	Profile string `json:"profile" validate:"required,oneof=chis standard"`
+}
+
+type PublicSigningKey struct {
+	ID        string  `json:"id"`
+	PublicKey string  `json:"publicKey"`
+	Status    string  `json:"status"`
+	CreatedAt string  `json:"createdAt"`
+	RetiredAt *string `json:"retiredAt"`
+}
+
+func (h *SigningKeyHandler) UpdateScheme(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	var req UpdateSignatureSchemeRequest
+	if err := validator.DecodeAndValidate(r, &req); err != nil {
+		return err
+	}
+
+	// Make sure there is a key to sign with before Ed25519 is switched on.
+	if req.Scheme != helper.SignatureSchemeHMAC {
+		_, err := h.signingKeyRepo.FindActiveByOrgID(r.Context(), orgID)
+		if errors.Is(err, repository.ErrNotFound) {
+			if _, err := h.rotate(r, orgID); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return apperror.Internal("failed to fetch signing key")
+		}
+	}
+
+	if err := h.organizationRepo.UpdateSignatureScheme(r.Context(), orgID, req.Scheme); err != nil {
+		return apperror.Internal("failed to update signature scheme")
+	}
+
+	response.WriteJSON(w, http.StatusOK, map[string]string{"scheme": req.Scheme})
+	return nil
+}
+
+// UpdateProfile switches between the X-Webhook-* headers and the Standard
+// Webhooks webhook-* headers with spec key handling.
+func (h *SigningKeyHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	var req UpdateSignatureProfileRequest
+	if err := validator.DecodeAndValidate(r, &req); err != nil {
+		return err
+	}
+
+	if err := h.organizationRepo.UpdateSignatureProfile(r.Context(), orgID, req.Profile); err != nil {
+		return apperror.Internal("failed to update signature profile")
+	}
+
+	response.WriteJSON(w, http.StatusOK, map[string]string{"profile": req.Profile})
+	return nil
+}
+
+func (h *SigningKeyHandler) List(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	keys, err := h.signingKeyRepo.FindByOrgID(r.Context(), orgID)
+	if err != nil {
+		return apperror.Internal("failed to fetch signing keys")
+	}
+
+	response.WriteJSON(w, http.StatusOK, toPublicSigningKeys(keys))
+	return nil
+}
+
+func (h *SigningKeyHandler) Rotate(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	key, err := h.rotate(r, orgID)
+	if err != nil {
+		return err
+	}
+
+	response.WriteJSON(w, http.StatusCreated, toPublicSigningKeys([]*model.SigningKey{key})[0])
+	return nil
+}
+
+func (h *SigningKeyHandler) Delete(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	keyID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return apperror.BadRequest("invalid signing key ID")
+	}
+
+	if err := h.signingKeyRepo.DeleteRetired(r.Context(), orgID, keyID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.NotFound("retired signing key not found")
+		}
+		return apperror.Internal("failed to delete signing key")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// PublicKeys is unauthenticated so receivers can fetch the keys they need to
+// verify Ed25519 signatures.
+func (h *SigningKeyHandler) PublicKeys(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := uuid.Parse(chi.URLParam(r, "orgId"))
+	if err != nil {
+		return apperror.BadRequest("invalid organization ID")
+	}
+
+	keys, err := h.signingKeyRepo.FindByOrgID(r.Context(), orgID)
+	if err != nil {
+		return apperror.Internal("failed to fetch signing keys")
+	}
+
+	response.WriteJSON(w, http.StatusOK, map[string][]PublicSigningKey{"keys": toPublicSigningKeys(keys)})
+	return nil
+}
+
+func (h *SigningKeyHandler) rotate(r *http.Request, orgID uuid.UUID) (*model.SigningKey, error) {
+	publicKey, privateKey, err := helper.GenerateEd25519KeyPair()
+	if err != nil {
+		return nil, apperror.Internal("failed to generate signing key")
+	}
+
+	key := &model.SigningKey{
+		OrgID:      orgID,
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+	}
+
+	if err := h.signingKeyRepo.Rotate(r.Context(), key); err != nil {
+		return nil, apperror.Internal("failed to rotate signing key")
+	}
+
+	return key, nil
+}
+
+func toPublicSigningKeys(keys []*model.SigningKey) []PublicSigningKey {
+	result := make([]PublicSigningKey, len(keys))
+	for i, k := range keys {
+		result[i] = PublicSigningKey{
+			ID:        k.ID.String(),
+			PublicKey: k.PublicKey,
+			Status:    k.Status,
+			CreatedAt: k.CreatedAt.Format(time.RFC3339),
+		}
+		if k.RetiredAt != nil {
+			retiredAt := k.RetiredAt.Format(time.RFC3339)
+			result[i].RetiredAt = &retiredAt
+		}
+	}
+	return result
+}
@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/bilalabdelkadir/chis/pkg/apperror"
+	"github.com/bilalabdelkadir/chis/pkg/response"
+	"github.com/google/uuid"
+)
+
+// granularities are the bucket widths Timeseries accepts.
+var granularities = map[string]time.Duration{
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+const (
+	// maxTimeseriesPoints bounds how many buckets one request may ask for.
+	maxTimeseriesPoints = 1000
+	// maxRawRange bounds sub-hour granularities, which are counted from
+	// the attempts themselves rather than the hourly rollups.
+	maxRawRange = 24 * time.Hour
+)
+
+type LatencyPercentiles struct {
+	P50 *int `json:"p50"`
+	P95 *int `json:"p95"`
+	P99 *int `json:"p99"`
+}
+
+type TimeseriesCounts struct {
+	Succeeded int64 `json:"succeeded"`
+	Retried   int64 `json:"retried"`
+	Failed    int64 `json:"failed"`
+	// LatencyMs percentiles are the upper bound of the latency bucket the
+	// percentile falls in, nil when there were no attempts.
+	LatencyMs   LatencyPercentiles `json:"latencyMs"`
+	StatusCodes map[string]int64   `json:"statusCodes"`
+}
+
+type TimeseriesBucket struct {
+	Start time.Time `json:"start"`
+	TimeseriesCounts
+}
+
+type TimeseriesResponse struct {
+	From        time.Time          `json:"from"`
+	To          time.Time          `json:"to"`
+	Granularity string             `json:"granularity"`
+	Buckets     []TimeseriesBucket `json:"buckets"`
+	Total       TimeseriesCounts   `json:"total"`
+}
+
+// Timeseries returns the org's delivery attempts over time: outcome counts,
+// latency percentiles and status codes per bucket. Query parameters are
+// from and to (RFC 3339, default the last 24 hours), granularity (5m, 1h or
+// 1d, default 1h), endpointId and eventType.
+func (h *DashboardHandler) Timeseries(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+
+	granularity := query.Get("granularity")
+	if granularity == "" {
+		granularity = "1h"
+	}
+	bucket, ok := granularities[granularity]
+	if !ok {
+		return apperror.BadRequest("granularity must be one of: 5m, 1h, 1d")
+	}
+
+	from, to, err := parseTimeRange(r, bucket)
+	if err != nil {
+		return err
+	}
+	if to.Sub(from)/bucket > maxTimeseriesPoints {
+		return apperror.BadRequest(fmt.Sprintf("range is too long for %s granularity, at most %d buckets", granularity, maxTimeseriesPoints))
+	}
+	if bucket%time.Hour != 0 && to.Sub(from) > maxRawRange {
+		return apperror.BadRequest(fmt.Sprintf("range must not exceed %s for %s granularity", maxRawRange, granularity))
+	}
+
+	filter := repository.TimeseriesFilter{
+		OrgID:     orgID,
+		From:      from,
+		To:        to,
+		Bucket:    bucket,
+		EventType: query.Get("eventType"),
+	}
+	if v := query.Get("endpointId"); v != "" {
+		endpointID, err := uuid.Parse(v)
+		if err != nil {
+			return apperror.BadRequest("invalid endpointId")
+		}
+		filter.EndpointID = &endpointID
+	}
+
+	rows, err := h.analyticsRepo.FindTimeseries(r.Context(), filter)
+	if err != nil {
+		return apperror.Internal("failed to fetch timeseries")
+	}
+
+	response.WriteJSON(w, http.StatusOK, buildTimeseries(rows, from, to, bucket, granularity))
+	return nil
+}
+
+// parseTimeRange reads the from and to query parameters (RFC 3339, default
+// the last 24 hours) and widens them to whole multiples of align, counted
+// from UTC midnight.
+func parseTimeRange(r *http.Request, align time.Duration) (time.Time, time.Time, error) {
+	query := r.URL.Query()
+
+	var err error
+	to := time.Now().UTC()
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return time.Time{}, time.Time{}, apperror.BadRequest("to must be an RFC 3339 timestamp")
+		}
+	}
+	from := to.Add(-24 * time.Hour)
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return time.Time{}, time.Time{}, apperror.BadRequest("from must be an RFC 3339 timestamp")
+		}
+	}
+
+	from = from.UTC().Truncate(align)
+	if end := to.UTC().Truncate(align); end.Equal(to.UTC()) {
+		to = end
+	} else {
+		to = end.Add(align)
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, apperror.BadRequest("from must be before to")
+	}
+	return from, to, nil
+}
+
+// buildTimeseries folds rows into one entry per bucket, including empty
+// ones, and a total over the whole range.
+func buildTimeseries(rows []repository.TimeseriesRow, from, to time.Time, bucket time.Duration, granularity string) TimeseriesResponse {
+	byStart := make(map[time.Time][]repository.TimeseriesRow)
+	for _, row := range rows {
+		start := row.Bucket.UTC()
+		byStart[start] = append(byStart[start], row)
+	}
+
+	res := TimeseriesResponse{
+		From:        from,
+		To:          to,
+		Granularity: granularity,
+		Buckets:     make([]TimeseriesBucket, 0, to.Sub(from)/bucket),
+		Total:       countTimeseries(rows),
+	}
+	for start := from; start.Before(to); start = start.Add(bucket) {
+		res.Buckets = append(res.Buckets, TimeseriesBucket{
+			Start:            start,
+			TimeseriesCounts: countTimeseries(byStart[start]),
+		})
+	}
+	return res
+}
+
+func countTimeseries(rows []repository.TimeseriesRow) TimeseriesCounts {
+	counts := TimeseriesCounts{StatusCodes: map[string]int64{}}
+	latencies := make(map[int]int64)
+	for _, row := range rows {
+		n := row.Succeeded + row.Retried + row.Failed
+		counts.Succeeded += row.Succeeded
+		counts.Retried += row.Retried
+		counts.Failed += row.Failed
+		counts.StatusCodes[strconv.Itoa(row.StatusCode)] += n
+		if row.LatencyBucket >= 0 {
+			latencies[row.LatencyBucket] += n
+		}
+	}
+
+	counts.LatencyMs = latencyPercentiles(latencies)
+	return counts
+}
+
+func latencyPercentiles(buckets map[int]int64) LatencyPercentiles {
+	return LatencyPercentiles{
+		P50: latencyPercentile(buckets, 0.50),
+		P95: latencyPercentile(buckets, 0.95),
+		P99: latencyPercentile(buckets, 0.99),
+	}
+}
+
+// latencyPercentile returns the upper bound of the bucket holding the p-th
+// attempt, given attempt counts keyed by bucket upper bound.
+func latencyPercentile(buckets map[int]int64, p float64) *int {
+	bounds := make([]int, 0, len(buckets))
+	var total int64
+	for bound, n := range buckets {
+		bounds = append(bounds, bound)
+		total += n
+	}
+	if total == 0 {
+		return nil
+	}
+	sort.Ints(bounds)
+
+	rank := int64(math.Ceil(p * float64(total)))
+	var seen int64
+	for _, bound := range bounds {
+		seen += buckets[bound]
+		if seen >= rank {
+			return &bound
+		}
+	}
+	return &bounds[len(bounds)-1]
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/bilalabdelkadir/chis/pkg/apperror"
+	"github.com/bilalabdelkadir/chis/pkg/response"
+)
+
+// maxUsageRange is the longest span Daily returns in one request.
+const maxUsageRange = 366 * 24 * time.Hour
+
+type UsageHandler struct {
+	planRepo  repository.PlanRepository
+	usageRepo repository.UsageRepository
+	orgRepo   repository.OrganizationRepository
+}
+
+func NewUsageHandler(planRepo repository.PlanRepository, usageRepo repository.UsageRepository, orgRepo repository.OrganizationRepository) *UsageHandler {
+	return &UsageHandler{
+		planRepo:  planRepo,
+		usageRepo: usageRepo,
+		orgRepo:   orgRepo,
+	}
+}
+
+type UsageSummaryResponse struct {
+	Plan        *model.Plan `json:"plan"`
+	PeriodStart string      `json:"periodStart"`
+	Messages    int64       `json:"messages"`
+	// Remaining is nil when the plan has no message quota.
+	Remaining *int64 `json:"remaining"`
+	// RetentionDays is how long messages are kept, which may differ from
+	// the plan's when the org has its own.
+	RetentionDays int  `json:"retentionDays"`
+	LegalHold     bool `json:"legalHold"`
+}
+
+type UsageDailyResponse struct {
+	Data []*model.UsageDay `json:"data"`
+}
+
+// Plans lists the plans an org can be put on.
+func (h *UsageHandler) Plans(w http.ResponseWriter, r *http.Request) error {
+	plans, err := h.planRepo.FindAll(r.Context())
+	if err != nil {
+		return apperror.Internal("failed to load plans")
+	}
+
+	response.WriteJSON(w, http.StatusOK, plans)
+	return nil
+}
+
+// Summary returns the org's plan, how much of this month's quota is used
+// and how long its messages are kept.
+func (h *UsageHandler) Summary(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	plan, err := h.planRepo.FindByOrgID(r.Context(), orgID)
+	if err != nil {
+		return apperror.Internal("failed to load plan")
+	}
+
+	start := quotaPeriodStart(time.Now())
+	used, err := h.usageRepo.MessagesSince(r.Context(), orgID, start)
+	if err != nil {
+		return apperror.Internal("failed to load usage")
+	}
+
+	retentionDays, legalHold, err := h.orgRepo.GetRetention(r.Context(), orgID)
+	if err != nil {
+		return apperror.Internal("failed to load retention")
+	}
+
+	res := UsageSummaryResponse{
+		Plan:          plan,
+		PeriodStart:   start.Format(time.DateOnly),
+		Messages:      used,
+		RetentionDays: retentionDays,
+		LegalHold:     legalHold,
+	}
+	if plan.MonthlyMessageQuota != nil {
+		remaining := max(int64(*plan.MonthlyMessageQuota)-used, 0)
+		res.Remaining = &remaining
+	}
+
+	response.WriteJSON(w, http.StatusOK, res)
+	return nil
+}
+
+// Daily returns the org's usage per UTC day between the from and to query
+// parameters (YYYY-MM-DD, inclusive), defaulting to the current month.
+// format=csv returns the same rows as a CSV download.
+func (h *UsageHandler) Daily(w http.ResponseWriter, r *http.Request) error {
+	orgID, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	from := quotaPeriodStart(time.Now())
+	to := time.Now().UTC()
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse(time.DateOnly, v); err != nil {
+			return apperror.BadRequest("from must be a date like 2006-01-02")
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse(time.DateOnly, v); err != nil {
+			return apperror.BadRequest("to must be a date like 2006-01-02")
+		}
+	}
+	if to.Before(from) {
+		return apperror.BadRequest("to must not be before from")
+	}
+	if to.Sub(from) > maxUsageRange {
+		return apperror.BadRequest("date range must not exceed one year")
+	}
+
+	days, err := h.usageRepo.FindByOrgID(r.Context(), orgID, from, to)
+	if err != nil {
+		return apperror.Internal("failed to load usage")
+	}
+	if days == nil {
+		days = []*model.UsageDay{}
+	}
+
+	if query.Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="usage.csv"`)
+		return writeUsageCSV(w, days)
+	}
+
+	response.WriteJSON(w, http.StatusOK, UsageDailyResponse{Data: days})
+	return nil
+}
+
+// writeUsageCSV writes usage rows under a header line.
+func writeUsageCSV(w io.Writer, days []*model.UsageDay) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"org_id", "day", "messages", "payload_bytes", "delivery_attempts"})
+	for _, d := range days {
+		cw.Write([]string{
+			d.OrgID.String(),
+			d.Day.Format(time.DateOnly),
+			strconv.FormatInt(d.Messages, 10),
+			strconv.FormatInt(d.PayloadBytes, 10),
+			strconv.FormatInt(d.DeliveryAttempts, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -2,26 +2,51 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 
-	"github.com/bilalabdelkadir/chis/internal/middleware"
+	"github.com/bilalabdelkadir/chis/internal/repository"
 	"github.com/bilalabdelkadir/chis/pkg/apperror"
+	"github.com/bilalabdelkadir/chis/pkg/helper"
 	"github.com/bilalabdelkadir/chis/pkg/response"
 	"github.com/bilalabdelkadir/chis/pkg/validator"
 	pb "github.com/bilalabdelkadir/chis/proto/delivery"
 	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// requestEnvelopeBytes is the allowance for the non-payload fields of a send
+// request when capping the request body.
+const requestEnvelopeBytes = 16 << 10
+
+// maxBatchSize caps the number of messages in one batch send.
+const maxBatchSize = 100
+
 type WebhookHandler struct {
-	grpcClient pb.DeliveryServiceClient
+	grpcClient      pb.DeliveryServiceClient
+	orgRepo         repository.OrganizationRepository
+	messageRepo     repository.MessageRepository
+	planRepo        repository.PlanRepository
+	usageRepo       repository.UsageRepository
+	maxPayloadBytes int
 }
 
 type SendWebhookRequest struct {
 	URL     string      `json:"url" validate:"required,url"`
 	Method  string      `json:"method"` // optional, default POST
 	Payload interface{} `json:"payload" validate:"required"`
+	// IdempotencyKey makes retried sends return the original message. The
+	// Idempotency-Key header sets it for single sends.
+	IdempotencyKey string `json:"idempotencyKey" validate:"omitempty,max=255"`
+	// Priority picks the delivery lane; high is for time-critical events
+	// and low for bulk sends. Defaults to normal.
+	Priority string `json:"priority" validate:"omitempty,oneof=high normal low"`
+	// EventType names what the payload describes, e.g. order.created. Logs,
+	// analytics and the live stream filter on it.
+	EventType string `json:"eventType" validate:"omitempty,max=255"`
 }
 
 type SendWebhookResponse struct {
@@ -29,29 +54,183 @@ type SendWebhookResponse struct {
 	Status    string    `json:"status"`
 }
 
+type SendBatchRequest struct {
+	Messages []SendWebhookRequest `json:"messages" validate:"required,min=1,max=100,dive"`
+}
+
+// BatchResult holds either the queued message or the error for one item,
+// in the same position as the request.
+type BatchResult struct {
+	MessageID *uuid.UUID      `json:"messageId,omitempty"`
+	Status    string          `json:"status,omitempty"`
+	Error     *BatchItemError `json:"error,omitempty"`
+}
+
+type BatchItemError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type SendBatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
 func NewWebhookHandler(
 	grpcClient pb.DeliveryServiceClient,
+	orgRepo repository.OrganizationRepository,
+	messageRepo repository.MessageRepository,
+	planRepo repository.PlanRepository,
+	usageRepo repository.UsageRepository,
+	maxPayloadBytes int,
 ) *WebhookHandler {
 	return &WebhookHandler{
-		grpcClient: grpcClient,
+		grpcClient:      grpcClient,
+		orgRepo:         orgRepo,
+		messageRepo:     messageRepo,
+		planRepo:        planRepo,
+		usageRepo:       usageRepo,
+		maxPayloadBytes: maxPayloadBytes,
 	}
 }
 
 func (h *WebhookHandler) Send(w http.ResponseWriter, r *http.Request) error {
+	orgId, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	limit, err := h.payloadLimit(r, orgId)
+	if err != nil {
+		return err
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, int64(limit+requestEnvelopeBytes))
+
 	var req SendWebhookRequest
 	if err := validator.DecodeAndValidate(r, &req); err != nil {
+		return decodeSendError(err, limit)
+	}
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		if len(key) > 255 {
+			return apperror.BadRequest("Idempotency-Key must be at most 255 characters")
+		}
+		req.IdempotencyKey = key
+	}
+
+	// A retry of a send that was already accepted gets its message back,
+	// even once the quota has run out since.
+	replay, err := h.replayed(r, orgId, req.IdempotencyKey)
+	if err != nil {
 		return err
 	}
+	if replay != nil {
+		response.WriteJSON(w, http.StatusCreated, replay)
+		return nil
+	}
 
-	orgIdValue := r.Context().Value(middleware.OrgIDKey)
-	if orgIdValue == nil {
-		return apperror.Unauthorized("org not found in context")
+	remaining, plan, err := remainingMessages(r.Context(), h.planRepo, h.usageRepo, orgId)
+	if err != nil {
+		return err
 	}
-	orgId, ok := orgIdValue.(uuid.UUID)
-	if !ok {
-		return apperror.Unauthorized("invalid Org ID type")
+	if remaining == 0 {
+		return quotaExceeded(plan)
+	}
+
+	res, err := h.queue(r, orgId, req, limit)
+	if err != nil {
+		return err
+	}
+
+	response.WriteJSON(w, http.StatusCreated, res)
+
+	return nil
+
+}
+
+// SendBatch queues up to maxBatchSize messages. Items succeed or fail
+// independently; the response lists a result for each one in order.
+func (h *WebhookHandler) SendBatch(w http.ResponseWriter, r *http.Request) error {
+	orgId, err := extractOrgID(r)
+	if err != nil {
+		return err
+	}
+
+	limit, err := h.payloadLimit(r, orgId)
+	if err != nil {
+		return err
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBatchSize*(limit+requestEnvelopeBytes)))
+
+	var req SendBatchRequest
+	if err := validator.DecodeAndValidate(r, &req); err != nil {
+		return decodeSendError(err, limit)
+	}
+
+	remaining, plan, err := remainingMessages(r.Context(), h.planRepo, h.usageRepo, orgId)
+	if err != nil {
+		return err
+	}
+
+	// Items past the quota fail on their own so the rest still go out.
+	// Replayed items were counted when first accepted.
+	results := make([]BatchResult, len(req.Messages))
+	for i, item := range req.Messages {
+		replay, err := h.replayed(r, orgId, item.IdempotencyKey)
+		if err != nil {
+			results[i].Error = batchItemError(err)
+			continue
+		}
+		if replay != nil {
+			results[i].MessageID = &replay.MessageID
+			results[i].Status = replay.Status
+			continue
+		}
+		if remaining == 0 {
+			results[i].Error = batchItemError(quotaExceeded(plan))
+			continue
+		}
+		res, err := h.queue(r, orgId, item, limit)
+		if err != nil {
+			results[i].Error = batchItemError(err)
+			continue
+		}
+		results[i].MessageID = &res.MessageID
+		results[i].Status = res.Status
+		if remaining > 0 {
+			remaining--
+		}
 	}
 
+	response.WriteJSON(w, http.StatusOK, SendBatchResponse{Results: results})
+	return nil
+}
+
+// replayed returns the message already accepted under key, or nil if there
+// is none.
+func (h *WebhookHandler) replayed(r *http.Request, orgId uuid.UUID, key string) (*SendWebhookResponse, error) {
+	if key == "" {
+		return nil, nil
+	}
+	msg, err := h.messageRepo.FindByIdempotencyKey(r.Context(), orgId, key)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, apperror.Internal("failed to check idempotency key")
+	}
+	return &SendWebhookResponse{MessageID: msg.ID, Status: msg.Status}, nil
+}
+
+func (h *WebhookHandler) payloadLimit(r *http.Request, orgId uuid.UUID) (int, error) {
+	orgMax, err := h.orgRepo.GetMaxPayloadBytes(r.Context(), orgId)
+	if err != nil {
+		return 0, apperror.Internal("failed to load organization limits")
+	}
+	return helper.PayloadLimit(h.maxPayloadBytes, orgMax), nil
+}
+
+func (h *WebhookHandler) queue(r *http.Request, orgId uuid.UUID, req SendWebhookRequest, limit int) (*SendWebhookResponse, error) {
 	method := req.Method
 	if method == "" {
 		method = "POST"
@@ -60,12 +239,16 @@ func (h *WebhookHandler) Send(w http.ResponseWriter, r *http.Request) error {
 	payload, err := json.Marshal(req.Payload)
 
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if len(payload) > limit {
+		return nil, apperror.PayloadTooLarge(fmt.Sprintf("payload exceeds maximum size of %d bytes", limit))
 	}
 
 	v, ok := pb.HttpMethod_value[method]
 	if !ok {
-		return fmt.Errorf("invalid http method: %s", method)
+		return nil, apperror.BadRequest(fmt.Sprintf("invalid http method: %s", method))
 	}
 
 	methodEnum := pb.HttpMethod(v)
@@ -73,30 +256,69 @@ func (h *WebhookHandler) Send(w http.ResponseWriter, r *http.Request) error {
 	log.Printf("[API] Received webhook request for URL: %s", req.URL)
 
 	grpcReq := &pb.QueueMessageRequest{
-		Url:     req.URL,
-		Method:  methodEnum,
-		Payload: payload,
-		OrgId:   orgId.String(),
+		Url:            req.URL,
+		Method:         methodEnum,
+		Payload:        payload,
+		OrgId:          orgId.String(),
+		IdempotencyKey: req.IdempotencyKey,
+		Priority:       req.Priority,
+		EventType:      req.EventType,
+	}
+	if apiKeyID := apiKeyIDFromContext(r); apiKeyID != nil {
+		grpcReq.ApiKeyId = apiKeyID.String()
 	}
 
 	grpcRes, err := h.grpcClient.QueueMessage(r.Context(), grpcReq)
 	if err != nil {
-		return err
+		return nil, fromGrpcError(err)
 	}
 	log.Printf("[API] Delivery service returned message_id: %s", grpcRes.MessageId)
 
 	msgId, err := uuid.Parse(grpcRes.MessageId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	res := SendWebhookResponse{
+	return &SendWebhookResponse{
 		MessageID: msgId,
 		Status:    grpcRes.Status,
+	}, nil
+}
+
+func decodeSendError(err error, limit int) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return apperror.PayloadTooLarge(fmt.Sprintf("payload exceeds maximum size of %d bytes", limit))
 	}
+	return err
+}
 
-	response.WriteJSON(w, http.StatusCreated, res)
+func batchItemError(err error) *BatchItemError {
+	var appErr *apperror.AppError
+	if errors.As(err, &appErr) {
+		return &BatchItemError{Code: appErr.Code, Message: appErr.Message}
+	}
+	return &BatchItemError{Code: http.StatusInternalServerError, Message: "failed to queue message"}
+}
 
-	return nil
+// fromGrpcError maps delivery service status codes onto API errors so that
+// client mistakes are not reported as internal failures.
+func fromGrpcError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
 
+	switch st.Code() {
+	case codes.ResourceExhausted:
+		return apperror.PayloadTooLarge(st.Message())
+	case codes.InvalidArgument:
+		return apperror.BadRequest(st.Message())
+	case codes.NotFound:
+		return apperror.NotFound(st.Message())
+	case codes.FailedPrecondition:
+		return apperror.Forbidden(st.Message())
+	default:
+		return err
+	}
 }
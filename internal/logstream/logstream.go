@@ -0,0 +1,177 @@
+// Package logstream carries changes to an org's messages from the worker
+// and scheduler to dashboards watching them live, over Redis pub/sub.
+//
+// Events are fire-and-forget: nothing is stored, so a dashboard only sees
+// what happens while it is connected and reloads the logs for the rest.
+package logstream
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// MessageStatus is published when a message changes status without
+	// being attempted, such as being held or queued for a retry.
+	MessageStatus = "message.status"
+	// MessageAttempt is published after each delivery attempt, with the
+	// status the attempt left the message in.
+	MessageAttempt = "message.attempt"
+)
+
+type Event struct {
+	Type         string     `json:"type"`
+	MessageID    uuid.UUID  `json:"messageId"`
+	EndpointID   *uuid.UUID `json:"endpointId"`
+	URL          string     `json:"url"`
+	EventType    *string    `json:"eventType"`
+	Status       string     `json:"status"`
+	AttemptCount int        `json:"attemptCount"`
+	NextRetryAt  *time.Time `json:"nextRetryAt,omitempty"`
+	Attempt      *Attempt   `json:"attempt,omitempty"`
+	Timestamp    time.Time  `json:"timestamp"`
+}
+
+// Attempt leaves out the response body, which can be large; the logs API
+// has it.
+type Attempt struct {
+	AttemptNumber int     `json:"attemptNumber"`
+	StatusCode    *int    `json:"statusCode"`
+	DurationMS    *int    `json:"durationMs"`
+	ErrorMessage  *string `json:"errorMessage"`
+}
+
+// Filter selects the events a subscriber wants. Empty fields match
+// everything.
+type Filter struct {
+	Types      []string
+	Statuses   []string
+	EndpointID *uuid.UUID
+	EventType  string
+}
+
+func (f Filter) Match(ev *Event) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, ev.Type) {
+		return false
+	}
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, ev.Status) {
+		return false
+	}
+	if f.EndpointID != nil && (ev.EndpointID == nil || *ev.EndpointID != *f.EndpointID) {
+		return false
+	}
+	if f.EventType != "" && (ev.EventType == nil || *ev.EventType != f.EventType) {
+		return false
+	}
+	return true
+}
+
+type Stream struct {
+	rdb *redis.Client
+}
+
+func New(rdb *redis.Client) *Stream {
+	return &Stream{rdb: rdb}
+}
+
+func channel(orgID uuid.UUID) string { return "logstream:" + orgID.String() }
+
+// PublishStatus announces that msg is now in status.
+func (s *Stream) PublishStatus(ctx context.Context, msg *model.Message, status string) {
+	s.publish(ctx, msg.OrgID, newEvent(MessageStatus, msg, status))
+}
+
+// PublishAttempt announces attempt, which left msg in status.
+func (s *Stream) PublishAttempt(ctx context.Context, msg *model.Message, status string, attempt *model.DeliveryAttempt) {
+	ev := newEvent(MessageAttempt, msg, status)
+	ev.Attempt = &Attempt{
+		AttemptNumber: attempt.AttemptNumber,
+		StatusCode:    attempt.StatusCode,
+		DurationMS:    attempt.DurationMS,
+		ErrorMessage:  attempt.ErrorMessage,
+	}
+	s.publish(ctx, msg.OrgID, ev)
+}
+
+func newEvent(eventType string, msg *model.Message, status string) *Event {
+	return &Event{
+		Type:         eventType,
+		MessageID:    msg.ID,
+		EndpointID:   msg.EndpointID,
+		URL:          msg.URL,
+		EventType:    msg.EventType,
+		Status:       status,
+		AttemptCount: msg.AttemptCount,
+		NextRetryAt:  msg.NextRetryAt,
+		Timestamp:    time.Now().UTC(),
+	}
+}
+
+// publish logs failures rather than returning them: a dashboard missing an
+// update must never hold up a delivery.
+func (s *Stream) publish(ctx context.Context, orgID uuid.UUID, ev *Event) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		slog.Error("logstream_encode_failed", "message_id", ev.MessageID, "error", err)
+		return
+	}
+	if err := s.rdb.Publish(ctx, channel(orgID), data).Err(); err != nil {
+		slog.Error("logstream_publish_failed", "message_id", ev.MessageID, "org_id", orgID, "error", err)
+	}
+}
+
+// Subscription receives an org's events until it is closed.
+type Subscription struct {
+	pubsub *redis.PubSub
+	events chan *Event
+	done   chan struct{}
+}
+
+// Subscribe starts receiving the org's events. Events published before it
+// returns are not seen.
+func (s *Stream) Subscribe(ctx context.Context, orgID uuid.UUID) (*Subscription, error) {
+	pubsub := s.rdb.Subscribe(ctx, channel(orgID))
+	// Wait for Redis to confirm, so the caller knows it is listening.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	sub := &Subscription{pubsub: pubsub, events: make(chan *Event), done: make(chan struct{})}
+	go sub.decode()
+	return sub, nil
+}
+
+func (s *Subscription) decode() {
+	defer close(s.events)
+	for msg := range s.pubsub.Channel() {
+		ev := &Event{}
+		if err := json.Unmarshal([]byte(msg.Payload), ev); err != nil {
+			slog.Warn("logstream_decode_failed", "channel", msg.Channel, "error", err)
+			continue
+		}
+		select {
+		case s.events <- ev:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Events returns the subscription's events. It is closed once the
+// subscription is; dropped connections to Redis are reestablished.
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
+
+func (s *Subscription) Close() error {
+	close(s.done)
+	return s.pubsub.Close()
+}
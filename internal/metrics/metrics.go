@@ -38,4 +38,96 @@ var (
 			Buckets: []float64{100, 250, 500, 1000, 2500, 5000, 10000},
 		},
 	)
+
+	EndpointsDisabledTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "endpoints_disabled_total",
+			Help: "Total endpoints disabled after failing continuously",
+		},
+	)
+
+	QueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "webhook_queue_depth",
+			Help: "Messages waiting in each queue lane",
+		},
+		[]string{"lane"},
+	)
+
+	QueueActiveOrgs = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "webhook_queue_active_orgs",
+			Help: "Orgs with messages waiting in each queue lane",
+		},
+		[]string{"lane"},
+	)
+
+	QueueLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "webhook_queue_latency_ms",
+			Help:    "Time messages spend queued before a worker picks them up, in milliseconds",
+			Buckets: []float64{10, 50, 100, 500, 1000, 5000, 30000, 60000, 300000},
+		},
+		[]string{"lane"},
+	)
+
+	QueuePromotionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_queue_promotions_total",
+			Help: "Messages served ahead of their org's turn for waiting longer than QUEUE_MAX_WAIT",
+		},
+		[]string{"lane"},
+	)
+
+	DeliveryConnectionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_connections_total",
+			Help: "Connections used for webhook deliveries, by whether they were reused from the pool",
+		},
+		[]string{"reused"},
+	)
+
+	DeliveryTLSHandshakesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_tls_handshakes_total",
+			Help: "TLS handshakes made for webhook deliveries",
+		},
+		[]string{"result"},
+	)
+
+	DeliveryDNSLookupsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_dns_lookups_total",
+			Help: "Host lookups for webhook deliveries, by whether the DNS cache answered",
+		},
+		[]string{"cache"},
+	)
+
+	RetentionMessagesPurgedTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "retention_messages_purged_total",
+			Help: "Messages deleted for being older than their org's retention",
+		},
+	)
+
+	RetentionAttemptsPurgedTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "retention_delivery_attempts_purged_total",
+			Help: "Delivery attempts deleted along with purged messages",
+		},
+	)
+
+	RetentionPayloadsPurgedTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "retention_payloads_purged_total",
+			Help: "Offloaded payloads deleted from the blob store once no message refers to them",
+		},
+	)
+
+	RetentionLastPurge = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "retention_last_purge_timestamp_seconds",
+			Help: "When the last retention purge finished for every org",
+		},
+	)
 )
@@ -10,7 +10,11 @@ import (
 	"github.com/bilalabdelkadir/chis/pkg/response"
 )
 
-const OrgIDKey contextKey = "orgId"
+const (
+	OrgIDKey contextKey = "orgId"
+	// ApiKeyIDKey is set only on requests authenticated with an API key.
+	ApiKeyIDKey contextKey = "apiKeyId"
+)
 
 func ValidateApiKey(apiKeyRepo repository.ApiKeyRepository) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -35,6 +39,7 @@ func ValidateApiKey(apiKeyRepo repository.ApiKeyRepository) func(next http.Handl
 				return
 			}
 			ctx := context.WithValue(r.Context(), OrgIDKey, apiKey.OrgID)
+			ctx = context.WithValue(ctx, ApiKeyIDKey, apiKey.ID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -22,23 +22,44 @@ type APIKey struct {
 type Message struct {
 	ID           uuid.UUID       `json:"id"`
 	OrgID        uuid.UUID       `json:"orgId"`
+	EndpointID   *uuid.UUID      `json:"endpointId"`
 	Method       string          `json:"method"` // e.g., "POST"
 	URL          string          `json:"url"`
-	Payload      json.RawMessage `json:"payload"` // JSONB stored as []byte
-	Status       string          `json:"status"`  // 'pending', 'success', 'failed'
+	Payload      json.RawMessage `json:"payload"`    // JSONB stored as []byte, nil when offloaded
+	PayloadRef   *string         `json:"payloadRef"` // blob store key for offloaded payloads
+	PayloadSize  *int            `json:"payloadSize"`
+	Status       string          `json:"status"` // 'pending', 'retry', 'success', 'failed', 'cancelled', 'held'
 	CreatedAt    time.Time       `json:"createdAt"`
 	UpdatedAt    time.Time       `json:"updatedAt"`
 	AttemptCount int             `json:"attemptCount"`
 	NextRetryAt  *time.Time      `json:"nextRetryAt"`
+	// IdempotencyKey dedupes sends per org; ReplayedFrom links a replay to
+	// the message it copied.
+	IdempotencyKey *string    `json:"idempotencyKey"`
+	ReplayedFrom   *uuid.UUID `json:"replayedFrom"`
+	// EventType is set on system events sent to operational endpoints.
+	EventType *string `json:"eventType"`
+	// Priority picks the queue lane: 'high', 'normal' or 'low'.
+	Priority string `json:"priority"`
+	// ApiKeyID is the key that sent or replayed the message, if any.
+	ApiKeyID *uuid.UUID `json:"apiKeyId"`
 }
 
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
 type DeliveryAttempt struct {
 	ID            uuid.UUID `json:"id"`
 	MessageID     uuid.UUID `json:"messageId"`
 	AttemptNumber int       `json:"attemptNumber"`
 	StatusCode    *int      `json:"statusCode"`   // nullable but always present
 	ResponseBody  *string   `json:"responseBody"` // nullable but always present
-	ErrorMessage  *string   `json:"errorMessage"` // nullable but always present
-	DurationMS    *int      `json:"durationMs"`   // nullable but always present
-	AttemptedAt   time.Time `json:"attemptedAt"`
+	// ResponseTruncated is set when the body was longer than the capture limit.
+	ResponseTruncated bool      `json:"responseTruncated"`
+	ErrorMessage      *string   `json:"errorMessage"` // nullable but always present
+	DurationMS        *int      `json:"durationMs"`   // nullable but always present
+	AttemptedAt       time.Time `json:"attemptedAt"`
 }
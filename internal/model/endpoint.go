@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Endpoint struct {
+	ID              uuid.UUID `json:"id"`
+	OrgID           uuid.UUID `json:"orgId"`
+	URL             string    `json:"url"`
+	ContentEncoding string    `json:"contentEncoding"` // 'identity', 'gzip', 'zstd'
+	// Relay routes deliveries to a `chis listen` session.
+	Relay bool `json:"relay"`
+	// Operational endpoints receive the org's system events.
+	Operational bool `json:"operational"`
+	// TimeoutMS overrides the platform delivery timeout when set.
+	TimeoutMS *int `json:"timeoutMs"`
+	// SigningSecret overrides the org secret when set.
+	SigningSecret           *string    `json:"-"`
+	PreviousSigningSecret   *string    `json:"-"`
+	PreviousSecretExpiresAt *time.Time `json:"previousSecretExpiresAt"`
+	// Health is tracked by the worker. A disabled endpoint holds its
+	// messages instead of attempting them.
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	FailingSince        *time.Time `json:"failingSince"`
+	LastSuccessAt       *time.Time `json:"lastSuccessAt"`
+	DisabledAt          *time.Time `json:"disabledAt"`
+	DisabledReason      *string    `json:"disabledReason"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	UpdatedAt           time.Time  `json:"updatedAt"`
+}
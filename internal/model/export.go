@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+)
+
+// ExportJob is an export written to the blob store in the background. It
+// covers messages created from From up to To that match MessageStatus and
+// Query, both empty for all.
+type ExportJob struct {
+	ID            uuid.UUID  `json:"id"`
+	OrgID         uuid.UUID  `json:"-"`
+	RequestedBy   *uuid.UUID `json:"requestedBy"`
+	Format        string     `json:"format"` // 'csv', 'ndjson'
+	From          time.Time  `json:"from"`
+	To            time.Time  `json:"to"`
+	MessageStatus string     `json:"messageStatus"`
+	Query         string     `json:"query"`
+	Status        string     `json:"status"` // 'pending', 'running', 'done', 'failed'
+	Messages      int64      `json:"messages"`
+	SizeBytes     int64      `json:"sizeBytes"`
+	FileRef       *string    `json:"-"`
+	Error         *string    `json:"error"`
+	StartedAt     *time.Time `json:"startedAt"`
+	FinishedAt    *time.Time `json:"finishedAt"`
+	// ExpiresAt is when the file is deleted, set once the job is done.
+	ExpiresAt *time.Time `json:"expiresAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	NotificationEndpointDisabled = "endpoint_disabled"
+	NotificationDeadLetters      = "dead_letters"
+	NotificationSuccessRate      = "success_rate"
+)
+
+type Notification struct {
+	ID         uuid.UUID  `json:"id"`
+	OrgID      uuid.UUID  `json:"orgId"`
+	Kind       string     `json:"kind"` // 'endpoint_disabled', 'dead_letters', 'success_rate'
+	EndpointID *uuid.UUID `json:"endpointId"`
+	Summary    string     `json:"summary"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// NotificationPreferences are per user and org. Users without a row get
+// the column defaults.
+type NotificationPreferences struct {
+	UserID                uuid.UUID  `json:"-"`
+	OrgID                 uuid.UUID  `json:"-"`
+	EndpointDisabled      bool       `json:"endpointDisabled"`
+	DeadLetters           bool       `json:"deadLetters"`
+	SuccessRate           bool       `json:"successRate"`
+	DigestIntervalMinutes int        `json:"digestIntervalMinutes"` // 15, 60 or 1440
+	LastDigestAt          *time.Time `json:"lastDigestAt"`
+}
+
+// Kinds lists the notification kinds the user wants.
+func (p *NotificationPreferences) Kinds() []string {
+	var kinds []string
+	if p.EndpointDisabled {
+		kinds = append(kinds, NotificationEndpointDisabled)
+	}
+	if p.DeadLetters {
+		kinds = append(kinds, NotificationDeadLetters)
+	}
+	if p.SuccessRate {
+		kinds = append(kinds, NotificationSuccessRate)
+	}
+	return kinds
+}
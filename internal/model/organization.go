@@ -11,8 +11,18 @@ type Organization struct {
 	Name          string    `json:"name"`
 	Slug          string    `json:"slug"`
 	SigningSecret string    `json:"-"`
-	CreatedAt     time.Time `json:"createdAt"`
-	UpdatedAt     time.Time `json:"updatedAt"`
+	// The previous secret keeps being signed with until it expires, so
+	// receivers have time to roll out a rotated secret.
+	PreviousSigningSecret   *string    `json:"-"`
+	PreviousSecretExpiresAt *time.Time `json:"previousSecretExpiresAt"`
+	MaxPayloadBytes         *int       `json:"maxPayloadBytes"`
+	PlanID                  string     `json:"planId"`
+	// MaxDeliveriesPerSecond caps the org's delivery rate when set.
+	MaxDeliveriesPerSecond *int      `json:"maxDeliveriesPerSecond"`
+	SignatureScheme        string    `json:"signatureScheme"`  // 'hmac', 'ed25519', 'both'
+	SignatureProfile       string    `json:"signatureProfile"` // 'chis', 'standard'
+	CreatedAt              time.Time `json:"createdAt"`
+	UpdatedAt              time.Time `json:"updatedAt"`
 }
 
 type Membership struct {
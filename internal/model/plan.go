@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Plan limits what an org may use. Nil limits are unlimited.
+type Plan struct {
+	ID                  string `json:"id"`
+	Name                string `json:"name"`
+	MonthlyMessageQuota *int   `json:"monthlyMessageQuota"`
+	MaxEndpoints        *int   `json:"maxEndpoints"`
+	MaxApiKeys          *int   `json:"maxApiKeys"`
+	RetentionDays       int    `json:"retentionDays"`
+	// MaxPayloadBytes falls back to the platform limit when nil.
+	MaxPayloadBytes *int      `json:"maxPayloadBytes"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// UsageDay is an org's metered usage for one UTC day.
+type UsageDay struct {
+	OrgID            uuid.UUID `json:"orgId"`
+	Day              time.Time `json:"day"`
+	Messages         int64     `json:"messages"`
+	PayloadBytes     int64     `json:"payloadBytes"`
+	DeliveryAttempts int64     `json:"deliveryAttempts"`
+}
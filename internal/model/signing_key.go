@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SigningKey struct {
+	ID         uuid.UUID  `json:"id"`
+	OrgID      uuid.UUID  `json:"orgId"`
+	PublicKey  string     `json:"publicKey"`
+	PrivateKey string     `json:"-"`
+	Status     string     `json:"status"` // 'active', 'retired'
+	RetiredAt  *time.Time `json:"retiredAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
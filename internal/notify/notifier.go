@@ -0,0 +1,182 @@
+// Package notify raises alerts about an org's deliveries and mails them to
+// org admins as digests, so an outage produces one email per admin per
+// digest interval rather than one per failure.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/email"
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+)
+
+const (
+	// CheckWindow is the period threshold checks look back over. Each kind
+	// of threshold alert fires at most once per window per org.
+	CheckWindow = time.Hour
+	// minAttempts keeps a handful of failures on a quiet org from counting
+	// as a success rate drop.
+	minAttempts    = 20
+	maxDigestItems = 20
+	checkInterval  = time.Minute
+)
+
+type Thresholds struct {
+	// DeadLetters is how many messages may exhaust their retries within
+	// CheckWindow before admins are told.
+	DeadLetters int
+	// SuccessRate is the percentage of attempts within CheckWindow below
+	// which admins are told.
+	SuccessRate int
+}
+
+type Notifier struct {
+	notificationRepo repository.NotificationRepository
+	messageRepo      repository.MessageRepository
+	emailService     *email.EmailService
+	thresholds       Thresholds
+}
+
+// NewNotifier returns a Notifier. Without an email service, alerts are
+// still recorded but no digests are sent.
+func NewNotifier(notificationRepo repository.NotificationRepository, messageRepo repository.MessageRepository,
+	emailService *email.EmailService, thresholds Thresholds,
+) *Notifier {
+	return &Notifier{
+		notificationRepo: notificationRepo,
+		messageRepo:      messageRepo,
+		emailService:     emailService,
+		thresholds:       thresholds,
+	}
+}
+
+func (n *Notifier) Start(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := n.checkThresholds(ctx); err != nil {
+			slog.Error("notify_check_failed", "error", err)
+		}
+		if err := n.sendDigests(ctx); err != nil {
+			slog.Error("notify_digest_failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *Notifier) checkThresholds(ctx context.Context) error {
+	since := time.Now().Add(-CheckWindow)
+	stats, err := n.messageRepo.FindOrgDeliveryStats(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range stats {
+		if n.thresholds.DeadLetters > 0 && s.DeadLetters >= n.thresholds.DeadLetters {
+			n.raise(ctx, s, model.NotificationDeadLetters, since,
+				fmt.Sprintf("%d messages exhausted their retries in the last hour.", s.DeadLetters))
+		}
+
+		if s.Attempts < minAttempts {
+			continue
+		}
+		rate := s.Succeeded * 100 / s.Attempts
+		if rate < n.thresholds.SuccessRate {
+			n.raise(ctx, s, model.NotificationSuccessRate, since,
+				fmt.Sprintf("Only %d%% of %d delivery attempts succeeded in the last hour.", rate, s.Attempts))
+		}
+	}
+	return nil
+}
+
+func (n *Notifier) raise(ctx context.Context, s repository.OrgDeliveryStats, kind string, since time.Time, summary string) {
+	exists, err := n.notificationRepo.ExistsSince(ctx, s.OrgID, kind, since)
+	if err != nil {
+		slog.Error("notify_lookup_failed", "org_id", s.OrgID, "kind", kind, "error", err)
+		return
+	}
+	if exists {
+		return
+	}
+
+	if err := n.notificationRepo.Create(ctx, &model.Notification{OrgID: s.OrgID, Kind: kind, Summary: summary}); err != nil {
+		slog.Error("notify_create_failed", "org_id", s.OrgID, "kind", kind, "error", err)
+		return
+	}
+	slog.Info("notification_raised", "org_id", s.OrgID, "kind", kind)
+}
+
+func (n *Notifier) sendDigests(ctx context.Context) error {
+	if n.emailService == nil {
+		return nil
+	}
+
+	recipients, err := n.notificationRepo.FindDueDigests(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range recipients {
+		prefs := r.Preferences
+		until := time.Now()
+
+		var (
+			notifications []*model.Notification
+			total         int
+		)
+		if kinds := prefs.Kinds(); len(kinds) > 0 {
+			notifications, total, err = n.notificationRepo.FindBetween(ctx, prefs.OrgID, kinds, r.Since, until, maxDigestItems)
+			if err != nil {
+				slog.Error("notify_digest_lookup_failed", "user_id", prefs.UserID, "org_id", prefs.OrgID, "error", err)
+				continue
+			}
+		}
+
+		if total > 0 {
+			digest := &email.Digest{FirstName: r.FirstName, OrgName: r.OrgName, Since: r.Since, Total: total}
+			for _, item := range notifications {
+				digest.Items = append(digest.Items, email.DigestItem{
+					Title:     Title(item.Kind),
+					Summary:   item.Summary,
+					CreatedAt: item.CreatedAt,
+				})
+			}
+			if err := n.emailService.SendDigest(r.Email, digest); err != nil {
+				// Leave the cursor alone so the next pass retries.
+				slog.Error("notify_digest_send_failed", "user_id", prefs.UserID, "org_id", prefs.OrgID, "error", err)
+				continue
+			}
+			slog.Info("notification_digest_sent", "user_id", prefs.UserID, "org_id", prefs.OrgID, "notifications", total)
+		}
+
+		// Muted kinds move the cursor too, so they are not looked at again.
+		if err := n.notificationRepo.MarkDigestSent(ctx, prefs.UserID, prefs.OrgID, until); err != nil {
+			slog.Error("notify_digest_mark_failed", "user_id", prefs.UserID, "org_id", prefs.OrgID, "error", err)
+		}
+	}
+	return nil
+}
+
+// Title is the short headline shown for a notification kind.
+func Title(kind string) string {
+	switch kind {
+	case model.NotificationEndpointDisabled:
+		return "Endpoint disabled"
+	case model.NotificationDeadLetters:
+		return "Dead letters piling up"
+	case model.NotificationSuccessRate:
+		return "Success rate dropped"
+	default:
+		return kind
+	}
+}
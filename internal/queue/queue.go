@@ -2,34 +2,464 @@ package queue
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/bilalabdelkadir/chis/internal/metrics"
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
+// errThrottled means every org with queued messages is at its delivery rate
+// cap.
+var errThrottled = errors.New("queue: all queued orgs are at their rate cap")
+
+// throttleWait is how long Pop waits before trying throttled orgs again.
+const throttleWait = 100 * time.Millisecond
+
+// maxSignals bounds the wake-up list idle workers block on.
+const maxSignals = 64
+
+// maxOverdue bounds how many overdue orgs Pop compares when picking one to
+// promote.
+const maxOverdue = 32
+
+// legacyRecheck is how long Pop waits before looking at the pre-fairness
+// lists again once they have been found empty.
+const legacyRecheck = time.Minute
+
+// lane is one priority. Every org with queued messages has its own list
+// under the lane and a place in the lane's ring, which Pop walks round-robin
+// so one org's backlog cannot hold up the others. legacy is the plain FIFO
+// list used before per-org queues; it is drained ahead of the lane so
+// messages queued back then are still picked up.
+type lane struct {
+	priority string
+	key      string
+	legacy   string
+	// weight is the lane's share of polls in which it is tried first.
+	weight int
+}
+
+// Queue keeps every key under the queue name as a hash tag, so on Redis
+// Cluster they share a slot and the scripts can touch all of them. The
+// scripts build per-org keys they can't declare up front; those rely on
+// the tag too, so any new key must start with it.
 type Queue struct {
 	rdsClient *redis.Client
 	name      string
+	tag       string
+	// lanes is ordered highest priority first.
+	lanes       []lane
+	totalWeight int
+	// quantum is how many messages Pop takes from one org before moving
+	// on to the next.
+	quantum int
+	// maxWait is how long an org's oldest message may wait before it is
+	// served ahead of its turn. Zero leaves it to the ring.
+	maxWait time.Duration
+	// legacyCheckAt is when Pop next looks at the legacy lists, in unix
+	// milliseconds.
+	legacyCheckAt atomic.Int64
+	now           func() time.Time
 }
 
-func NewQueue(client *redis.Client, queueName string) *Queue {
-	return &Queue{
+func NewQueue(client *redis.Client, queueName string, quantum int, maxWait time.Duration) *Queue {
+	tag := "{" + queueName + "}"
+	q := &Queue{
 		rdsClient: client,
 		name:      queueName,
+		tag:       tag,
+		lanes: []lane{
+			{priority: model.PriorityHigh, key: tag + ":high", legacy: queueName + ":high", weight: 6},
+			{priority: model.PriorityNormal, key: tag + ":normal", legacy: queueName, weight: 3},
+			{priority: model.PriorityLow, key: tag + ":low", legacy: queueName + ":low", weight: 1},
+		},
+		quantum: quantum,
+		maxWait: maxWait,
+		now:     time.Now,
 	}
+	for _, l := range q.lanes {
+		q.totalWeight += l.weight
+	}
+	return q
 }
 
-func (q *Queue) Push(ctx context.Context, messageID string) error {
-	if err := q.rdsClient.LPush(ctx, q.name, messageID).Err(); err != nil {
-		return err
-	}
-	return nil
+// pushScript queues an item on the org's list and puts the org in the
+// lane's ring if it was not there already. The lane's oldest set keeps each
+// org's oldest enqueue time, so it is only written when the org has none.
+//
+// KEYS: org list, lane active set, lane ring, lane oldest set, signal list
+// ARGV: item, org ID, max signals, now (unix ms)
+var pushScript = redis.NewScript(`
+redis.call('LPUSH', KEYS[1], ARGV[1])
+if redis.call('SADD', KEYS[2], ARGV[2]) == 1 then
+	redis.call('LPUSH', KEYS[3], ARGV[2])
+end
+redis.call('ZADD', KEYS[4], 'NX', ARGV[4], ARGV[2])
+redis.call('LPUSH', KEYS[5], '1')
+redis.call('LTRIM', KEYS[5], 0, tonumber(ARGV[3]) - 1)
+return 1
+`)
+
+// popScript takes the next item, visiting lanes in the given order. Within a
+// lane, an org whose oldest item has waited longer than max wait is served
+// first; if several have, the one with the fewest queued goes first, so a
+// backlog can't use this to jump ahead. Overdue orgs are found through the
+// lane's oldest set and only the maxOverdue longest waiting are compared,
+// so a pop stays cheap however many orgs are queued. Otherwise the org at
+// the tail of the ring is served until it has had quantum items or runs
+// dry, then the ring rotates. Orgs over their per-second cap are skipped.
+// Returns {lane, item}, {lane, item, 'promoted'}, 'throttled' or nil.
+//
+// Which orgs' lists and rate counters are touched is only known once the
+// script runs, so those keys are not in KEYS. They are named from the lane
+// keys and the tag, which carry the queue's hash tag, so on Redis Cluster
+// they share the declared keys' slot.
+//
+// KEYS: caps, then ring, active set, served hash and oldest set for each lane
+// ARGV: tag, quantum, now (unix ms), max wait (ms, 0 for none), max overdue,
+// lane keys...
+var popScript = redis.NewScript(`
+local tag = ARGV[1]
+local quantum = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local maxWait = tonumber(ARGV[4])
+local maxOverdue = tonumber(ARGV[5])
+local second = tostring(math.floor(now / 1000))
+local throttled = false
+
+-- Returns the org's cap, if any, and whether it has been reached.
+local function capped(org)
+	local cap = tonumber(redis.call('HGET', KEYS[1], org))
+	if not cap then
+		return nil, false
+	end
+	local rate = tag .. ':rate:' .. org .. ':' .. second
+	return cap, tonumber(redis.call('GET', rate) or '0') >= cap
+end
+
+local function charge(org, cap)
+	if cap then
+		local rate = tag .. ':rate:' .. org .. ':' .. second
+		redis.call('INCR', rate)
+		redis.call('EXPIRE', rate, 2)
+	end
+end
+
+local function retire(ring, active, served, oldest, org)
+	redis.call('LREM', ring, 1, org)
+	redis.call('SREM', active, org)
+	redis.call('HDEL', served, org)
+	redis.call('ZREM', oldest, org)
+end
+
+-- Moves the org's oldest time on to its new head item, if it has one.
+local function advance(list, oldest, org)
+	local head = redis.call('LINDEX', list, -1)
+	if not head then
+		redis.call('ZREM', oldest, org)
+		return false
+	end
+	local at = tonumber(string.match(head, '@(%d+)$'))
+	if at then
+		redis.call('ZADD', oldest, at, org)
+	end
+	return true
+end
+
+for i = 6, #ARGV do
+	local lane = ARGV[i]
+	local k = 2 + (i - 6) * 4
+	local ring, active, served, oldest = KEYS[k], KEYS[k + 1], KEYS[k + 2], KEYS[k + 3]
+
+	if maxWait > 0 then
+		local overdue, fewest
+		local due = redis.call('ZRANGEBYSCORE', oldest, '-inf', string.format('(%d', now - maxWait), 'LIMIT', 0, maxOverdue)
+		for _, org in ipairs(due) do
+			local n = redis.call('LLEN', lane .. ':org:' .. org)
+			local _, over = capped(org)
+			if n == 0 then
+				redis.call('ZREM', oldest, org)
+			elseif over then
+				throttled = true
+			elseif not fewest or n < fewest then
+				overdue, fewest = org, n
+			end
+		end
+		if overdue then
+			local cap = capped(overdue)
+			local list = lane .. ':org:' .. overdue
+			local item = redis.call('RPOP', list)
+			charge(overdue, cap)
+			if not advance(list, oldest, overdue) then
+				retire(ring, active, served, oldest, overdue)
+			end
+			return {lane, item, 'promoted'}
+		end
+	end
+
+	for _ = 1, redis.call('LLEN', ring) do
+		local org = redis.call('LINDEX', ring, -1)
+		if not org then
+			break
+		end
+		local list = lane .. ':org:' .. org
+		local cap, over = capped(org)
+
+		if over then
+			throttled = true
+			redis.call('HDEL', served, org)
+			redis.call('RPOPLPUSH', ring, ring)
+		else
+			local item = redis.call('RPOP', list)
+			if item then
+				charge(org, cap)
+				local n = redis.call('HINCRBY', served, org, 1)
+				if not advance(list, oldest, org) then
+					redis.call('RPOP', ring)
+					redis.call('SREM', active, org)
+					redis.call('HDEL', served, org)
+				elseif n >= quantum then
+					redis.call('HDEL', served, org)
+					redis.call('RPOPLPUSH', ring, ring)
+				end
+				return {lane, item}
+			end
+			-- The org's list was emptied behind our back.
+			redis.call('RPOP', ring)
+			redis.call('SREM', active, org)
+			redis.call('HDEL', served, org)
+			redis.call('ZREM', oldest, org)
+		end
+	end
+end
+
+if throttled then
+	return 'throttled'
+end
+return false
+`)
+
+// Push queues the message on its org's list in its priority's lane.
+// Unknown priorities go to the normal lane.
+func (q *Queue) Push(ctx context.Context, orgID uuid.UUID, messageID, priority string) error {
+	l := q.lane(priority)
+	org := orgID.String()
+	// The enqueue time rides along for the latency metric and max wait.
+	now := q.now().UnixMilli()
+	item := messageID + "@" + strconv.FormatInt(now, 10)
+
+	keys := []string{l.key + ":org:" + org, l.key + ":active", l.key + ":orgs", l.key + ":oldest", q.signalKey()}
+	return pushScript.Run(ctx, q.rdsClient, keys, item, org, maxSignals, now).Err()
 }
 
+// Pop takes the next message. Lanes are tried in a weighted random order,
+// so busy high priority lanes slow the low lane down without starving it,
+// and orgs within a lane take turns. With nothing queued, Pop blocks for a
+// few seconds waiting for a push and returns redis.Nil if none comes.
 func (q *Queue) Pop(ctx context.Context) (string, error) {
-	val := q.rdsClient.BRPop(ctx, 3*time.Second, q.name)
-	if val.Err() != nil {
-		return "", val.Err()
+	messageID, err := q.take(ctx)
+	if err == nil || !errors.Is(err, redis.Nil) {
+		if errors.Is(err, errThrottled) {
+			select {
+			case <-ctx.Done():
+			case <-time.After(throttleWait):
+			}
+		}
+		return messageID, err
+	}
+
+	if err := q.rdsClient.BRPop(ctx, 3*time.Second, q.signalKey()).Err(); err != nil {
+		return "", err
+	}
+	return q.take(ctx)
+}
+
+func (q *Queue) take(ctx context.Context) (string, error) {
+	order := q.pollOrder()
+	if messageID, ok := q.takeLegacy(ctx, order); ok {
+		return messageID, nil
+	}
+
+	keys := []string{q.capsKey()}
+	args := []any{q.tag, q.quantum, q.now().UnixMilli(), q.maxWait.Milliseconds(), maxOverdue}
+	for _, l := range order {
+		keys = append(keys, l.key+":orgs", l.key+":active", l.key+":served", l.key+":oldest")
+		args = append(args, l.key)
+	}
+
+	res, err := popScript.Run(ctx, q.rdsClient, keys, args...).Result()
+	if err != nil {
+		return "", err
+	}
+
+	val, ok := res.([]any)
+	if !ok || len(val) < 2 {
+		return "", errThrottled
+	}
+	key, _ := val[0].(string)
+	item, _ := val[1].(string)
+	l := q.laneByKey(key)
+	if len(val) == 3 {
+		metrics.QueuePromotionsTotal.WithLabelValues(l.priority).Inc()
+	}
+
+	messageID, queuedAt, ok := strings.Cut(item, "@")
+	if ok {
+		if ms, err := strconv.ParseInt(queuedAt, 10, 64); err == nil {
+			metrics.QueueLatency.WithLabelValues(l.priority).
+				Observe(float64(q.now().UnixMilli() - ms))
+		}
+	}
+	return messageID, nil
+}
+
+// takeLegacy pops from the pre-fairness lists. Once they are all empty it
+// only looks again after legacyRecheck.
+func (q *Queue) takeLegacy(ctx context.Context, order []lane) (string, bool) {
+	now := q.now().UnixMilli()
+	if now < q.legacyCheckAt.Load() {
+		return "", false
+	}
+	for _, l := range order {
+		item, err := q.rdsClient.RPop(ctx, l.legacy).Result()
+		if err == nil {
+			messageID, _, _ := strings.Cut(item, "@")
+			return messageID, true
+		}
+		if !errors.Is(err, redis.Nil) {
+			slog.Error("queue_legacy_pop_failed", "lane", l.priority, "error", err)
+			return "", false
+		}
+	}
+	q.legacyCheckAt.Store(now + legacyRecheck.Milliseconds())
+	return "", false
+}
+
+// SetOrgRate caps how many of the org's messages Pop hands out per second.
+// A nil rate removes the cap.
+func (q *Queue) SetOrgRate(ctx context.Context, orgID uuid.UUID, perSecond *int) error {
+	if perSecond == nil {
+		return q.rdsClient.HDel(ctx, q.capsKey(), orgID.String()).Err()
+	}
+	return q.rdsClient.HSet(ctx, q.capsKey(), orgID.String(), *perSecond).Err()
+}
+
+// SyncOrgRates replaces every org's cap with rates, so caps lost with Redis
+// or set while it was unreachable come back.
+func (q *Queue) SyncOrgRates(ctx context.Context, rates map[uuid.UUID]int) error {
+	_, err := q.rdsClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, q.capsKey())
+		if len(rates) > 0 {
+			values := make(map[string]any, len(rates))
+			for orgID, perSecond := range rates {
+				values[orgID.String()] = perSecond
+			}
+			pipe.HSet(ctx, q.capsKey(), values)
+		}
+		return nil
+	})
+	return err
+}
+
+// ReportDepth publishes each lane's length and number of orgs waiting
+// until ctx is done.
+func (q *Queue) ReportDepth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, l := range q.lanes {
+			depth, orgs, err := q.depth(ctx, l)
+			if err != nil {
+				slog.Error("queue_depth_failed", "lane", l.priority, "error", err)
+				continue
+			}
+			metrics.QueueDepth.WithLabelValues(l.priority).Set(float64(depth))
+			metrics.QueueActiveOrgs.WithLabelValues(l.priority).Set(float64(orgs))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *Queue) depth(ctx context.Context, l lane) (int64, int, error) {
+	orgs, err := q.rdsClient.SMembers(ctx, l.key+":active").Result()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pipe := q.rdsClient.Pipeline()
+	legacy := pipe.LLen(ctx, l.legacy)
+	lens := make([]*redis.IntCmd, len(orgs))
+	for i, org := range orgs {
+		lens[i] = pipe.LLen(ctx, l.key+":org:"+org)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, err
+	}
+
+	depth := legacy.Val()
+	for _, n := range lens {
+		depth += n.Val()
+	}
+	return depth, len(orgs), nil
+}
+
+func (q *Queue) signalKey() string {
+	return q.tag + ":signal"
+}
+
+func (q *Queue) capsKey() string {
+	return q.tag + ":caps"
+}
+
+func (q *Queue) pollOrder() []lane {
+	pick := rand.IntN(q.totalWeight)
+	first := 0
+	for i, l := range q.lanes {
+		if pick < l.weight {
+			first = i
+			break
+		}
+		pick -= l.weight
+	}
+
+	order := make([]lane, 0, len(q.lanes))
+	order = append(order, q.lanes[first])
+	for i, l := range q.lanes {
+		if i != first {
+			order = append(order, l)
+		}
+	}
+	return order
+}
+
+func (q *Queue) lane(priority string) lane {
+	for _, l := range q.lanes {
+		if l.priority == priority {
+			return l
+		}
+	}
+	return q.lanes[1]
+}
+
+func (q *Queue) laneByKey(key string) lane {
+	for _, l := range q.lanes {
+		if l.key == key {
+			return l
+		}
 	}
-	return val.Val()[1], nil
+	return q.lanes[1]
 }
@@ -0,0 +1,229 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	orgA = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	orgB = uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+)
+
+// testQueue is a queue on miniredis whose clock only moves when the test
+// moves it.
+type testQueue struct {
+	*Queue
+	redis *miniredis.Miniredis
+	clock time.Time
+}
+
+func newTestQueue(t *testing.T, quantum int, maxWait time.Duration) *testQueue {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	tq := &testQueue{
+		Queue: NewQueue(client, "webhooks", quantum, maxWait),
+		redis: mr,
+		clock: time.UnixMilli(1_700_000_000_000),
+	}
+	tq.now = func() time.Time { return tq.clock }
+	return tq
+}
+
+func (tq *testQueue) push(t *testing.T, org uuid.UUID, priority string, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if err := tq.Push(context.Background(), org, id, priority); err != nil {
+			t.Fatalf("Push(%s): %v", id, err)
+		}
+	}
+}
+
+// drain takes messages until the queue is empty or throttled.
+func (tq *testQueue) drain(t *testing.T) []string {
+	t.Helper()
+	var got []string
+	for {
+		id, err := tq.take(context.Background())
+		if errors.Is(err, redis.Nil) || errors.Is(err, errThrottled) {
+			return got
+		}
+		if err != nil {
+			t.Fatalf("take: %v", err)
+		}
+		got = append(got, id)
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	tests := []struct {
+		quantum int
+		want    []string
+	}{
+		{1, []string{"a1", "b1", "a2", "b2", "a3", "a4", "a5"}},
+		{2, []string{"a1", "a2", "b1", "b2", "a3", "a4", "a5"}},
+		{3, []string{"a1", "a2", "a3", "b1", "b2", "a4", "a5"}},
+		{10, []string{"a1", "a2", "a3", "a4", "a5", "b1", "b2"}},
+	}
+	for _, tt := range tests {
+		q := newTestQueue(t, tt.quantum, 0)
+		q.push(t, orgA, model.PriorityNormal, "a1", "a2", "a3", "a4", "a5")
+		q.push(t, orgB, model.PriorityNormal, "b1", "b2")
+
+		if got := q.drain(t); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("quantum %d: got %v, want %v", tt.quantum, got, tt.want)
+		}
+		if q.redis.Exists("{webhooks}:normal:orgs") || q.redis.Exists("{webhooks}:normal:active") {
+			t.Errorf("quantum %d: drained lane still lists orgs", tt.quantum)
+		}
+	}
+}
+
+func TestRateCap(t *testing.T) {
+	ctx := context.Background()
+	q := newTestQueue(t, 5, 0)
+	one := 1
+	if err := q.SetOrgRate(ctx, orgA, &one); err != nil {
+		t.Fatalf("SetOrgRate: %v", err)
+	}
+	q.push(t, orgA, model.PriorityNormal, "a1", "a2", "a3")
+	q.push(t, orgB, model.PriorityNormal, "b1")
+
+	// A gets one message this second and B is served while A waits.
+	if got, want := q.drain(t), []string{"a1", "b1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("first second: got %v, want %v", got, want)
+	}
+	if _, err := q.take(ctx); !errors.Is(err, errThrottled) {
+		t.Fatalf("take with only capped orgs queued: got %v, want errThrottled", err)
+	}
+
+	q.clock = q.clock.Add(time.Second)
+	if got, want := q.drain(t), []string{"a2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("next second: got %v, want %v", got, want)
+	}
+
+	if err := q.SyncOrgRates(ctx, nil); err != nil {
+		t.Fatalf("SyncOrgRates: %v", err)
+	}
+	if got, want := q.drain(t), []string{"a3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("after caps cleared: got %v, want %v", got, want)
+	}
+	if _, err := q.take(ctx); !errors.Is(err, redis.Nil) {
+		t.Fatalf("take on empty queue: got %v, want redis.Nil", err)
+	}
+}
+
+func TestMaxWaitPromotion(t *testing.T) {
+	const oldest = "{webhooks}:normal:oldest"
+	q := newTestQueue(t, 10, time.Second)
+	start := q.clock
+
+	q.push(t, orgA, model.PriorityNormal, "a1", "a2", "a3")
+	q.clock = start.Add(100 * time.Millisecond)
+	q.push(t, orgB, model.PriorityNormal, "b1")
+
+	// Nothing has waited past max wait, so A keeps its turn.
+	q.clock = start.Add(500 * time.Millisecond)
+	if got, _ := q.take(context.Background()); got != "a1" {
+		t.Fatalf("before max wait: got %q, want a1", got)
+	}
+
+	// Both are overdue now and B, with fewer queued, goes first.
+	q.clock = start.Add(1500 * time.Millisecond)
+	if got, _ := q.take(context.Background()); got != "b1" {
+		t.Fatalf("after max wait: got %q, want b1", got)
+	}
+	if members, _ := q.redis.ZMembers(oldest); !reflect.DeepEqual(members, []string{orgA.String()}) {
+		t.Errorf("%s = %v, want only org A once B is drained", oldest, members)
+	}
+
+	// A's next message was queued at start, so its oldest time stays there.
+	if score, err := q.redis.ZScore(oldest, orgA.String()); err != nil || int64(score) != start.UnixMilli() {
+		t.Errorf("org A oldest = %v, %v; want %d", score, err, start.UnixMilli())
+	}
+	if got, want := q.drain(t), []string{"a2", "a3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("rest: got %v, want %v", got, want)
+	}
+	if q.redis.Exists(oldest) {
+		t.Errorf("drained lane still has %s", oldest)
+	}
+}
+
+func TestMaxWaitTracksHead(t *testing.T) {
+	const oldest = "{webhooks}:normal:oldest"
+	q := newTestQueue(t, 10, time.Second)
+	start := q.clock
+
+	q.push(t, orgA, model.PriorityNormal, "a1")
+	q.clock = start.Add(2 * time.Second)
+	q.push(t, orgA, model.PriorityNormal, "a2")
+
+	q.clock = start.Add(2500 * time.Millisecond)
+	if got, _ := q.take(context.Background()); got != "a1" {
+		t.Fatalf("got %q, want a1", got)
+	}
+	want := start.Add(2 * time.Second).UnixMilli()
+	if score, err := q.redis.ZScore(oldest, orgA.String()); err != nil || int64(score) != want {
+		t.Errorf("org A oldest = %v, %v; want %d", score, err, want)
+	}
+}
+
+func TestLaneWeighting(t *testing.T) {
+	const polls = 20000
+	q := newTestQueue(t, 1, 0)
+	first := map[string]int{}
+	for range polls {
+		order := q.pollOrder()
+		if len(order) != 3 {
+			t.Fatalf("poll order has %d lanes, want 3", len(order))
+		}
+		seen := map[string]bool{}
+		for _, l := range order {
+			seen[l.priority] = true
+		}
+		if len(seen) != 3 {
+			t.Fatalf("poll order %v repeats a lane", order)
+		}
+		first[order[0].priority]++
+	}
+
+	want := map[string]float64{
+		model.PriorityHigh:   0.6,
+		model.PriorityNormal: 0.3,
+		model.PriorityLow:    0.1,
+	}
+	for priority, share := range want {
+		got := float64(first[priority]) / polls
+		if math.Abs(got-share) > 0.02 {
+			t.Errorf("%s lane first in %.3f of polls, want about %.1f", priority, got, share)
+		}
+	}
+}
+
+func TestPushLane(t *testing.T) {
+	q := newTestQueue(t, 1, 0)
+	q.push(t, orgA, model.PriorityHigh, "h1")
+	q.push(t, orgA, "urgent", "n1")
+
+	for key, want := range map[string]int{
+		"{webhooks}:high:org:" + orgA.String():   1,
+		"{webhooks}:normal:org:" + orgA.String(): 1,
+	} {
+		list, err := q.redis.List(key)
+		if err != nil || len(list) != want {
+			t.Errorf("%s = %v, %v; want %d items", key, list, err, want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Package relay hands deliveries for relay endpoints to a developer's
+// `chis listen` session over Redis instead of sending them over HTTP.
+//
+// The worker pushes each request onto the endpoint's list and blocks on a
+// per-delivery response key; the API's long-poll pops requests for the CLI
+// and pushes the local server's response back.
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// ListenerTTL is how long a listener counts as connected after its last
+// poll. Polls must be shorter than this.
+const ListenerTTL = 40 * time.Second
+
+var ErrNoListener = errors.New("no listener connected for relay endpoint")
+
+type Request struct {
+	ID        string      `json:"id"`
+	MessageID string      `json:"messageId"`
+	Method    string      `json:"method"`
+	URL       string      `json:"url"`
+	Headers   http.Header `json:"headers"`
+	Body      []byte      `json:"body"`
+	// Deadline drops requests whose worker has stopped waiting.
+	Deadline time.Time `json:"deadline"`
+}
+
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+	// Error is set when the local server could not be reached.
+	Error string `json:"error,omitempty"`
+}
+
+type Relay struct {
+	rdb *redis.Client
+}
+
+func New(rdb *redis.Client) *Relay {
+	return &Relay{rdb: rdb}
+}
+
+func requestsKey(endpointID uuid.UUID) string { return "relay:" + endpointID.String() + ":requests" }
+func listenerKey(endpointID uuid.UUID) string { return "relay:" + endpointID.String() + ":listener" }
+func responseKey(endpointID uuid.UUID, requestID string) string {
+	return "relay:" + endpointID.String() + ":response:" + requestID
+}
+
+// Forward sends req to the endpoint's listener and waits up to timeout for
+// its response.
+func (r *Relay) Forward(ctx context.Context, endpointID uuid.UUID, req *Request, timeout time.Duration) (*Response, error) {
+	n, err := r.rdb.Exists(ctx, listenerKey(endpointID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrNoListener
+	}
+
+	req.ID = uuid.NewString()
+	req.Deadline = time.Now().Add(timeout)
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	key := requestsKey(endpointID)
+	if err := r.rdb.LPush(ctx, key, data).Err(); err != nil {
+		return nil, err
+	}
+	r.rdb.Expire(ctx, key, ListenerTTL)
+
+	res, err := r.rdb.BRPop(ctx, timeout, responseKey(endpointID, req.ID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("listener did not respond within %s", timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resp Response
+	if err := json.Unmarshal([]byte(res[1]), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Next marks the listener as connected and waits up to wait for a request.
+// It returns nil when none arrived.
+func (r *Relay) Next(ctx context.Context, endpointID uuid.UUID, wait time.Duration) (*Request, error) {
+	if err := r.rdb.Set(ctx, listenerKey(endpointID), "1", ListenerTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		remaining := time.Until(deadline)
+		if remaining < time.Second {
+			return nil, nil
+		}
+
+		res, err := r.rdb.BRPop(ctx, remaining, requestsKey(endpointID)).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var req Request
+		if err := json.Unmarshal([]byte(res[1]), &req); err != nil {
+			continue
+		}
+		if time.Now().After(req.Deadline) {
+			continue
+		}
+		return &req, nil
+	}
+}
+
+// Respond hands the listener's response to the waiting worker.
+func (r *Relay) Respond(ctx context.Context, endpointID uuid.UUID, requestID string, resp *Response) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	key := responseKey(endpointID, requestID)
+	if err := r.rdb.LPush(ctx, key, data).Err(); err != nil {
+		return err
+	}
+	return r.rdb.Expire(ctx, key, ListenerTTL).Err()
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// attemptFacts is one row per delivery attempt with the dimensions rollups
+// are keyed on. See delivery_rollups_hourly for how outcomes are decided.
+const attemptFacts = `
+	SELECT m.org_id,
+		da.attempted_at,
+		COALESCE(m.endpoint_id, '00000000-0000-0000-0000-000000000000') AS endpoint_id,
+		COALESCE(m.event_type, '') AS event_type,
+		COALESCE(da.status_code, 0) AS status_code,
+		latency_bucket(da.duration_ms) AS latency_bucket,
+		CASE
+			WHEN da.status_code BETWEEN 200 AND 299 THEN 'succeeded'
+			WHEN m.status = 'failed' AND da.attempt_number > m.attempt_count THEN 'failed'
+			ELSE 'retried'
+		END AS outcome
+	FROM delivery_attempts da
+	JOIN messages m ON m.id = da.message_id
+`
+
+type PostgresAnalyticsRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAnalyticsRepository(pool *pgxpool.Pool) AnalyticsRepository {
+	return &PostgresAnalyticsRepository{
+		pool: pool,
+	}
+}
+
+// RefreshRollups recomputes the hourly rollups for attempts in [from, to),
+// which must be whole hours, and returns how many rollup rows were written.
+// It records to as the point rollups are current up to.
+func (r *PostgresAnalyticsRepository) RefreshRollups(ctx context.Context, from, to time.Time) (int64, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM delivery_rollups_hourly WHERE hour >= $1 AND hour < $2
+	`, from, to); err != nil {
+		return 0, err
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO delivery_rollups_hourly
+			(org_id, hour, endpoint_id, event_type, status_code, latency_bucket, succeeded, retried, failed)
+		SELECT org_id, date_trunc('hour', attempted_at), endpoint_id, event_type, status_code, latency_bucket,
+			COUNT(*) FILTER (WHERE outcome = 'succeeded'),
+			COUNT(*) FILTER (WHERE outcome = 'retried'),
+			COUNT(*) FILTER (WHERE outcome = 'failed')
+		FROM (`+attemptFacts+`
+			WHERE da.attempted_at >= $1 AND da.attempted_at < $2
+		) a
+		GROUP BY 1, 2, 3, 4, 5, 6
+	`, from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE rollup_progress SET rolled_up_to = GREATEST(rolled_up_to, $1)
+	`, to); err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), tx.Commit(ctx)
+}
+
+// FindRollupProgress returns the point the rollups are current up to.
+func (r *PostgresAnalyticsRepository) FindRollupProgress(ctx context.Context) (time.Time, error) {
+	var to time.Time
+	err := r.pool.QueryRow(ctx, `SELECT rolled_up_to FROM rollup_progress`).Scan(&to)
+	return to, err
+}
+
+// FindTimeseries counts the org's attempts per bucket, status code and
+// latency bucket. Whole-hour buckets are read from the hourly rollups;
+// shorter ones from the attempts themselves, so callers should keep their
+// range short. Buckets are aligned to UTC midnight; empty ones are left out.
+func (r *PostgresAnalyticsRepository) FindTimeseries(ctx context.Context, f TimeseriesFilter) ([]TimeseriesRow, error) {
+	source := `delivery_rollups_hourly`
+	timeCol := `hour`
+	sums := `SUM(succeeded)::bigint, SUM(retried)::bigint, SUM(failed)::bigint`
+	if f.Bucket%time.Hour != 0 {
+		source = `(` + attemptFacts + `) a`
+		timeCol = `attempted_at`
+		sums = `COUNT(*) FILTER (WHERE outcome = 'succeeded'),
+			COUNT(*) FILTER (WHERE outcome = 'retried'),
+			COUNT(*) FILTER (WHERE outcome = 'failed')`
+	}
+
+	args := []any{f.OrgID, f.From, f.To, f.Bucket}
+	where := fmt.Sprintf("WHERE org_id = $1 AND %s >= $2 AND %s < $3", timeCol, timeCol)
+	if f.EndpointID != nil {
+		args = append(args, *f.EndpointID)
+		where += fmt.Sprintf(" AND endpoint_id = $%d", len(args))
+	}
+	if f.EventType != "" {
+		args = append(args, f.EventType)
+		where += fmt.Sprintf(" AND event_type = $%d", len(args))
+	}
+
+	rows, err := r.pool.Query(ctx, fmt.Sprintf(`
+		SELECT date_bin($4::interval, %s, TIMESTAMPTZ '2000-01-01 00:00:00+00') AS bucket,
+			status_code, latency_bucket, %s
+		FROM %s
+		%s
+		GROUP BY 1, 2, 3
+		ORDER BY 1, 2, 3
+	`, timeCol, sums, source, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []TimeseriesRow
+	for rows.Next() {
+		var row TimeseriesRow
+		if err := rows.Scan(&row.Bucket, &row.StatusCode, &row.LatencyBucket, &row.Succeeded, &row.Retried, &row.Failed); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// FindEndpointReport totals the org's attempts in [from, to), whole hours,
+// per endpoint and latency bucket from the hourly rollups.
+func (r *PostgresAnalyticsRepository) FindEndpointReport(ctx context.Context, orgID uuid.UUID, from, to time.Time) ([]EndpointReportRow, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT ro.endpoint_id, e.url, ro.latency_bucket,
+			SUM(ro.succeeded + ro.retried + ro.failed)::bigint,
+			SUM(ro.retried + ro.failed)::bigint,
+			SUM(ro.failed)::bigint
+		FROM delivery_rollups_hourly ro
+		LEFT JOIN endpoints e ON e.id = ro.endpoint_id
+		WHERE ro.org_id = $1 AND ro.hour >= $2 AND ro.hour < $3
+			AND ro.endpoint_id <> '00000000-0000-0000-0000-000000000000'
+		GROUP BY ro.endpoint_id, e.url, ro.latency_bucket
+	`, orgID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []EndpointReportRow
+	for rows.Next() {
+		var row EndpointReportRow
+		if err := rows.Scan(&row.EndpointID, &row.URL, &row.LatencyBucket, &row.Attempts, &row.Failures, &row.DeadLetters); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// FindApiKeyReport counts the messages the org created in [from, to) per
+// API key, busiest first.
+func (r *PostgresAnalyticsRepository) FindApiKeyReport(ctx context.Context, orgID uuid.UUID, from, to time.Time, limit int) ([]ApiKeyReportRow, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT m.api_key_id, k.name, k.prefix,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE m.status = 'success'),
+			COUNT(*) FILTER (WHERE m.status = 'failed')
+		FROM messages m
+		LEFT JOIN api_keys k ON k.id = m.api_key_id
+		WHERE m.org_id = $1 AND m.created_at >= $2 AND m.created_at < $3
+		GROUP BY m.api_key_id, k.name, k.prefix
+		ORDER BY COUNT(*) DESC
+		LIMIT $4
+	`, orgID, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []ApiKeyReportRow
+	for rows.Next() {
+		var row ApiKeyReportRow
+		if err := rows.Scan(&row.ApiKeyID, &row.Name, &row.Prefix, &row.Messages, &row.Succeeded, &row.Failed); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/bilalabdelkadir/chis/internal/model"
 	"github.com/google/uuid"
@@ -61,13 +62,25 @@ func (r *PostgresApiKeyRepository) FindByHashedKey(ctx context.Context, hashedKe
 	return apiKey, nil
 }
 
-func (r *PostgresApiKeyRepository) FindByOrgID(ctx context.Context, orgID uuid.UUID) ([]*model.APIKey, error) {
-	rows, err := r.pool.Query(ctx, `
+// FindByOrgID returns a page of the org's keys, newest first.
+func (r *PostgresApiKeyRepository) FindByOrgID(ctx context.Context, orgID uuid.UUID, page PageRequest) (*Page[*model.APIKey], error) {
+	where := "WHERE org_id = $1"
+	args := []any{orgID}
+
+	dataWhere, dataArgs := where, args
+	if cond, keysetArgs := page.keysetCondition("created_at", "id", false, args); cond != "" {
+		dataWhere += " AND " + cond
+		dataArgs = keysetArgs
+	}
+	dataArgs = append(dataArgs, page.limit()+1)
+
+	rows, err := r.pool.Query(ctx, fmt.Sprintf(`
 		SELECT id, org_id, name, prefix, expires_at, last_used_at, created_at, updated_at
 		FROM api_keys
-		WHERE org_id = $1
-		ORDER BY created_at DESC
-	`, orgID)
+		%s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d
+	`, dataWhere, len(dataArgs)), dataArgs...)
 	if err != nil {
 		return nil, err
 	}
@@ -84,8 +97,23 @@ func (r *PostgresApiKeyRepository) FindByOrgID(ctx context.Context, orgID uuid.U
 		}
 		keys = append(keys, k)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := newPage(keys, page, func(k *model.APIKey) Cursor {
+		return Cursor{Time: k.CreatedAt, ID: k.ID}
+	})
+	if err := countTotal(ctx, r.pool, result, page, "FROM api_keys "+where, args); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 
-	return keys, rows.Err()
+func (r *PostgresApiKeyRepository) CountByOrgID(ctx context.Context, orgID uuid.UUID) (int, error) {
+	var count int
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM api_keys WHERE org_id = $1`, orgID).Scan(&count)
+	return count, err
 }
 
 func (r *PostgresApiKeyRepository) Delete(ctx context.Context, id uuid.UUID) error {
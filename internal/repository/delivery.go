@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/bilalabdelkadir/chis/internal/model"
 	"github.com/google/uuid"
@@ -20,7 +21,7 @@ func NewDeliveryAttemptsRepository(pool *pgxpool.Pool) DeliveryAttemptRepository
 
 func (r *PostgresDeliveryAttemptsRepository) FindByMessageID(ctx context.Context, messageID uuid.UUID) ([]*model.DeliveryAttempt, error) {
 	rows, err := r.pool.Query(ctx, `
-		SELECT id, message_id, attempt_number, status_code, response_body, error_message, duration_ms, attempted_at
+		SELECT id, message_id, attempt_number, status_code, response_body, response_truncated, error_message, duration_ms, attempted_at
 		FROM delivery_attempts
 		WHERE message_id = $1
 		ORDER BY attempt_number ASC
@@ -33,7 +34,7 @@ func (r *PostgresDeliveryAttemptsRepository) FindByMessageID(ctx context.Context
 	var attempts []*model.DeliveryAttempt
 	for rows.Next() {
 		a := &model.DeliveryAttempt{}
-		err := rows.Scan(&a.ID, &a.MessageID, &a.AttemptNumber, &a.StatusCode, &a.ResponseBody, &a.ErrorMessage, &a.DurationMS, &a.AttemptedAt)
+		err := rows.Scan(&a.ID, &a.MessageID, &a.AttemptNumber, &a.StatusCode, &a.ResponseBody, &a.ResponseTruncated, &a.ErrorMessage, &a.DurationMS, &a.AttemptedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -43,10 +44,56 @@ func (r *PostgresDeliveryAttemptsRepository) FindByMessageID(ctx context.Context
 	return attempts, rows.Err()
 }
 
+// ListByMessageID returns a page of the message's attempts, oldest first.
+func (r *PostgresDeliveryAttemptsRepository) ListByMessageID(ctx context.Context, messageID uuid.UUID, page PageRequest) (*Page[*model.DeliveryAttempt], error) {
+	where := "WHERE message_id = $1"
+	args := []any{messageID}
+
+	dataWhere, dataArgs := where, args
+	if cond, keysetArgs := page.keysetCondition("attempted_at", "id", true, args); cond != "" {
+		dataWhere += " AND " + cond
+		dataArgs = keysetArgs
+	}
+	dataArgs = append(dataArgs, page.limit()+1)
+
+	rows, err := r.pool.Query(ctx, fmt.Sprintf(`
+		SELECT id, message_id, attempt_number, status_code, response_body, response_truncated, error_message, duration_ms, attempted_at
+		FROM delivery_attempts
+		%s
+		ORDER BY attempted_at ASC, id ASC
+		LIMIT $%d
+	`, dataWhere, len(dataArgs)), dataArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []*model.DeliveryAttempt
+	for rows.Next() {
+		a := &model.DeliveryAttempt{}
+		err := rows.Scan(&a.ID, &a.MessageID, &a.AttemptNumber, &a.StatusCode, &a.ResponseBody, &a.ResponseTruncated, &a.ErrorMessage, &a.DurationMS, &a.AttemptedAt)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := newPage(attempts, page, func(a *model.DeliveryAttempt) Cursor {
+		return Cursor{Time: a.AttemptedAt, ID: a.ID}
+	})
+	if err := countTotal(ctx, r.pool, result, page, "FROM delivery_attempts "+where, args); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 func (r *PostgresDeliveryAttemptsRepository) Create(ctx context.Context, deliveryAttempt *model.DeliveryAttempt) error {
 	err := r.pool.QueryRow(ctx, `
-		INSERT INTO delivery_attempts (message_id, attempt_number,status_code,response_body, error_message, duration_ms)
-		VALUES ($1,$2,$3,$4,$5,$6)
+		INSERT INTO delivery_attempts (message_id, attempt_number,status_code,response_body, response_truncated, error_message, duration_ms)
+		VALUES ($1,$2,$3,$4,$5,$6,$7)
 		RETURNING id, attempted_at
 
 	`,
@@ -54,6 +101,7 @@ func (r *PostgresDeliveryAttemptsRepository) Create(ctx context.Context, deliver
 		deliveryAttempt.AttemptNumber,
 		deliveryAttempt.StatusCode,
 		deliveryAttempt.ResponseBody,
+		deliveryAttempt.ResponseTruncated,
 		deliveryAttempt.ErrorMessage,
 		deliveryAttempt.DurationMS,
 	).Scan(&deliveryAttempt.ID, &deliveryAttempt.AttemptedAt)
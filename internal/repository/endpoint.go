@@ -0,0 +1,352 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresEndpointRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewEndpointRepository(pool *pgxpool.Pool) EndpointRepository {
+	return &PostgresEndpointRepository{
+		pool: pool,
+	}
+}
+
+func (r *PostgresEndpointRepository) Create(ctx context.Context, endpoint *model.Endpoint) error {
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO endpoints (org_id, url, content_encoding, relay, operational, timeout_ms)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`,
+		endpoint.OrgID,
+		endpoint.URL,
+		endpoint.ContentEncoding,
+		endpoint.Relay,
+		endpoint.Operational,
+		endpoint.TimeoutMS,
+	).Scan(&endpoint.ID, &endpoint.CreatedAt, &endpoint.UpdatedAt)
+
+	// The org already has an endpoint for this URL.
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// endpointColumns matches the scan order of scanEndpoint.
+const endpointColumns = `id, org_id, url, content_encoding, relay, operational, timeout_ms, signing_secret, previous_signing_secret,
+	previous_secret_expires_at, consecutive_failures, failing_since, last_success_at, disabled_at,
+	disabled_reason, created_at, updated_at`
+
+func scanEndpoint(row pgx.Row) (*model.Endpoint, error) {
+	e := &model.Endpoint{}
+	err := row.Scan(
+		&e.ID,
+		&e.OrgID,
+		&e.URL,
+		&e.ContentEncoding,
+		&e.Relay,
+		&e.Operational,
+		&e.TimeoutMS,
+		&e.SigningSecret,
+		&e.PreviousSigningSecret,
+		&e.PreviousSecretExpiresAt,
+		&e.ConsecutiveFailures,
+		&e.FailingSince,
+		&e.LastSuccessAt,
+		&e.DisabledAt,
+		&e.DisabledReason,
+		&e.CreatedAt,
+		&e.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return e, nil
+}
+
+// Upsert returns the endpoint registered for url, creating it with default
+// settings the first time the org sends to that URL. An existing endpoint
+// is only read, so concurrent sends to it never wait on each other.
+func (r *PostgresEndpointRepository) Upsert(ctx context.Context, orgID uuid.UUID, url string) (*model.Endpoint, error) {
+	endpoint, err := scanEndpoint(r.pool.QueryRow(ctx, `
+		INSERT INTO endpoints (org_id, url)
+		VALUES ($1, $2)
+		ON CONFLICT (org_id, url) DO NOTHING
+		RETURNING `+endpointColumns, orgID, url))
+	if errors.Is(err, ErrNotFound) {
+		return r.FindByURL(ctx, orgID, url)
+	}
+	return endpoint, err
+}
+
+func (r *PostgresEndpointRepository) FindByURL(ctx context.Context, orgID uuid.UUID, url string) (*model.Endpoint, error) {
+	return scanEndpoint(r.pool.QueryRow(ctx, `
+		SELECT `+endpointColumns+`
+		FROM endpoints
+		WHERE org_id = $1 AND url = $2
+	`, orgID, url))
+}
+
+func (r *PostgresEndpointRepository) CountByOrgID(ctx context.Context, orgID uuid.UUID) (int, error) {
+	var count int
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM endpoints WHERE org_id = $1`, orgID).Scan(&count)
+	return count, err
+}
+
+func (r *PostgresEndpointRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.Endpoint, error) {
+	return scanEndpoint(r.pool.QueryRow(ctx, `
+		SELECT `+endpointColumns+`
+		FROM endpoints
+		WHERE id = $1
+	`, id))
+}
+
+func (r *PostgresEndpointRepository) FindByOrgID(ctx context.Context, orgID uuid.UUID) ([]*model.Endpoint, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+endpointColumns+`
+		FROM endpoints
+		WHERE org_id = $1
+		ORDER BY created_at DESC
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var endpoints []*model.Endpoint
+	for rows.Next() {
+		e, err := scanEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, e)
+	}
+
+	return endpoints, rows.Err()
+}
+
+// FindOperational returns the org's enabled endpoints that receive system
+// events.
+func (r *PostgresEndpointRepository) FindOperational(ctx context.Context, orgID uuid.UUID) ([]*model.Endpoint, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+endpointColumns+`
+		FROM endpoints
+		WHERE org_id = $1 AND operational AND disabled_at IS NULL
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var endpoints []*model.Endpoint
+	for rows.Next() {
+		e, err := scanEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, e)
+	}
+
+	return endpoints, rows.Err()
+}
+
+func (r *PostgresEndpointRepository) Update(ctx context.Context, endpoint *model.Endpoint) error {
+	err := r.pool.QueryRow(ctx, `
+		UPDATE endpoints
+		SET content_encoding = $1, relay = $2, operational = $3, timeout_ms = $4
+		WHERE id = $5
+		RETURNING updated_at
+	`, endpoint.ContentEncoding, endpoint.Relay, endpoint.Operational, endpoint.TimeoutMS, endpoint.ID).Scan(&endpoint.UpdatedAt)
+
+	if err == pgx.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+// RotateSigningSecret gives the endpoint its own secret. The secret it signed
+// with until now (its own, or the org secret it inherited) stays valid until
+// previousExpiresAt; a nil previousExpiresAt drops it immediately.
+func (r *PostgresEndpointRepository) RotateSigningSecret(ctx context.Context, id uuid.UUID, newSecret string, previousExpiresAt *time.Time) error {
+	result, err := r.pool.Exec(ctx, `
+		UPDATE endpoints e
+		SET signing_secret = $1,
+			previous_signing_secret = CASE WHEN $2::timestamptz IS NULL THEN NULL
+				ELSE COALESCE(e.signing_secret, o.signing_secret) END,
+			previous_secret_expires_at = $2
+		FROM organizations o
+		WHERE o.id = e.org_id AND e.id = $3
+	`, newSecret, previousExpiresAt, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresEndpointRepository) ExpirePreviousSigningSecret(ctx context.Context, id uuid.UUID) error {
+	result, err := r.pool.Exec(ctx, `
+		UPDATE endpoints
+		SET previous_signing_secret = NULL, previous_secret_expires_at = NULL
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordDelivery updates the endpoint's health counters after an attempt
+// and returns the endpoint as it is now. The previous streak is read under
+// the same row lock, so of several concurrent attempts exactly one sees the
+// streak it ended.
+func (r *PostgresEndpointRepository) RecordDelivery(ctx context.Context, id uuid.UUID, success bool) (*DeliveryHealth, error) {
+	health := &DeliveryHealth{}
+	endpoint, err := scanEndpoint(withExtra(r.pool.QueryRow(ctx, `
+		WITH previous AS (
+			SELECT id AS previous_id, consecutive_failures AS previous_failures,
+				failing_since AS previous_failing_since
+			FROM endpoints
+			WHERE id = $1
+			FOR UPDATE
+		)
+		UPDATE endpoints
+		SET consecutive_failures = CASE WHEN $2 THEN 0 ELSE consecutive_failures + 1 END,
+			failing_since = CASE WHEN $2 THEN NULL ELSE COALESCE(failing_since, NOW()) END,
+			last_success_at = CASE WHEN $2 THEN NOW() ELSE last_success_at END
+		FROM previous
+		WHERE id = previous_id
+		RETURNING `+endpointColumns+`, previous_failures, previous_failing_since
+	`, id, success), &health.PreviousFailures, &health.PreviousFailingSince))
+	if err != nil {
+		return nil, err
+	}
+	health.Endpoint = endpoint
+	return health, nil
+}
+
+// extraRow scans columns selected after endpointColumns into extra.
+type extraRow struct {
+	pgx.Row
+	extra []any
+}
+
+func withExtra(row pgx.Row, extra ...any) pgx.Row {
+	return extraRow{Row: row, extra: extra}
+}
+
+func (r extraRow) Scan(dest ...any) error {
+	return r.Row.Scan(append(dest, r.extra...)...)
+}
+
+// Disable stops deliveries to the endpoint. Endpoints that are already
+// disabled return ErrNotFound.
+func (r *PostgresEndpointRepository) Disable(ctx context.Context, id uuid.UUID, reason string) error {
+	result, err := r.pool.Exec(ctx, `
+		UPDATE endpoints
+		SET disabled_at = NOW(), disabled_reason = $2
+		WHERE id = $1 AND disabled_at IS NULL
+	`, id, reason)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Enable resumes deliveries and resets the failure streak so the endpoint
+// gets a full disable period before it can be disabled again.
+func (r *PostgresEndpointRepository) Enable(ctx context.Context, id uuid.UUID) error {
+	result, err := r.pool.Exec(ctx, `
+		UPDATE endpoints
+		SET disabled_at = NULL, disabled_reason = NULL, consecutive_failures = 0, failing_since = NULL
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetHealth summarizes attempts made to the endpoint since the given time.
+func (r *PostgresEndpointRepository) GetHealth(ctx context.Context, id uuid.UUID, since time.Time) (*EndpointHealth, error) {
+	e, err := r.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var attempts, succeeded, held int
+	err = r.pool.QueryRow(ctx, `
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE da.status_code BETWEEN 200 AND 299),
+			(SELECT COUNT(*) FROM messages WHERE endpoint_id = $1 AND status = 'held')
+		FROM delivery_attempts da
+		JOIN messages m ON m.id = da.message_id
+		WHERE m.endpoint_id = $1 AND da.attempted_at >= $2
+	`, id, since).Scan(&attempts, &succeeded, &held)
+	if err != nil {
+		return nil, err
+	}
+
+	health := &EndpointHealth{
+		Status:              "healthy",
+		Attempts:            attempts,
+		ConsecutiveFailures: e.ConsecutiveFailures,
+		LastSuccessAt:       e.LastSuccessAt,
+		FailingSince:        e.FailingSince,
+		DisabledAt:          e.DisabledAt,
+		DisabledReason:      e.DisabledReason,
+		HeldMessages:        held,
+		Since:               since,
+	}
+	if attempts > 0 {
+		rate := math.Round(float64(succeeded)/float64(attempts)*10000) / 100
+		health.SuccessRate = &rate
+	}
+	switch {
+	case e.DisabledAt != nil:
+		health.Status = "disabled"
+	case e.FailingSince != nil:
+		health.Status = "failing"
+	}
+
+	return health, nil
+}
+
+func (r *PostgresEndpointRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.pool.Exec(ctx, `DELETE FROM endpoints WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
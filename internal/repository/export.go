@@ -0,0 +1,298 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// exportBatchSize is how many messages Stream loads at a time.
+const exportBatchSize = 500
+
+type PostgresExportRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewExportRepository(pool *pgxpool.Pool) ExportRepository {
+	return &PostgresExportRepository{
+		pool: pool,
+	}
+}
+
+// Stream calls fn with every message the filter selects, oldest first,
+// together with its attempts. Messages are read in batches by keyset, so
+// memory stays flat however many there are and no query runs for long. It
+// returns how many messages fn was called with.
+func (r *PostgresExportRepository) Stream(ctx context.Context, f ExportFilter, fn func(*ExportRecord) error) (int64, error) {
+	where := "WHERE m.org_id = $1 AND m.created_at >= $2 AND m.created_at < $3"
+	args := []any{f.OrgID, f.From, f.To}
+	if f.Status != "" {
+		args = append(args, f.Status)
+		where += fmt.Sprintf(" AND m.status = $%d", len(args))
+	}
+	if conds, filterArgs := f.Filter.SQL(args); conds != "" {
+		where += " AND " + conds
+		args = filterArgs
+	}
+	join := ""
+	if f.Filter.References("da.") {
+		join = latestAttemptJoin
+	}
+
+	var (
+		page  = PageRequest{Limit: exportBatchSize}
+		count int64
+	)
+	for {
+		batchWhere, batchArgs := where, args
+		if cond, keysetArgs := page.keysetCondition("m.created_at", "m.id", true, args); cond != "" {
+			batchWhere += " AND " + cond
+			batchArgs = keysetArgs
+		}
+		batchArgs = append(batchArgs, exportBatchSize)
+
+		messages, err := r.findMessages(ctx, fmt.Sprintf(`
+			SELECT m.id, m.org_id, m.endpoint_id, m.method, m.url, m.payload, m.payload_ref, m.payload_size, m.status,
+				m.created_at, m.updated_at, m.attempt_count, m.next_retry_at,
+				m.idempotency_key, m.replayed_from, m.event_type, m.priority, m.api_key_id
+			FROM messages m
+			%s
+			%s
+			ORDER BY m.created_at ASC, m.id ASC
+			LIMIT $%d
+		`, join, batchWhere, len(batchArgs)), batchArgs...)
+		if err != nil {
+			return count, err
+		}
+		if len(messages) == 0 {
+			return count, nil
+		}
+
+		attempts, err := r.findAttempts(ctx, messages)
+		if err != nil {
+			return count, err
+		}
+		for _, msg := range messages {
+			if err := fn(&ExportRecord{Message: msg, Attempts: attempts[msg.ID]}); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if len(messages) < exportBatchSize {
+			return count, nil
+		}
+		last := messages[len(messages)-1]
+		page.After = &Cursor{Time: last.CreatedAt, ID: last.ID}
+	}
+}
+
+func (r *PostgresExportRepository) findMessages(ctx context.Context, query string, args ...any) ([]*model.Message, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*model.Message
+	for rows.Next() {
+		msg := &model.Message{}
+		if err := rows.Scan(
+			&msg.ID, &msg.OrgID, &msg.EndpointID, &msg.Method, &msg.URL, &msg.Payload, &msg.PayloadRef, &msg.PayloadSize, &msg.Status,
+			&msg.CreatedAt, &msg.UpdatedAt, &msg.AttemptCount, &msg.NextRetryAt,
+			&msg.IdempotencyKey, &msg.ReplayedFrom, &msg.EventType, &msg.Priority, &msg.ApiKeyID,
+		); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// findAttempts loads the attempts of a batch of messages, keyed by message.
+func (r *PostgresExportRepository) findAttempts(ctx context.Context, messages []*model.Message) (map[uuid.UUID][]*model.DeliveryAttempt, error) {
+	ids := make([]uuid.UUID, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, message_id, attempt_number, status_code, response_body, response_truncated, error_message, duration_ms, attempted_at
+		FROM delivery_attempts
+		WHERE message_id = ANY($1)
+		ORDER BY message_id, attempt_number ASC
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := make(map[uuid.UUID][]*model.DeliveryAttempt, len(messages))
+	for rows.Next() {
+		a := &model.DeliveryAttempt{}
+		if err := rows.Scan(&a.ID, &a.MessageID, &a.AttemptNumber, &a.StatusCode, &a.ResponseBody, &a.ResponseTruncated, &a.ErrorMessage, &a.DurationMS, &a.AttemptedAt); err != nil {
+			return nil, err
+		}
+		attempts[a.MessageID] = append(attempts[a.MessageID], a)
+	}
+	return attempts, rows.Err()
+}
+
+const exportJobColumns = `id, org_id, requested_by, format, from_time, to_time, message_status, query, status,
+	messages, size_bytes, file_ref, error, started_at, finished_at, expires_at, created_at`
+
+func scanExportJob(row pgx.Row) (*model.ExportJob, error) {
+	job := &model.ExportJob{}
+	err := row.Scan(
+		&job.ID, &job.OrgID, &job.RequestedBy, &job.Format, &job.From, &job.To, &job.MessageStatus, &job.Query, &job.Status,
+		&job.Messages, &job.SizeBytes, &job.FileRef, &job.Error, &job.StartedAt, &job.FinishedAt, &job.ExpiresAt, &job.CreatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+func (r *PostgresExportRepository) CreateJob(ctx context.Context, job *model.ExportJob) error {
+	return r.pool.QueryRow(ctx, `
+		INSERT INTO export_jobs (org_id, requested_by, format, from_time, to_time, message_status, query)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, status, created_at
+	`, job.OrgID, job.RequestedBy, job.Format, job.From, job.To, job.MessageStatus, job.Query,
+	).Scan(&job.ID, &job.Status, &job.CreatedAt)
+}
+
+func (r *PostgresExportRepository) FindJobByID(ctx context.Context, id uuid.UUID) (*model.ExportJob, error) {
+	return scanExportJob(r.pool.QueryRow(ctx, `SELECT `+exportJobColumns+` FROM export_jobs WHERE id = $1`, id))
+}
+
+// FindJobsByOrgID returns a page of the org's jobs, newest first.
+func (r *PostgresExportRepository) FindJobsByOrgID(ctx context.Context, orgID uuid.UUID, page PageRequest) (*Page[*model.ExportJob], error) {
+	where := "WHERE org_id = $1"
+	args := []any{orgID}
+
+	dataWhere, dataArgs := where, args
+	if cond, keysetArgs := page.keysetCondition("created_at", "id", false, args); cond != "" {
+		dataWhere += " AND " + cond
+		dataArgs = keysetArgs
+	}
+	dataArgs = append(dataArgs, page.limit()+1)
+
+	rows, err := r.pool.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM export_jobs
+		%s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d
+	`, exportJobColumns, dataWhere, len(dataArgs)), dataArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*model.ExportJob
+	for rows.Next() {
+		job, err := scanExportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := newPage(jobs, page, func(job *model.ExportJob) Cursor {
+		return Cursor{Time: job.CreatedAt, ID: job.ID}
+	})
+	if err := countTotal(ctx, r.pool, result, page, "FROM export_jobs "+where, args); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ClaimJob marks the oldest pending job running and returns it. Jobs still
+// running since before staleBefore are taken over, as whoever ran them is
+// assumed to have died. The returned StartedAt identifies this claim to
+// CompleteJob and FailJob. It returns ErrNotFound when there is nothing to
+// do.
+func (r *PostgresExportRepository) ClaimJob(ctx context.Context, staleBefore time.Time) (*model.ExportJob, error) {
+	return scanExportJob(r.pool.QueryRow(ctx, `
+		UPDATE export_jobs SET status = 'running', started_at = NOW()
+		WHERE id = (
+			SELECT id FROM export_jobs
+			WHERE status = 'pending' OR (status = 'running' AND started_at < $1)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+exportJobColumns, staleBefore))
+}
+
+// CompleteJob records the job's file. startedAt is the claim's StartedAt;
+// if the job has since been taken over by another runner, nothing changes
+// and ErrNotFound is returned.
+func (r *PostgresExportRepository) CompleteJob(ctx context.Context, id uuid.UUID, startedAt time.Time, messages, sizeBytes int64, fileRef string, expiresAt time.Time) error {
+	result, err := r.pool.Exec(ctx, `
+		UPDATE export_jobs
+		SET status = 'done', messages = $3, size_bytes = $4, file_ref = $5, expires_at = $6, finished_at = NOW()
+		WHERE id = $1 AND status = 'running' AND started_at = $2
+	`, id, startedAt, messages, sizeBytes, fileRef, expiresAt)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// FailJob marks the job failed, under the same claim check as CompleteJob.
+func (r *PostgresExportRepository) FailJob(ctx context.Context, id uuid.UUID, startedAt time.Time, reason string) error {
+	result, err := r.pool.Exec(ctx, `
+		UPDATE export_jobs SET status = 'failed', error = $3, finished_at = NOW()
+		WHERE id = $1 AND status = 'running' AND started_at = $2
+	`, id, startedAt, reason)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// FindExpiredJobs returns jobs whose files expired before now. Their files
+// are deleted before the jobs, so a job stays until its file is gone.
+func (r *PostgresExportRepository) FindExpiredJobs(ctx context.Context, now time.Time) ([]*model.ExportJob, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+exportJobColumns+` FROM export_jobs WHERE expires_at < $1
+	`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*model.ExportJob
+	for rows.Next() {
+		job, err := scanExportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (r *PostgresExportRepository) DeleteJob(ctx context.Context, id uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM export_jobs WHERE id = $1`, id)
+	return err
+}
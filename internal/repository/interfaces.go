@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/search"
 	"github.com/google/uuid"
 )
 
@@ -23,35 +24,82 @@ type AccountRepository interface {
 type ApiKeyRepository interface {
 	Create(ctx context.Context, apiKey *model.APIKey) error
 	FindByHashedKey(ctx context.Context, hashedKey string) (*model.APIKey, error)
-	FindByOrgID(ctx context.Context, orgID uuid.UUID) ([]*model.APIKey, error)
+	FindByOrgID(ctx context.Context, orgID uuid.UUID, page PageRequest) (*Page[*model.APIKey], error)
+	CountByOrgID(ctx context.Context, orgID uuid.UUID) (int, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
+type EndpointRepository interface {
+	Create(ctx context.Context, endpoint *model.Endpoint) error
+	Upsert(ctx context.Context, orgID uuid.UUID, url string) (*model.Endpoint, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*model.Endpoint, error)
+	FindByOrgID(ctx context.Context, orgID uuid.UUID) ([]*model.Endpoint, error)
+	FindByURL(ctx context.Context, orgID uuid.UUID, url string) (*model.Endpoint, error)
+	CountByOrgID(ctx context.Context, orgID uuid.UUID) (int, error)
+	FindOperational(ctx context.Context, orgID uuid.UUID) ([]*model.Endpoint, error)
+	Update(ctx context.Context, endpoint *model.Endpoint) error
+	RotateSigningSecret(ctx context.Context, id uuid.UUID, newSecret string, previousExpiresAt *time.Time) error
+	ExpirePreviousSigningSecret(ctx context.Context, id uuid.UUID) error
+	RecordDelivery(ctx context.Context, id uuid.UUID, success bool) (*DeliveryHealth, error)
+	Disable(ctx context.Context, id uuid.UUID, reason string) error
+	Enable(ctx context.Context, id uuid.UUID) error
+	GetHealth(ctx context.Context, id uuid.UUID, since time.Time) (*EndpointHealth, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// DeliveryHealth is an endpoint right after RecordDelivery, along with the
+// failure streak it had before the attempt was recorded.
+type DeliveryHealth struct {
+	Endpoint             *model.Endpoint
+	PreviousFailures     int
+	PreviousFailingSince *time.Time
+}
+
+type EndpointHealth struct {
+	Status              string     `json:"status"` // 'healthy', 'failing', 'disabled'
+	SuccessRate         *float64   `json:"successRate"`
+	Attempts            int        `json:"attempts"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastSuccessAt       *time.Time `json:"lastSuccessAt"`
+	FailingSince        *time.Time `json:"failingSince"`
+	DisabledAt          *time.Time `json:"disabledAt"`
+	DisabledReason      *string    `json:"disabledReason"`
+	HeldMessages        int        `json:"heldMessages"`
+	Since               time.Time  `json:"since"`
+}
+
 type DeliveryAttemptRepository interface {
 	Create(ctx context.Context, deliveryAttempt *model.DeliveryAttempt) error
 	FindByMessageID(ctx context.Context, messageID uuid.UUID) ([]*model.DeliveryAttempt, error)
+	ListByMessageID(ctx context.Context, messageID uuid.UUID, page PageRequest) (*Page[*model.DeliveryAttempt], error)
 }
 
 type DeliveryAttemptDetail struct {
-	ID            uuid.UUID `json:"id"`
-	AttemptNumber int       `json:"attemptNumber"`
-	StatusCode    *int      `json:"statusCode"`
-	ResponseBody  *string   `json:"responseBody"`
-	ErrorMessage  *string   `json:"errorMessage"`
-	DurationMS    *int      `json:"durationMs"`
-	AttemptedAt   string    `json:"attemptedAt"`
+	ID                uuid.UUID `json:"id"`
+	AttemptNumber     int       `json:"attemptNumber"`
+	StatusCode        *int      `json:"statusCode"`
+	ResponseBody      *string   `json:"responseBody"`
+	ResponseTruncated bool      `json:"responseTruncated"`
+	ErrorMessage      *string   `json:"errorMessage"`
+	DurationMS        *int      `json:"durationMs"`
+	AttemptedAt       string    `json:"attemptedAt"`
 }
 
 type WebhookLogDetail struct {
-	ID              uuid.UUID              `json:"id"`
-	Method          string                 `json:"method"`
-	URL             string                 `json:"url"`
-	Status          string                 `json:"status"`
-	Payload         json.RawMessage        `json:"payload"`
-	AttemptCount    int                    `json:"attemptCount"`
-	CreatedAt       string                 `json:"createdAt"`
-	UpdatedAt       string                 `json:"updatedAt"`
-	NextRetryAt     *string                `json:"nextRetryAt"`
+	ID               uuid.UUID               `json:"id"`
+	Method           string                  `json:"method"`
+	URL              string                  `json:"url"`
+	Status           string                  `json:"status"`
+	Priority         string                  `json:"priority"`
+	Payload          json.RawMessage         `json:"payload"`
+	PayloadRef       *string                 `json:"payloadRef,omitempty"`
+	IdempotencyKey   *string                 `json:"idempotencyKey,omitempty"`
+	ReplayedFrom     *uuid.UUID              `json:"replayedFrom,omitempty"`
+	ApiKeyID         *uuid.UUID              `json:"apiKeyId,omitempty"`
+	AttemptCount     int                     `json:"attemptCount"`
+	CreatedAt        string                  `json:"createdAt"`
+	UpdatedAt        string                  `json:"updatedAt"`
+	NextRetryAt      *string                 `json:"nextRetryAt"`
 	DeliveryAttempts []DeliveryAttemptDetail `json:"deliveryAttempts"`
 }
 type MembershipWithOrg struct {
@@ -75,6 +123,20 @@ type MessageStats struct {
 	SuccessRate float64 `json:"successRate"`
 }
 
+// OrgDeliveryStats covers one org's attempts and dead letters over a window.
+type OrgDeliveryStats struct {
+	OrgID       uuid.UUID
+	Attempts    int
+	Succeeded   int
+	DeadLetters int
+}
+
+// RetentionPolicy is how long an org's messages are kept.
+type RetentionPolicy struct {
+	OrgID         uuid.UUID
+	RetentionDays int
+}
+
 type WebhookLogEntry struct {
 	ID             uuid.UUID `json:"id"`
 	Endpoint       string    `json:"endpoint"`
@@ -83,14 +145,7 @@ type WebhookLogEntry struct {
 	EventType      string    `json:"eventType"`
 	AttemptedAt    string    `json:"attemptedAt"`
 	ResponseTimeMs int       `json:"responseTimeMs"`
-}
-
-type WebhookLogsResult struct {
-	Data       []WebhookLogEntry `json:"data"`
-	Total      int               `json:"total"`
-	Page       int               `json:"page"`
-	Limit      int               `json:"limit"`
-	TotalPages int               `json:"totalPages"`
+	CreatedAt      time.Time `json:"createdAt"`
 }
 
 type MessageRepository interface {
@@ -98,10 +153,107 @@ type MessageRepository interface {
 	FindPending(ctx context.Context, limit int) ([]*model.Message, error)
 	UpdateStatus(ctx context.Context, id uuid.UUID, status string) (*model.Message, error)
 	FindById(ctx context.Context, id uuid.UUID) (*model.Message, error)
+	FindByIdempotencyKey(ctx context.Context, orgID uuid.UUID, key string) (*model.Message, error)
 	Update(ctx context.Context, msg *model.Message) error
+	Replay(ctx context.Context, id uuid.UUID, apiKeyID *uuid.UUID) (*model.Message, error)
+	Cancel(ctx context.Context, id uuid.UUID) error
+	HoldByEndpoint(ctx context.Context, endpointID uuid.UUID) ([]*model.Message, error)
+	ReleaseHeld(ctx context.Context, endpointID uuid.UUID) ([]*model.Message, error)
+	FailHeld(ctx context.Context, endpointID uuid.UUID) ([]*model.Message, error)
 	FindRetryReady(ctx context.Context, limit int) ([]*model.Message, error)
 	GetStatsByOrgID(ctx context.Context, orgID uuid.UUID) (*MessageStats, error)
-	FindWebhookLogs(ctx context.Context, orgID uuid.UUID, status string, search string, page int, limit int) (*WebhookLogsResult, error)
+	FindOrgDeliveryStats(ctx context.Context, since time.Time) ([]OrgDeliveryStats, error)
+	FindWebhookLogs(ctx context.Context, orgID uuid.UUID, status string, urlSearch string, filter *search.Filter, page PageRequest) (*Page[WebhookLogEntry], error)
+	HasOffloadedPayloads(ctx context.Context, orgID uuid.UUID) (bool, error)
+	PurgeBefore(ctx context.Context, orgID uuid.UUID, before time.Time, limit int) (messages, attempts int64, err error)
+	FindOrphanedPayloads(ctx context.Context, before time.Time, limit int) ([]string, error)
+	PurgeOrphanedPayload(ctx context.Context, payloadRef string, deleteBlob func(context.Context) error) (bool, error)
+	ClaimPayload(ctx context.Context, payloadRef string) error
+}
+
+// TimeseriesFilter selects the attempts FindTimeseries counts.
+type TimeseriesFilter struct {
+	OrgID uuid.UUID
+	From  time.Time
+	To    time.Time
+	// Bucket is the width of each point.
+	Bucket     time.Duration
+	EndpointID *uuid.UUID
+	EventType  string
+}
+
+// TimeseriesRow counts the attempts in one bucket that share a status code
+// and latency bucket. StatusCode is 0 for attempts that got no response and
+// LatencyBucket is the bucket's upper bound in milliseconds, or -1 when the
+// attempt has no duration.
+type TimeseriesRow struct {
+	Bucket        time.Time
+	StatusCode    int
+	LatencyBucket int
+	Succeeded     int64
+	Retried       int64
+	Failed        int64
+}
+
+// EndpointReportRow counts one endpoint's attempts in one latency bucket.
+// URL is nil when the endpoint has since been deleted.
+type EndpointReportRow struct {
+	EndpointID    uuid.UUID
+	URL           *string
+	LatencyBucket int
+	Attempts      int64
+	// Failures are attempts without a 2xx response; DeadLetters the ones
+	// among them that were their message's last.
+	Failures    int64
+	DeadLetters int64
+}
+
+// ApiKeyReportRow counts the messages one API key created. ApiKeyID is nil
+// for messages no key is credited with.
+type ApiKeyReportRow struct {
+	ApiKeyID  *uuid.UUID `json:"apiKeyId"`
+	Name      *string    `json:"name"`
+	Prefix    *string    `json:"prefix"`
+	Messages  int64      `json:"messages"`
+	Succeeded int64      `json:"succeeded"`
+	Failed    int64      `json:"failed"`
+}
+
+type AnalyticsRepository interface {
+	RefreshRollups(ctx context.Context, from, to time.Time) (int64, error)
+	FindRollupProgress(ctx context.Context) (time.Time, error)
+	FindTimeseries(ctx context.Context, f TimeseriesFilter) ([]TimeseriesRow, error)
+	FindEndpointReport(ctx context.Context, orgID uuid.UUID, from, to time.Time) ([]EndpointReportRow, error)
+	FindApiKeyReport(ctx context.Context, orgID uuid.UUID, from, to time.Time, limit int) ([]ApiKeyReportRow, error)
+}
+
+// ExportFilter selects the messages an export covers: the org's messages
+// created from From up to To, narrowed by Status and Filter as in
+// FindWebhookLogs.
+type ExportFilter struct {
+	OrgID  uuid.UUID
+	From   time.Time
+	To     time.Time
+	Status string
+	Filter *search.Filter
+}
+
+// ExportRecord is a message with its delivery attempts, oldest first.
+type ExportRecord struct {
+	Message  *model.Message
+	Attempts []*model.DeliveryAttempt
+}
+
+type ExportRepository interface {
+	Stream(ctx context.Context, f ExportFilter, fn func(*ExportRecord) error) (int64, error)
+	CreateJob(ctx context.Context, job *model.ExportJob) error
+	FindJobByID(ctx context.Context, id uuid.UUID) (*model.ExportJob, error)
+	FindJobsByOrgID(ctx context.Context, orgID uuid.UUID, page PageRequest) (*Page[*model.ExportJob], error)
+	ClaimJob(ctx context.Context, staleBefore time.Time) (*model.ExportJob, error)
+	CompleteJob(ctx context.Context, id uuid.UUID, startedAt time.Time, messages, sizeBytes int64, fileRef string, expiresAt time.Time) error
+	FailJob(ctx context.Context, id uuid.UUID, startedAt time.Time, reason string) error
+	FindExpiredJobs(ctx context.Context, now time.Time) ([]*model.ExportJob, error)
+	DeleteJob(ctx context.Context, id uuid.UUID) error
 }
 
 type OrganizationRepository interface {
@@ -109,7 +261,27 @@ type OrganizationRepository interface {
 	FindByID(ctx context.Context, id uuid.UUID) (*model.Organization, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 	GetSigningSecret(ctx context.Context, orgID uuid.UUID) (string, error)
-	RotateSigningSecret(ctx context.Context, orgID uuid.UUID, newSecret string) error
+	RotateSigningSecret(ctx context.Context, orgID uuid.UUID, newSecret string, previousExpiresAt *time.Time) error
+	ExpirePreviousSigningSecret(ctx context.Context, orgID uuid.UUID) error
+	GetMaxPayloadBytes(ctx context.Context, orgID uuid.UUID) (*int, error)
+	UpdateMaxPayloadBytes(ctx context.Context, orgID uuid.UUID, maxPayloadBytes *int) error
+	FindDeliveryRates(ctx context.Context) (map[uuid.UUID]int, error)
+	UpdateMaxDeliveriesPerSecond(ctx context.Context, orgID uuid.UUID, maxDeliveriesPerSecond *int) error
+	UpdateSignatureScheme(ctx context.Context, orgID uuid.UUID, scheme string) error
+	UpdateSignatureProfile(ctx context.Context, orgID uuid.UUID, profile string) error
+	GetRedactPaths(ctx context.Context, orgID uuid.UUID) ([]string, error)
+	UpdateRedactPaths(ctx context.Context, orgID uuid.UUID, paths []string) error
+	GetRetention(ctx context.Context, orgID uuid.UUID) (days int, legalHold bool, err error)
+	FindRetentionPolicies(ctx context.Context) ([]RetentionPolicy, error)
+	SetRetentionDays(ctx context.Context, orgID uuid.UUID, days *int) error
+	SetLegalHold(ctx context.Context, orgID uuid.UUID, hold bool) error
+}
+
+type SigningKeyRepository interface {
+	FindActiveByOrgID(ctx context.Context, orgID uuid.UUID) (*model.SigningKey, error)
+	FindByOrgID(ctx context.Context, orgID uuid.UUID) ([]*model.SigningKey, error)
+	Rotate(ctx context.Context, key *model.SigningKey) error
+	DeleteRetired(ctx context.Context, orgID, id uuid.UUID) error
 }
 
 type PendingInvitation struct {
@@ -124,10 +296,42 @@ type InvitationRepository interface {
 	Create(ctx context.Context, invitation *model.Invitation) error
 	FindByID(ctx context.Context, id uuid.UUID) (*model.Invitation, error)
 	FindByToken(ctx context.Context, token string) (*model.Invitation, error)
-	FindByOrgID(ctx context.Context, orgID uuid.UUID) ([]*model.Invitation, error)
+	FindByOrgID(ctx context.Context, orgID uuid.UUID, page PageRequest) (*Page[*model.Invitation], error)
 	FindByOrgAndEmail(ctx context.Context, orgID uuid.UUID, email string) (*model.Invitation, error)
 	FindPendingByEmail(ctx context.Context, email string) ([]PendingInvitation, error)
 	UpdateStatus(ctx context.Context, id uuid.UUID, status string) error
 	AcceptInvitation(ctx context.Context, id uuid.UUID) error
 	Delete(ctx context.Context, id uuid.UUID) error
 }
+
+type DigestRecipient struct {
+	Email       string
+	FirstName   string
+	OrgName     string
+	Preferences model.NotificationPreferences
+	// Since is the end of the user's previous digest.
+	Since time.Time
+}
+
+type NotificationRepository interface {
+	Create(ctx context.Context, n *model.Notification) error
+	ExistsSince(ctx context.Context, orgID uuid.UUID, kind string, since time.Time) (bool, error)
+	FindBetween(ctx context.Context, orgID uuid.UUID, kinds []string, since, until time.Time, limit int) ([]*model.Notification, int, error)
+	GetPreferences(ctx context.Context, userID, orgID uuid.UUID) (*model.NotificationPreferences, error)
+	UpsertPreferences(ctx context.Context, p *model.NotificationPreferences) error
+	FindDueDigests(ctx context.Context) ([]*DigestRecipient, error)
+	MarkDigestSent(ctx context.Context, userID, orgID uuid.UUID, at time.Time) error
+}
+
+type PlanRepository interface {
+	FindAll(ctx context.Context) ([]*model.Plan, error)
+	FindByOrgID(ctx context.Context, orgID uuid.UUID) (*model.Plan, error)
+	SetOrgPlan(ctx context.Context, orgID uuid.UUID, planID string) error
+}
+
+type UsageRepository interface {
+	Record(ctx context.Context, orgID uuid.UUID, messages, payloadBytes, deliveryAttempts int64) error
+	MessagesSince(ctx context.Context, orgID uuid.UUID, since time.Time) (int64, error)
+	FindByOrgID(ctx context.Context, orgID uuid.UUID, from, to time.Time) ([]*model.UsageDay, error)
+	FindAll(ctx context.Context, from, to time.Time) ([]*model.UsageDay, error)
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/bilalabdelkadir/chis/internal/model"
@@ -125,13 +126,25 @@ func (r *PostgresInvitationRepository) FindByToken(ctx context.Context, token st
 	return inv, nil
 }
 
-func (r *PostgresInvitationRepository) FindByOrgID(ctx context.Context, orgID uuid.UUID) ([]*model.Invitation, error) {
-	rows, err := r.pool.Query(ctx, `
+// FindByOrgID returns a page of the org's invitations, newest first.
+func (r *PostgresInvitationRepository) FindByOrgID(ctx context.Context, orgID uuid.UUID, page PageRequest) (*Page[*model.Invitation], error) {
+	where := "WHERE org_id = $1"
+	args := []any{orgID}
+
+	dataWhere, dataArgs := where, args
+	if cond, keysetArgs := page.keysetCondition("created_at", "id", false, args); cond != "" {
+		dataWhere += " AND " + cond
+		dataArgs = keysetArgs
+	}
+	dataArgs = append(dataArgs, page.limit()+1)
+
+	rows, err := r.pool.Query(ctx, fmt.Sprintf(`
 		SELECT id, org_id, email, role, token, status, invited_by, expires_at, accepted_at, created_at, updated_at
 		FROM invitations
-		WHERE org_id = $1
-		ORDER BY created_at DESC
-	`, orgID)
+		%s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d
+	`, dataWhere, len(dataArgs)), dataArgs...)
 	if err != nil {
 		return nil, err
 	}
@@ -157,8 +170,17 @@ func (r *PostgresInvitationRepository) FindByOrgID(ctx context.Context, orgID uu
 		}
 		result = append(result, inv)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return result, rows.Err()
+	invitations := newPage(result, page, func(inv *model.Invitation) Cursor {
+		return Cursor{Time: inv.CreatedAt, ID: inv.ID}
+	})
+	if err := countTotal(ctx, r.pool, invitations, page, "FROM invitations "+where, args); err != nil {
+		return nil, err
+	}
+	return invitations, nil
 }
 
 func (r *PostgresInvitationRepository) FindByOrgAndEmail(ctx context.Context, orgID uuid.UUID, email string) (*model.Invitation, error) {
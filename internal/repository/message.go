@@ -2,13 +2,16 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"time"
 
 	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/search"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -24,17 +27,28 @@ func NewMessageRepository(pool *pgxpool.Pool) MessageRepository {
 
 func (r *PostgresMessageRepository) Create(ctx context.Context, message *model.Message) error {
 	err := r.pool.QueryRow(ctx, `
-		INSERT INTO messages (org_id, method, url,payload)
-		VALUES ($1,$2,$3,$4)
-		RETURNING id, status,created_at, updated_at
+		INSERT INTO messages (org_id, endpoint_id, method, url,payload, payload_ref, payload_size, idempotency_key, event_type, priority, api_key_id)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,COALESCE(NULLIF($10, ''), 'normal'),$11)
+		RETURNING id, status, priority, created_at, updated_at
 
 	`,
 		message.OrgID,
+		message.EndpointID,
 		message.Method,
 		message.URL,
 		message.Payload,
-	).Scan(&message.ID, &message.Status, &message.CreatedAt, &message.UpdatedAt)
-
+		message.PayloadRef,
+		message.PayloadSize,
+		message.IdempotencyKey,
+		message.EventType,
+		message.Priority,
+		message.ApiKeyID,
+	).Scan(&message.ID, &message.Status, &message.Priority, &message.CreatedAt, &message.UpdatedAt)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrDuplicate
+	}
 	return err
 }
 
@@ -106,23 +120,39 @@ func (r *PostgresMessageRepository) UpdateStatus(ctx context.Context, id uuid.UU
 }
 
 func (r *PostgresMessageRepository) FindById(ctx context.Context, id uuid.UUID) (*model.Message, error) {
+	return r.findOne(ctx, `WHERE id = $1`, id)
+}
+
+func (r *PostgresMessageRepository) FindByIdempotencyKey(ctx context.Context, orgID uuid.UUID, key string) (*model.Message, error) {
+	return r.findOne(ctx, `WHERE org_id = $1 AND idempotency_key = $2`, orgID, key)
+}
+
+func (r *PostgresMessageRepository) findOne(ctx context.Context, where string, args ...any) (*model.Message, error) {
 	var msg model.Message
 
 	err := r.pool.QueryRow(ctx, `
-		SELECT id, org_id, method, url, payload, status, created_at, updated_at, attempt_count, next_retry_at
+		SELECT id, org_id, endpoint_id, method, url, payload, payload_ref, payload_size, status, created_at, updated_at, attempt_count, next_retry_at,
+			idempotency_key, replayed_from, event_type, priority, api_key_id
 		FROM messages
-		WHERE id = $1
-	`, id).Scan(
+		`+where, args...).Scan(
 		&msg.ID,
 		&msg.OrgID,
+		&msg.EndpointID,
 		&msg.Method,
 		&msg.URL,
 		&msg.Payload,
+		&msg.PayloadRef,
+		&msg.PayloadSize,
 		&msg.Status,
 		&msg.CreatedAt,
 		&msg.UpdatedAt,
 		&msg.AttemptCount,
 		&msg.NextRetryAt,
+		&msg.IdempotencyKey,
+		&msg.ReplayedFrom,
+		&msg.EventType,
+		&msg.Priority,
+		&msg.ApiKeyID,
 	)
 
 	if err != nil {
@@ -135,25 +165,138 @@ func (r *PostgresMessageRepository) FindById(ctx context.Context, id uuid.UUID)
 	return &msg, nil
 }
 
+// Update leaves cancelled and held messages alone so an in-flight retry
+// cannot resurrect them.
 func (r *PostgresMessageRepository) Update(ctx context.Context, msg *model.Message) error {
 	_, err := r.pool.Exec(ctx, `
         UPDATE messages 
         SET status = $1, attempt_count = $2, next_retry_at = $3
-        WHERE id = $4
+        WHERE id = $4 AND status NOT IN ('cancelled', 'held')
     `, msg.Status, msg.AttemptCount, msg.NextRetryAt, msg.ID)
 	return err
 }
 
+// Replay copies a finished message into a new one that the scheduler picks
+// up on its next pass. Messages still being delivered return ErrNotFound.
+// Replay copies a finished message as a new one, credited to apiKeyID.
+func (r *PostgresMessageRepository) Replay(ctx context.Context, id uuid.UUID, apiKeyID *uuid.UUID) (*model.Message, error) {
+	msg := &model.Message{}
+
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO messages (org_id, endpoint_id, method, url, payload, payload_ref, payload_size,
+			status, next_retry_at, replayed_from, event_type, priority, api_key_id)
+		SELECT org_id, endpoint_id, method, url, payload, payload_ref, payload_size,
+			'retry', NOW(), id, event_type, priority, $2
+		FROM messages
+		WHERE id = $1 AND status IN ('success', 'failed', 'cancelled')
+		RETURNING id, org_id, endpoint_id, method, url, event_type, status, priority, created_at, updated_at, next_retry_at, replayed_from, api_key_id
+	`, id, apiKeyID).Scan(
+		&msg.ID,
+		&msg.OrgID,
+		&msg.EndpointID,
+		&msg.Method,
+		&msg.URL,
+		&msg.EventType,
+		&msg.Status,
+		&msg.Priority,
+		&msg.CreatedAt,
+		&msg.UpdatedAt,
+		&msg.NextRetryAt,
+		&msg.ReplayedFrom,
+		&msg.ApiKeyID,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// Cancel stops a message that has not been delivered yet, including one
+// held for a disabled endpoint. Messages that already finished return
+// ErrNotFound.
+func (r *PostgresMessageRepository) Cancel(ctx context.Context, id uuid.UUID) error {
+	result, err := r.pool.Exec(ctx, `
+		UPDATE messages
+		SET status = 'cancelled', next_retry_at = NULL
+		WHERE id = $1 AND status IN ('pending', 'retry', 'held')
+	`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// HoldByEndpoint parks every undelivered message for a disabled endpoint
+// and returns the messages it held.
+func (r *PostgresMessageRepository) HoldByEndpoint(ctx context.Context, endpointID uuid.UUID) ([]*model.Message, error) {
+	return r.updateByEndpoint(ctx, `
+		UPDATE messages
+		SET status = 'held', next_retry_at = NULL
+		WHERE endpoint_id = $1 AND status IN ('pending', 'retry')
+	`, endpointID)
+}
+
+// ReleaseHeld hands held messages back to the scheduler with a fresh set
+// of attempts and returns them.
+func (r *PostgresMessageRepository) ReleaseHeld(ctx context.Context, endpointID uuid.UUID) ([]*model.Message, error) {
+	return r.updateByEndpoint(ctx, `
+		UPDATE messages
+		SET status = 'retry', attempt_count = 0, next_retry_at = NOW()
+		WHERE endpoint_id = $1 AND status = 'held'
+	`, endpointID)
+}
+
+// FailHeld gives up on held messages without attempting them again and
+// returns them.
+func (r *PostgresMessageRepository) FailHeld(ctx context.Context, endpointID uuid.UUID) ([]*model.Message, error) {
+	return r.updateByEndpoint(ctx, `
+		UPDATE messages
+		SET status = 'failed'
+		WHERE endpoint_id = $1 AND status = 'held'
+	`, endpointID)
+}
+
+// updateByEndpoint runs an UPDATE of an endpoint's messages and returns what
+// the live log stream needs of each.
+func (r *PostgresMessageRepository) updateByEndpoint(ctx context.Context, update string, endpointID uuid.UUID) ([]*model.Message, error) {
+	rows, err := r.pool.Query(ctx, update+`
+		RETURNING id, org_id, endpoint_id, url, event_type, status, attempt_count, next_retry_at
+	`, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*model.Message
+	for rows.Next() {
+		msg := &model.Message{}
+		if err := rows.Scan(&msg.ID, &msg.OrgID, &msg.EndpointID, &msg.URL, &msg.EventType, &msg.Status,
+			&msg.AttemptCount, &msg.NextRetryAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
 func (r *PostgresMessageRepository) FindRetryReady(ctx context.Context, limit int) ([]*model.Message, error) {
 
 	rows, err := r.pool.Query(ctx, `
-        SELECT id, org_id, method, url, payload, status,
+        SELECT id, org_id, method, url, payload, status, priority,
                created_at, updated_at, attempt_count, next_retry_at
         FROM messages
         WHERE status = 'retry'
           AND next_retry_at IS NOT NULL
           AND next_retry_at <= NOW()
-        ORDER BY next_retry_at ASC
+        ORDER BY CASE priority WHEN 'high' THEN 0 WHEN 'normal' THEN 1 ELSE 2 END, next_retry_at ASC
         LIMIT $1
     `, limit)
 	if err != nil {
@@ -172,6 +315,7 @@ func (r *PostgresMessageRepository) FindRetryReady(ctx context.Context, limit in
 			&msg.URL,
 			&msg.Payload,
 			&msg.Status,
+			&msg.Priority,
 			&msg.CreatedAt,
 			&msg.UpdatedAt,
 			&msg.AttemptCount,
@@ -215,15 +359,93 @@ func (r *PostgresMessageRepository) GetStatsByOrgID(ctx context.Context, orgID u
 	}, nil
 }
 
-func (r *PostgresMessageRepository) FindWebhookLogs(ctx context.Context, orgID uuid.UUID, status string, search string, page int, limit int) (*WebhookLogsResult, error) {
-	if page < 1 {
-		page = 1
+// FindOrgDeliveryStats groups attempts and messages that exhausted their
+// retries since the given time by org. Orgs with no attempts are omitted.
+func (r *PostgresMessageRepository) FindOrgDeliveryStats(ctx context.Context, since time.Time) ([]OrgDeliveryStats, error) {
+	rows, err := r.pool.Query(ctx, `
+		WITH attempts AS (
+			SELECT m.org_id,
+				COUNT(*) AS total,
+				COUNT(*) FILTER (WHERE da.status_code BETWEEN 200 AND 299) AS succeeded
+			FROM delivery_attempts da
+			JOIN messages m ON m.id = da.message_id
+			WHERE da.attempted_at >= $1
+			GROUP BY m.org_id
+		), dead AS (
+			SELECT org_id, COUNT(*) AS total
+			FROM messages
+			WHERE status = 'failed' AND updated_at >= $1
+			GROUP BY org_id
+		)
+		SELECT a.org_id, a.total, a.succeeded, COALESCE(d.total, 0)
+		FROM attempts a
+		LEFT JOIN dead d ON d.org_id = a.org_id
+	`, since)
+	if err != nil {
+		return nil, err
 	}
-	if limit < 1 {
-		limit = 20
+	defer rows.Close()
+
+	var stats []OrgDeliveryStats
+	for rows.Next() {
+		var s OrgDeliveryStats
+		if err := rows.Scan(&s.OrgID, &s.Attempts, &s.Succeeded, &s.DeadLetters); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
 	}
-	offset := (page - 1) * limit
 
+	return stats, rows.Err()
+}
+
+// WebhookLogSchema is what the q parameter of a webhook log search may
+// filter on. Status code, duration and attempts come from the message's
+// latest attempt, joined as da.
+var WebhookLogSchema = search.Schema{
+	Fields: map[string]search.Field{
+		"status": {Kind: search.Enum, Column: "m.status",
+			Values: []string{"pending", "retry", "success", "failed", "cancelled", "held"}},
+		"priority": {Kind: search.Enum, Column: "m.priority",
+			Values: []string{model.PriorityHigh, model.PriorityNormal, model.PriorityLow}},
+		"code":        {Kind: search.Number, Column: "COALESCE(da.status_code, 0)"},
+		"status_code": {Kind: search.Number, Column: "COALESCE(da.status_code, 0)"},
+		"duration":    {Kind: search.Duration, Column: "da.duration_ms"},
+		"attempts":    {Kind: search.Number, Column: "COALESCE(da.attempt_number, 0)"},
+		"event":       {Kind: search.Exact, Column: "m.event_type"},
+		"event_type":  {Kind: search.Exact, Column: "m.event_type"},
+		"endpoint":    {Kind: search.UUID, Column: "m.endpoint_id"},
+		"endpoint_id": {Kind: search.UUID, Column: "m.endpoint_id"},
+		"api_key":     {Kind: search.UUID, Column: "m.api_key_id"},
+		"url":         {Kind: search.Text, Column: "m.url"},
+		"created":     {Kind: search.Time, Column: "m.created_at"},
+	},
+	FreeText: "m.url",
+	Payload:  "m.payload",
+}
+
+// HasOffloadedPayloads reports whether any of the org's messages keep their
+// payload in the blob store, where payload searches can't see it.
+func (r *PostgresMessageRepository) HasOffloadedPayloads(ctx context.Context, orgID uuid.UUID) (bool, error) {
+	var exists bool
+	err := r.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM messages WHERE org_id = $1 AND payload_ref IS NOT NULL)
+	`, orgID).Scan(&exists)
+	return exists, err
+}
+
+// latestAttemptJoin joins each message's latest delivery attempt as da.
+const latestAttemptJoin = `
+	LEFT JOIN LATERAL (
+		SELECT status_code, duration_ms, attempted_at, attempt_number
+		FROM delivery_attempts
+		WHERE message_id = m.id
+		ORDER BY attempt_number DESC
+		LIMIT 1
+	) da ON true`
+
+func (r *PostgresMessageRepository) FindWebhookLogs(ctx context.Context, orgID uuid.UUID, status string, urlSearch string,
+	filter *search.Filter, page PageRequest,
+) (*Page[WebhookLogEntry], error) {
 	// Build WHERE clause
 	where := "WHERE m.org_id = $1"
 	args := []any{orgID}
@@ -234,46 +456,35 @@ func (r *PostgresMessageRepository) FindWebhookLogs(ctx context.Context, orgID u
 		args = append(args, status)
 		argIdx++
 	}
-	if search != "" {
+	if urlSearch != "" {
 		where += fmt.Sprintf(" AND m.url ILIKE $%d", argIdx)
-		args = append(args, "%"+search+"%")
-		argIdx++
+		args = append(args, "%"+urlSearch+"%")
 	}
-
-	// Count total
-	var total int
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM messages m %s", where)
-	if err := r.pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
-		return nil, err
+	if conds, filterArgs := filter.SQL(args); conds != "" {
+		where += " AND " + conds
+		args = filterArgs
 	}
 
-	totalPages := 0
-	if total > 0 {
-		totalPages = int(math.Ceil(float64(total) / float64(limit)))
+	// Fetch one more than the page to know whether there is a next one
+	dataWhere, dataArgs := where, args
+	if cond, keysetArgs := page.keysetCondition("m.created_at", "m.id", false, args); cond != "" {
+		dataWhere += " AND " + cond
+		dataArgs = keysetArgs
 	}
-
-	// Fetch page
+	dataArgs = append(dataArgs, page.limit()+1)
 	dataQuery := fmt.Sprintf(`
-		SELECT m.id, m.url, m.status, m.method, m.created_at,
+		SELECT m.id, m.url, m.status, COALESCE(m.event_type, ''), m.created_at,
 			COALESCE(da.status_code, 0),
 			COALESCE(da.duration_ms, 0),
 			COALESCE(da.attempted_at, m.created_at)
 		FROM messages m
-		LEFT JOIN LATERAL (
-			SELECT status_code, duration_ms, attempted_at
-			FROM delivery_attempts
-			WHERE message_id = m.id
-			ORDER BY attempt_number DESC
-			LIMIT 1
-		) da ON true
 		%s
-		ORDER BY m.created_at DESC
-		LIMIT $%d OFFSET $%d
-	`, where, argIdx, argIdx+1)
-
-	args = append(args, limit, offset)
+		%s
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $%d
+	`, latestAttemptJoin, dataWhere, len(dataArgs))
 
-	rows, err := r.pool.Query(ctx, dataQuery, args...)
+	rows, err := r.pool.Query(ctx, dataQuery, dataArgs...)
 	if err != nil {
 		return nil, err
 	}
@@ -282,12 +493,12 @@ func (r *PostgresMessageRepository) FindWebhookLogs(ctx context.Context, orgID u
 	var entries []WebhookLogEntry
 	for rows.Next() {
 		var (
-			id          uuid.UUID
-			url, msgStatus, method string
-			createdAt, attemptedAt time.Time
-			statusCode, durationMs int
+			id                        uuid.UUID
+			url, msgStatus, eventType string
+			createdAt, attemptedAt    time.Time
+			statusCode, durationMs    int
 		)
-		if err := rows.Scan(&id, &url, &msgStatus, &method, &createdAt, &statusCode, &durationMs, &attemptedAt); err != nil {
+		if err := rows.Scan(&id, &url, &msgStatus, &eventType, &createdAt, &statusCode, &durationMs, &attemptedAt); err != nil {
 			return nil, err
 		}
 		entries = append(entries, WebhookLogEntry{
@@ -295,24 +506,141 @@ func (r *PostgresMessageRepository) FindWebhookLogs(ctx context.Context, orgID u
 			Endpoint:       url,
 			Status:         msgStatus,
 			StatusCode:     statusCode,
-			EventType:      method,
+			EventType:      eventType,
 			AttemptedAt:    attemptedAt.Format(time.RFC3339),
 			ResponseTimeMs: durationMs,
+			CreatedAt:      createdAt,
 		})
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	if entries == nil {
-		entries = []WebhookLogEntry{}
+	result := newPage(entries, page, func(e WebhookLogEntry) Cursor {
+		return Cursor{Time: e.CreatedAt, ID: e.ID}
+	})
+
+	// Count total; the attempt join is only paid for when filtering on it
+	join := ""
+	if filter.References("da.") {
+		join = latestAttemptJoin
+	}
+	if err := countTotal(ctx, r.pool, result, page, fmt.Sprintf("FROM messages m %s %s", join, where), args); err != nil {
+		return nil, err
 	}
 
-	return &WebhookLogsResult{
-		Data:       entries,
-		Total:      total,
-		Page:       page,
-		Limit:      limit,
-		TotalPages: totalPages,
-	}, nil
+	return result, nil
+}
+
+// PurgeBefore deletes up to limit of the org's finished messages created
+// before before, oldest first, along with their attempts, and returns how
+// many of each it deleted. Messages still being delivered or held are kept,
+// as are rows another transaction has locked; nothing is deleted once the
+// org is under legal hold. The blobs of purged messages are recorded in
+// orphaned_payloads for FindOrphanedPayloads.
+func (r *PostgresMessageRepository) PurgeBefore(ctx context.Context, orgID uuid.UUID, before time.Time, limit int) (int64, int64, error) {
+	var messages, attempts int64
+	err := r.pool.QueryRow(ctx, `
+		WITH batch AS (
+			SELECT m.id
+			FROM messages m
+			JOIN organizations o ON o.id = m.org_id AND NOT o.legal_hold
+			WHERE m.org_id = $1 AND m.created_at < $2 AND m.status IN ('success', 'failed', 'cancelled')
+			ORDER BY m.created_at
+			LIMIT $3
+			FOR UPDATE OF m SKIP LOCKED
+		), purged_attempts AS (
+			DELETE FROM delivery_attempts da USING batch
+			WHERE da.message_id = batch.id
+			RETURNING 1
+		), purged AS (
+			DELETE FROM messages m USING batch
+			WHERE m.id = batch.id
+			RETURNING m.payload_ref
+		), orphaned AS (
+			INSERT INTO orphaned_payloads (payload_ref)
+			SELECT DISTINCT payload_ref FROM purged WHERE payload_ref IS NOT NULL
+			ON CONFLICT (payload_ref) DO NOTHING
+		)
+		SELECT (SELECT COUNT(*) FROM purged), (SELECT COUNT(*) FROM purged_attempts)
+	`, orgID, before, limit).Scan(&messages, &attempts)
+	return messages, attempts, err
+}
+
+// FindOrphanedPayloads returns up to limit blob keys, oldest first, that were
+// orphaned before before and that no message refers to any more. Keys a new
+// message has picked up again are forgotten.
+func (r *PostgresMessageRepository) FindOrphanedPayloads(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `
+		WITH reused AS (
+			DELETE FROM orphaned_payloads o
+			WHERE o.orphaned_at < $1
+				AND EXISTS (SELECT 1 FROM messages m WHERE m.payload_ref = o.payload_ref)
+		)
+		SELECT o.payload_ref
+		FROM orphaned_payloads o
+		WHERE o.orphaned_at < $1
+			AND NOT EXISTS (SELECT 1 FROM messages m WHERE m.payload_ref = o.payload_ref)
+		ORDER BY o.orphaned_at
+		LIMIT $2
+	`, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []string
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// PurgeOrphanedPayload calls deleteBlob for an orphaned blob key that no
+// message refers to, then forgets the key. The key's row stays locked until
+// the blob is gone, so ClaimPayload waits for the delete to finish. It
+// reports whether the blob was deleted; the key is kept if deleteBlob fails.
+func (r *PostgresMessageRepository) PurgeOrphanedPayload(ctx context.Context, payloadRef string, deleteBlob func(context.Context) error) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var referenced bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM messages WHERE payload_ref = o.payload_ref)
+		FROM orphaned_payloads o
+		WHERE o.payload_ref = $1
+		FOR UPDATE OF o
+	`, payloadRef).Scan(&referenced)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !referenced {
+		if err := deleteBlob(ctx); err != nil {
+			return false, err
+		}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM orphaned_payloads WHERE payload_ref = $1`, payloadRef); err != nil {
+		return false, err
+	}
+	return !referenced, tx.Commit(ctx)
+}
+
+// ClaimPayload stops an orphaned blob key from being purged because a new
+// message is about to refer to it. If the purge is deleting the blob right
+// now, it waits until the delete is done, so the caller sees the blob gone
+// and writes it again.
+func (r *PostgresMessageRepository) ClaimPayload(ctx context.Context, payloadRef string) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM orphaned_payloads WHERE payload_ref = $1`, payloadRef)
+	return err
 }
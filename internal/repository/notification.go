@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresNotificationRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewNotificationRepository(pool *pgxpool.Pool) NotificationRepository {
+	return &PostgresNotificationRepository{
+		pool: pool,
+	}
+}
+
+func (r *PostgresNotificationRepository) Create(ctx context.Context, n *model.Notification) error {
+	return r.pool.QueryRow(ctx, `
+		INSERT INTO notifications (org_id, kind, endpoint_id, summary)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, n.OrgID, n.Kind, n.EndpointID, n.Summary).Scan(&n.ID, &n.CreatedAt)
+}
+
+// ExistsSince reports whether the org already has a notification of this
+// kind, so threshold alerts fire once per window instead of every check.
+func (r *PostgresNotificationRepository) ExistsSince(ctx context.Context, orgID uuid.UUID, kind string, since time.Time) (bool, error) {
+	var exists bool
+	err := r.pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM notifications
+			WHERE org_id = $1 AND kind = $2 AND created_at >= $3
+		)
+	`, orgID, kind, since).Scan(&exists)
+	return exists, err
+}
+
+// FindBetween returns up to limit notifications of the given kinds created
+// in (since, until], newest first, along with how many there are in total.
+func (r *PostgresNotificationRepository) FindBetween(ctx context.Context, orgID uuid.UUID, kinds []string, since, until time.Time, limit int) ([]*model.Notification, int, error) {
+	var total int
+	err := r.pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM notifications
+		WHERE org_id = $1 AND kind = ANY($2) AND created_at > $3 AND created_at <= $4
+	`, orgID, kinds, since, until).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, org_id, kind, endpoint_id, summary, created_at
+		FROM notifications
+		WHERE org_id = $1 AND kind = ANY($2) AND created_at > $3 AND created_at <= $4
+		ORDER BY created_at DESC
+		LIMIT $5
+	`, orgID, kinds, since, until, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var notifications []*model.Notification
+	for rows.Next() {
+		n := &model.Notification{}
+		if err := rows.Scan(&n.ID, &n.OrgID, &n.Kind, &n.EndpointID, &n.Summary, &n.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, total, rows.Err()
+}
+
+func (r *PostgresNotificationRepository) GetPreferences(ctx context.Context, userID, orgID uuid.UUID) (*model.NotificationPreferences, error) {
+	p := &model.NotificationPreferences{UserID: userID, OrgID: orgID}
+
+	err := r.pool.QueryRow(ctx, `
+		SELECT endpoint_disabled, dead_letters, success_rate, digest_interval_minutes, last_digest_at
+		FROM notification_preferences
+		WHERE user_id = $1 AND org_id = $2
+	`, userID, orgID).Scan(
+		&p.EndpointDisabled,
+		&p.DeadLetters,
+		&p.SuccessRate,
+		&p.DigestIntervalMinutes,
+		&p.LastDigestAt,
+	)
+	if err == pgx.ErrNoRows {
+		p.EndpointDisabled = true
+		p.DeadLetters = true
+		p.SuccessRate = true
+		p.DigestIntervalMinutes = 60
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (r *PostgresNotificationRepository) UpsertPreferences(ctx context.Context, p *model.NotificationPreferences) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO notification_preferences (user_id, org_id, endpoint_disabled, dead_letters, success_rate, digest_interval_minutes)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, org_id) DO UPDATE
+		SET endpoint_disabled = EXCLUDED.endpoint_disabled,
+			dead_letters = EXCLUDED.dead_letters,
+			success_rate = EXCLUDED.success_rate,
+			digest_interval_minutes = EXCLUDED.digest_interval_minutes
+	`, p.UserID, p.OrgID, p.EndpointDisabled, p.DeadLetters, p.SuccessRate, p.DigestIntervalMinutes)
+	return err
+}
+
+// FindDueDigests returns the org admins whose digest interval has passed
+// and whose org has had notifications since their last digest. Admins who
+// never got a digest start from when they joined the org.
+func (r *PostgresNotificationRepository) FindDueDigests(ctx context.Context) ([]*DigestRecipient, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT u.id, u.email, u.first_name, o.id, o.name,
+			COALESCE(p.endpoint_disabled, TRUE),
+			COALESCE(p.dead_letters, TRUE),
+			COALESCE(p.success_rate, TRUE),
+			COALESCE(p.digest_interval_minutes, 60),
+			COALESCE(p.last_digest_at, m.created_at)
+		FROM memberships m
+		JOIN users u ON u.id = m.user_id
+		JOIN organizations o ON o.id = m.org_id
+		LEFT JOIN notification_preferences p ON p.user_id = m.user_id AND p.org_id = m.org_id
+		WHERE m.role = 'admin'
+			AND (p.last_digest_at IS NULL
+				OR p.last_digest_at <= NOW() - make_interval(mins => p.digest_interval_minutes))
+			AND EXISTS (
+				SELECT 1 FROM notifications n
+				WHERE n.org_id = m.org_id AND n.created_at > COALESCE(p.last_digest_at, m.created_at)
+			)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []*DigestRecipient
+	for rows.Next() {
+		d := &DigestRecipient{}
+		if err := rows.Scan(
+			&d.Preferences.UserID, &d.Email, &d.FirstName, &d.Preferences.OrgID, &d.OrgName,
+			&d.Preferences.EndpointDisabled, &d.Preferences.DeadLetters, &d.Preferences.SuccessRate,
+			&d.Preferences.DigestIntervalMinutes, &d.Since,
+		); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, d)
+	}
+
+	return recipients, rows.Err()
+}
+
+// MarkDigestSent moves the user's digest cursor to at. Notifications up to
+// that point are never mailed to them again.
+func (r *PostgresNotificationRepository) MarkDigestSent(ctx context.Context, userID, orgID uuid.UUID, at time.Time) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO notification_preferences (user_id, org_id, last_digest_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, org_id) DO UPDATE
+		SET last_digest_at = EXCLUDED.last_digest_at
+	`, userID, orgID, at)
+	return err
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/bilalabdelkadir/chis/internal/model"
 	"github.com/google/uuid"
@@ -36,9 +37,11 @@ func (r *PostgresOrganizationRepository) Create(ctx context.Context, organizatio
 func (r *PostgresOrganizationRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.Organization, error) {
 	org := &model.Organization{}
 	err := r.pool.QueryRow(ctx, `
-		SELECT id, name, slug, signing_secret, created_at, updated_at
+		SELECT id, name, slug, signing_secret, previous_signing_secret, previous_secret_expires_at,
+			max_payload_bytes, plan_id, max_deliveries_per_second, signature_scheme, signature_profile, created_at, updated_at
 		FROM organizations WHERE id = $1
-	`, id).Scan(&org.ID, &org.Name, &org.Slug, &org.SigningSecret, &org.CreatedAt, &org.UpdatedAt)
+	`, id).Scan(&org.ID, &org.Name, &org.Slug, &org.SigningSecret, &org.PreviousSigningSecret, &org.PreviousSecretExpiresAt,
+		&org.MaxPayloadBytes, &org.PlanID, &org.MaxDeliveriesPerSecond, &org.SignatureScheme, &org.SignatureProfile, &org.CreatedAt, &org.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -61,10 +64,17 @@ func (r *PostgresOrganizationRepository) GetSigningSecret(ctx context.Context, o
 	return secret, nil
 }
 
-func (r *PostgresOrganizationRepository) RotateSigningSecret(ctx context.Context, orgID uuid.UUID, newSecret string) error {
+// RotateSigningSecret installs newSecret. When previousExpiresAt is set the
+// old secret is kept until then; otherwise it is dropped immediately.
+func (r *PostgresOrganizationRepository) RotateSigningSecret(ctx context.Context, orgID uuid.UUID, newSecret string, previousExpiresAt *time.Time) error {
 	tag, err := r.pool.Exec(ctx, `
-		UPDATE organizations SET signing_secret = $1, updated_at = NOW() WHERE id = $2
-	`, newSecret, orgID)
+		UPDATE organizations
+		SET signing_secret = $1,
+			previous_signing_secret = CASE WHEN $3::timestamptz IS NULL THEN NULL ELSE signing_secret END,
+			previous_secret_expires_at = $3,
+			updated_at = NOW()
+		WHERE id = $2
+	`, newSecret, orgID, previousExpiresAt)
 	if err != nil {
 		return err
 	}
@@ -73,3 +83,206 @@ func (r *PostgresOrganizationRepository) RotateSigningSecret(ctx context.Context
 	}
 	return nil
 }
+
+func (r *PostgresOrganizationRepository) ExpirePreviousSigningSecret(ctx context.Context, orgID uuid.UUID) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE organizations
+		SET previous_signing_secret = NULL, previous_secret_expires_at = NULL
+		WHERE id = $1
+	`, orgID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("organization not found")
+	}
+	return nil
+}
+
+// GetMaxPayloadBytes returns the lower of the org's own limit and its
+// plan's, or nil when neither is set.
+func (r *PostgresOrganizationRepository) GetMaxPayloadBytes(ctx context.Context, orgID uuid.UUID) (*int, error) {
+	var maxPayloadBytes *int
+	err := r.pool.QueryRow(ctx, `
+		SELECT LEAST(o.max_payload_bytes, p.max_payload_bytes)
+		FROM organizations o
+		JOIN plans p ON p.id = o.plan_id
+		WHERE o.id = $1
+	`, orgID).Scan(&maxPayloadBytes)
+	if err != nil {
+		return nil, err
+	}
+	return maxPayloadBytes, nil
+}
+
+func (r *PostgresOrganizationRepository) UpdateMaxPayloadBytes(ctx context.Context, orgID uuid.UUID, maxPayloadBytes *int) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE organizations SET max_payload_bytes = $1 WHERE id = $2
+	`, maxPayloadBytes, orgID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("organization not found")
+	}
+	return nil
+}
+
+// FindDeliveryRates returns the delivery rate cap of every org that has one.
+func (r *PostgresOrganizationRepository) FindDeliveryRates(ctx context.Context) (map[uuid.UUID]int, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, max_deliveries_per_second FROM organizations
+		WHERE max_deliveries_per_second IS NOT NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var (
+			orgID     uuid.UUID
+			perSecond int
+		)
+		if err := rows.Scan(&orgID, &perSecond); err != nil {
+			return nil, err
+		}
+		rates[orgID] = perSecond
+	}
+	return rates, rows.Err()
+}
+
+func (r *PostgresOrganizationRepository) UpdateMaxDeliveriesPerSecond(ctx context.Context, orgID uuid.UUID, maxDeliveriesPerSecond *int) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE organizations SET max_deliveries_per_second = $1 WHERE id = $2
+	`, maxDeliveriesPerSecond, orgID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("organization not found")
+	}
+	return nil
+}
+
+func (r *PostgresOrganizationRepository) UpdateSignatureProfile(ctx context.Context, orgID uuid.UUID, profile string) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE organizations SET signature_profile = $1 WHERE id = $2
+	`, profile, orgID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("organization not found")
+	}
+	return nil
+}
+
+func (r *PostgresOrganizationRepository) UpdateSignatureScheme(ctx context.Context, orgID uuid.UUID, scheme string) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE organizations SET signature_scheme = $1 WHERE id = $2
+	`, scheme, orgID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("organization not found")
+	}
+	return nil
+}
+
+func (r *PostgresOrganizationRepository) GetRedactPaths(ctx context.Context, orgID uuid.UUID) ([]string, error) {
+	var paths []string
+	err := r.pool.QueryRow(ctx, `
+		SELECT redact_paths FROM organizations WHERE id = $1
+	`, orgID).Scan(&paths)
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+func (r *PostgresOrganizationRepository) UpdateRedactPaths(ctx context.Context, orgID uuid.UUID, paths []string) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE organizations SET redact_paths = $1 WHERE id = $2
+	`, paths, orgID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("organization not found")
+	}
+	return nil
+}
+
+// GetRetention returns how many days the org's messages are kept, its own
+// override or else its plan's, and whether it is under legal hold.
+func (r *PostgresOrganizationRepository) GetRetention(ctx context.Context, orgID uuid.UUID) (int, bool, error) {
+	var (
+		days      int
+		legalHold bool
+	)
+	err := r.pool.QueryRow(ctx, `
+		SELECT COALESCE(o.retention_days, p.retention_days), o.legal_hold
+		FROM organizations o
+		JOIN plans p ON p.id = o.plan_id
+		WHERE o.id = $1
+	`, orgID).Scan(&days, &legalHold)
+	return days, legalHold, err
+}
+
+// FindRetentionPolicies returns each org's retention, its own or else its
+// plan's. Orgs under legal hold are left out.
+func (r *PostgresOrganizationRepository) FindRetentionPolicies(ctx context.Context) ([]RetentionPolicy, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT o.id, COALESCE(o.retention_days, p.retention_days)
+		FROM organizations o
+		JOIN plans p ON p.id = o.plan_id
+		WHERE NOT o.legal_hold
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []RetentionPolicy
+	for rows.Next() {
+		var p RetentionPolicy
+		if err := rows.Scan(&p.OrgID, &p.RetentionDays); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// SetRetentionDays overrides the org's plan retention; nil goes back to the
+// plan's. Unknown orgs return ErrNotFound.
+func (r *PostgresOrganizationRepository) SetRetentionDays(ctx context.Context, orgID uuid.UUID, days *int) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE organizations SET retention_days = $1 WHERE id = $2
+	`, days, orgID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetLegalHold exempts the org from purging, or lifts the exemption.
+// Unknown orgs return ErrNotFound.
+func (r *PostgresOrganizationRepository) SetLegalHold(ctx context.Context, orgID uuid.UUID, hold bool) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE organizations SET legal_hold = $1 WHERE id = $2
+	`, hold, orgID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrInvalidCursor is returned by DecodeCursor for cursors it did not
+// produce.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in a list ordered by a timestamp and then ID, such as
+// (created_at, id). Lists return the cursor of their last item so the next
+// page starts right after it, which stays correct while rows are inserted.
+type Cursor struct {
+	Time time.Time
+	ID   uuid.UUID
+}
+
+// Encode returns the cursor as an opaque, URL-safe string.
+func (c Cursor) Encode() string {
+	raw := c.Time.UTC().Format(time.RFC3339Nano) + "," + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{Time: t, ID: parsedID}, nil
+}
+
+// Count says how a page's total is worked out.
+type Count string
+
+const (
+	// CountNone leaves the total out.
+	CountNone Count = ""
+	// CountExact runs a COUNT(*), which reads every matching row.
+	CountExact Count = "exact"
+	// CountEstimate takes the planner's row estimate, which costs next to
+	// nothing but can be well off for selective filters.
+	CountEstimate Count = "estimate"
+)
+
+// PageRequest asks for up to Limit items after the After cursor, or from the
+// start of the list when it is nil.
+type PageRequest struct {
+	After *Cursor
+	Limit int
+	Count Count
+}
+
+// Page is one page of a list. NextCursor is nil on the last page.
+type Page[T any] struct {
+	Data           []T     `json:"data"`
+	Limit          int     `json:"limit"`
+	NextCursor     *string `json:"nextCursor"`
+	Total          *int    `json:"total,omitempty"`
+	TotalEstimated bool    `json:"totalEstimated,omitempty"`
+}
+
+const defaultPageLimit = 20
+
+func (p PageRequest) limit() int {
+	if p.Limit < 1 {
+		return defaultPageLimit
+	}
+	return p.Limit
+}
+
+// keysetCondition returns the condition selecting rows after the request's
+// cursor in a list ordered by timeCol and idCol, descending unless asc, with
+// its arguments appended to args. It is "" for the first page.
+func (p PageRequest) keysetCondition(timeCol, idCol string, asc bool, args []any) (string, []any) {
+	if p.After == nil {
+		return "", args
+	}
+	op := "<"
+	if asc {
+		op = ">"
+	}
+	args = append(args, p.After.Time, p.After.ID)
+	return fmt.Sprintf("(%s, %s) %s ($%d, $%d)", timeCol, idCol, op, len(args)-1, len(args)), args
+}
+
+// newPage trims items fetched with a limit one higher than the request's
+// down to the page and sets the next cursor when there was more.
+func newPage[T any](items []T, p PageRequest, cursor func(T) Cursor) *Page[T] {
+	limit := p.limit()
+	page := &Page[T]{Data: items, Limit: limit}
+	if len(items) > limit {
+		page.Data = items[:limit]
+		next := cursor(page.Data[limit-1]).Encode()
+		page.NextCursor = &next
+	}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	return page
+}
+
+// countTotal sets the page's total as the request asks, counting the rows of
+// from, a FROM clause with its joins and WHERE. A first page that is also the
+// last needs no query.
+func countTotal[T any](ctx context.Context, pool *pgxpool.Pool, page *Page[T], p PageRequest, from string, args []any) error {
+	if p.Count != CountNone && p.After == nil && page.NextCursor == nil {
+		total := len(page.Data)
+		page.Total = &total
+		return nil
+	}
+
+	switch p.Count {
+	case CountExact:
+		var total int
+		if err := pool.QueryRow(ctx, "SELECT COUNT(*) "+from, args...).Scan(&total); err != nil {
+			return err
+		}
+		page.Total = &total
+	case CountEstimate:
+		var plan []byte
+		if err := pool.QueryRow(ctx, "EXPLAIN (FORMAT JSON) SELECT 1 "+from, args...).Scan(&plan); err != nil {
+			return err
+		}
+		var explained []struct {
+			Plan struct {
+				Rows float64 `json:"Plan Rows"`
+			} `json:"Plan"`
+		}
+		if err := json.Unmarshal(plan, &explained); err != nil {
+			return fmt.Errorf("reading query plan: %w", err)
+		}
+		if len(explained) == 0 {
+			return errors.New("reading query plan: empty plan")
+		}
+		total := int(explained[0].Plan.Rows)
+		page.Total = &total
+		page.TotalEstimated = true
+	}
+	return nil
+}
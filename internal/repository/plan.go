@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresPlanRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPlanRepository(pool *pgxpool.Pool) PlanRepository {
+	return &PostgresPlanRepository{
+		pool: pool,
+	}
+}
+
+const planColumns = `p.id, p.name, p.monthly_message_quota, p.max_endpoints, p.max_api_keys, p.retention_days,
+	p.max_payload_bytes, p.created_at, p.updated_at`
+
+func scanPlan(row pgx.Row) (*model.Plan, error) {
+	p := &model.Plan{}
+	err := row.Scan(
+		&p.ID,
+		&p.Name,
+		&p.MonthlyMessageQuota,
+		&p.MaxEndpoints,
+		&p.MaxApiKeys,
+		&p.RetentionDays,
+		&p.MaxPayloadBytes,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *PostgresPlanRepository) FindAll(ctx context.Context) ([]*model.Plan, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+planColumns+`
+		FROM plans p
+		ORDER BY p.monthly_message_quota ASC NULLS LAST
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plans []*model.Plan
+	for rows.Next() {
+		p, err := scanPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, p)
+	}
+	return plans, rows.Err()
+}
+
+func (r *PostgresPlanRepository) FindByOrgID(ctx context.Context, orgID uuid.UUID) (*model.Plan, error) {
+	return scanPlan(r.pool.QueryRow(ctx, `
+		SELECT `+planColumns+`
+		FROM plans p
+		JOIN organizations o ON o.plan_id = p.id
+		WHERE o.id = $1
+	`, orgID))
+}
+
+// SetOrgPlan moves the org to another plan. Unknown orgs and plans return
+// ErrNotFound.
+func (r *PostgresPlanRepository) SetOrgPlan(ctx context.Context, orgID uuid.UUID, planID string) error {
+	result, err := r.pool.Exec(ctx, `
+		UPDATE organizations SET plan_id = $1 WHERE id = $2
+	`, planID, orgID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresSigningKeyRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewSigningKeyRepository(pool *pgxpool.Pool) SigningKeyRepository {
+	return &PostgresSigningKeyRepository{
+		pool: pool,
+	}
+}
+
+func (r *PostgresSigningKeyRepository) FindActiveByOrgID(ctx context.Context, orgID uuid.UUID) (*model.SigningKey, error) {
+	k := &model.SigningKey{}
+
+	err := r.pool.QueryRow(ctx, `
+		SELECT id, org_id, public_key, private_key, status, retired_at, created_at
+		FROM signing_keys
+		WHERE org_id = $1 AND status = 'active'
+	`, orgID).Scan(
+		&k.ID,
+		&k.OrgID,
+		&k.PublicKey,
+		&k.PrivateKey,
+		&k.Status,
+		&k.RetiredAt,
+		&k.CreatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return k, nil
+}
+
+func (r *PostgresSigningKeyRepository) FindByOrgID(ctx context.Context, orgID uuid.UUID) ([]*model.SigningKey, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, org_id, public_key, status, retired_at, created_at
+		FROM signing_keys
+		WHERE org_id = $1
+		ORDER BY created_at DESC
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*model.SigningKey
+	for rows.Next() {
+		k := &model.SigningKey{}
+		if err := rows.Scan(&k.ID, &k.OrgID, &k.PublicKey, &k.Status, &k.RetiredAt, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+// Rotate retires the org's current active key, if any, and stores key as the
+// new active one. Retired keys stay published so receivers can still verify
+// in-flight deliveries.
+func (r *PostgresSigningKeyRepository) Rotate(ctx context.Context, key *model.SigningKey) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE signing_keys
+		SET status = 'retired', retired_at = NOW()
+		WHERE org_id = $1 AND status = 'active'
+	`, key.OrgID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO signing_keys (org_id, public_key, private_key)
+		VALUES ($1, $2, $3)
+		RETURNING id, status, created_at
+	`, key.OrgID, key.PublicKey, key.PrivateKey).Scan(&key.ID, &key.Status, &key.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresSigningKeyRepository) DeleteRetired(ctx context.Context, orgID, id uuid.UUID) error {
+	result, err := r.pool.Exec(ctx, `
+		DELETE FROM signing_keys
+		WHERE id = $1 AND org_id = $2 AND status = 'retired'
+	`, id, orgID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresUsageRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewUsageRepository(pool *pgxpool.Pool) UsageRepository {
+	return &PostgresUsageRepository{
+		pool: pool,
+	}
+}
+
+// Record adds to the org's counters for the current UTC day.
+func (r *PostgresUsageRepository) Record(ctx context.Context, orgID uuid.UUID, messages, payloadBytes, deliveryAttempts int64) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO usage_daily (org_id, day, messages, payload_bytes, delivery_attempts)
+		VALUES ($1, (NOW() AT TIME ZONE 'UTC')::date, $2, $3, $4)
+		ON CONFLICT (org_id, day) DO UPDATE SET
+			messages = usage_daily.messages + EXCLUDED.messages,
+			payload_bytes = usage_daily.payload_bytes + EXCLUDED.payload_bytes,
+			delivery_attempts = usage_daily.delivery_attempts + EXCLUDED.delivery_attempts
+	`, orgID, messages, payloadBytes, deliveryAttempts)
+	return err
+}
+
+// MessagesSince totals the org's messages from the UTC day of since.
+func (r *PostgresUsageRepository) MessagesSince(ctx context.Context, orgID uuid.UUID, since time.Time) (int64, error) {
+	var total int64
+	err := r.pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(messages), 0)
+		FROM usage_daily
+		WHERE org_id = $1 AND day >= $2::date
+	`, orgID, since.UTC().Format(time.DateOnly)).Scan(&total)
+	return total, err
+}
+
+// FindByOrgID returns the org's usage for the days from..to, inclusive.
+// Days without usage are left out.
+func (r *PostgresUsageRepository) FindByOrgID(ctx context.Context, orgID uuid.UUID, from, to time.Time) ([]*model.UsageDay, error) {
+	return r.find(ctx, `WHERE org_id = $1 AND day BETWEEN $2::date AND $3::date`,
+		orgID, from.UTC().Format(time.DateOnly), to.UTC().Format(time.DateOnly))
+}
+
+// FindAll returns every org's usage for the days from..to, for billing.
+func (r *PostgresUsageRepository) FindAll(ctx context.Context, from, to time.Time) ([]*model.UsageDay, error) {
+	return r.find(ctx, `WHERE day BETWEEN $1::date AND $2::date`,
+		from.UTC().Format(time.DateOnly), to.UTC().Format(time.DateOnly))
+}
+
+func (r *PostgresUsageRepository) find(ctx context.Context, where string, args ...any) ([]*model.UsageDay, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT org_id, day, messages, payload_bytes, delivery_attempts
+		FROM usage_daily
+		`+where+`
+		ORDER BY org_id, day
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []*model.UsageDay
+	for rows.Next() {
+		d := &model.UsageDay{}
+		if err := rows.Scan(&d.OrgID, &d.Day, &d.Messages, &d.PayloadBytes, &d.DeliveryAttempts); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
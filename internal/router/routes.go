@@ -13,6 +13,15 @@ func Setup(r *Router,
 	dashboardHandler *handler.DashboardHandler,
 	orgHandler *handler.OrganizationHandler,
 	invitationHandler *handler.InvitationHandler,
+	endpointHandler *handler.EndpointHandler,
+	signingKeyHandler *handler.SigningKeyHandler,
+	messageHandler *handler.MessageHandler,
+	listenHandler *handler.ListenHandler,
+	notificationHandler *handler.NotificationHandler,
+	usageHandler *handler.UsageHandler,
+	reportHandler *handler.ReportHandler,
+	exportHandler *handler.ExportHandler,
+	logStreamHandler *handler.LogStreamHandler,
 	apiKeyRepo repository.ApiKeyRepository,
 	membershipRepo repository.MembershipRepository,
 	secret string,
@@ -23,9 +32,33 @@ func Setup(r *Router,
 		r.Post("/login", authHandler.Login)
 	})
 
+	r.Get("/public/orgs/{orgId}/signing-keys", signingKeyHandler.PublicKeys)
+
 	r.Route("/webhook", func(r *Router) {
 		r.Use(middleware.ValidateApiKey(apiKeyRepo))
 		r.Post("/send", webhookHandler.Send)
+		r.Post("/send/batch", webhookHandler.SendBatch)
+
+		r.Route("/messages", func(r *Router) {
+			r.Get("/{id}", messageHandler.Get)
+			r.Post("/{id}/replay", messageHandler.Replay)
+			r.Post("/{id}/cancel", messageHandler.Cancel)
+		})
+
+		r.Route("/endpoints", func(r *Router) {
+			r.Post("/", endpointHandler.Create)
+			r.Get("/", endpointHandler.List)
+			r.Get("/{id}", endpointHandler.Get)
+			r.Patch("/{id}", endpointHandler.Update)
+			r.Delete("/{id}", endpointHandler.Delete)
+			r.Get("/{id}/health", endpointHandler.Health)
+			r.Post("/{id}/disable", endpointHandler.Disable)
+			r.Post("/{id}/enable", endpointHandler.Enable)
+		})
+
+		// Long-poll session for `chis listen`
+		r.Get("/listen/{id}", listenHandler.Poll)
+		r.Post("/listen/{id}/responses/{requestId}", listenHandler.Respond)
 	})
 
 	r.Route("/api", func(r *Router) {
@@ -50,12 +83,58 @@ func Setup(r *Router,
 				r.Delete("/{id}", apiKeyHandler.Delete)
 			})
 
+			r.Route("/endpoints", func(r *Router) {
+				r.Post("/", endpointHandler.Create)
+				r.Get("/", endpointHandler.List)
+				r.Get("/{id}", endpointHandler.Get)
+				r.Patch("/{id}", endpointHandler.Update)
+				r.Delete("/{id}", endpointHandler.Delete)
+				r.Get("/{id}/health", endpointHandler.Health)
+				r.Post("/{id}/disable", endpointHandler.Disable)
+				r.Post("/{id}/enable", endpointHandler.Enable)
+
+				r.Route("/{id}/signing-secret", func(r *Router) {
+					r.Use(middleware.RequireAdmin(membershipRepo))
+					r.Get("/", endpointHandler.GetSigningSecret)
+					r.Post("/rotate", endpointHandler.RotateSigningSecret)
+					r.Post("/expire-previous", endpointHandler.ExpirePreviousSigningSecret)
+				})
+			})
+
 			r.Route("/dashboard", func(r *Router) {
 				r.Get("/stats", dashboardHandler.Stats)
+				r.Get("/timeseries", dashboardHandler.Timeseries)
+			})
+
+			r.Route("/reports", func(r *Router) {
+				r.Get("/endpoints", reportHandler.Endpoints)
+				r.Get("/api-keys", reportHandler.ApiKeys)
 			})
 
 			r.Get("/webhook-logs", dashboardHandler.WebhookLogs)
+			r.Get("/webhook-logs/stream", logStreamHandler.Stream)
 			r.Get("/webhook-logs/{id}", dashboardHandler.WebhookLogDetail)
+			r.Get("/webhook-logs/{id}/attempts", dashboardHandler.WebhookLogAttempts)
+
+			r.Route("/exports", func(r *Router) {
+				r.Get("/stream", exportHandler.Stream)
+				r.Post("/", exportHandler.CreateJob)
+				r.Get("/", exportHandler.ListJobs)
+				r.Get("/{id}", exportHandler.GetJob)
+				r.Get("/{id}/download", exportHandler.Download)
+			})
+
+			r.Get("/plans", usageHandler.Plans)
+			r.Route("/usage", func(r *Router) {
+				r.Get("/", usageHandler.Summary)
+				r.Get("/daily", usageHandler.Daily)
+			})
+
+			r.Route("/notifications", func(r *Router) {
+				r.Get("/", notificationHandler.List)
+				r.Get("/preferences", notificationHandler.GetPreferences)
+				r.Put("/preferences", notificationHandler.UpdatePreferences)
+			})
 
 			// Admin-only routes
 			r.Route("/invitations", func(r *Router) {
@@ -70,6 +149,16 @@ func Setup(r *Router,
 				r.Delete("/", orgHandler.DeleteOrg)
 				r.Get("/signing-secret", orgHandler.GetSigningSecret)
 				r.Post("/signing-secret/rotate", orgHandler.RotateSigningSecret)
+				r.Post("/signing-secret/expire-previous", orgHandler.ExpirePreviousSigningSecret)
+				r.Put("/payload-limit", orgHandler.UpdatePayloadLimit)
+				r.Put("/delivery-rate", orgHandler.UpdateDeliveryRate)
+				r.Put("/signature-scheme", signingKeyHandler.UpdateScheme)
+				r.Put("/signature-profile", signingKeyHandler.UpdateProfile)
+				r.Get("/redaction", exportHandler.GetRedaction)
+				r.Put("/redaction", exportHandler.UpdateRedaction)
+				r.Get("/signing-keys", signingKeyHandler.List)
+				r.Post("/signing-keys/rotate", signingKeyHandler.Rotate)
+				r.Delete("/signing-keys/{id}", signingKeyHandler.Delete)
 			})
 		})
 	})
@@ -0,0 +1,170 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/blobstore"
+	"github.com/bilalabdelkadir/chis/internal/export"
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/bilalabdelkadir/chis/internal/search"
+)
+
+const (
+	exportPollInterval = 5 * time.Second
+	// exportStaleAfter is how long a job may run before another scheduler
+	// assumes its runner died and starts it over.
+	exportStaleAfter = time.Hour
+	// exportRetention is how long finished export files can be downloaded.
+	exportRetention = 7 * 24 * time.Hour
+)
+
+// ExportRunner runs export jobs one at a time. Each export is written to a
+// temporary file first, since the blob store needs the size up front, then
+// uploaded; expired files are deleted along with their jobs.
+type ExportRunner struct {
+	exportRepo repository.ExportRepository
+	exporter   *export.Exporter
+	blobs      blobstore.Store
+}
+
+func NewExportRunner(exportRepo repository.ExportRepository, exporter *export.Exporter, blobs blobstore.Store) *ExportRunner {
+	return &ExportRunner{
+		exportRepo: exportRepo,
+		exporter:   exporter,
+		blobs:      blobs,
+	}
+}
+
+func (r *ExportRunner) Start(ctx context.Context) {
+	ticker := time.NewTicker(exportPollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.deleteExpired(ctx)
+
+		// Drain the backlog before waiting again.
+		for {
+			job, err := r.exportRepo.ClaimJob(ctx, time.Now().Add(-exportStaleAfter))
+			if errors.Is(err, repository.ErrNotFound) {
+				break
+			}
+			if err != nil {
+				slog.Error("export_claim_failed", "error", err)
+				break
+			}
+			r.run(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run writes the job's file. A runner that took longer than exportStaleAfter
+// may find the job taken over when it finishes; its claim is then lost, so it
+// leaves the job alone and deletes the file it wrote.
+func (r *ExportRunner) run(ctx context.Context, job *model.ExportJob) {
+	start := time.Now()
+	// ClaimJob always sets it.
+	claimedAt := *job.StartedAt
+	messages, size, fileRef, err := r.write(ctx, job)
+	if err != nil {
+		slog.Error("export_failed", "job_id", job.ID, "org_id", job.OrgID, "error", err)
+		err := r.exportRepo.FailJob(ctx, job.ID, claimedAt, "export failed; request a new one")
+		if errors.Is(err, repository.ErrNotFound) {
+			slog.Warn("export_claim_lost", "job_id", job.ID)
+		} else if err != nil {
+			slog.Error("export_fail_record_failed", "job_id", job.ID, "error", err)
+		}
+		return
+	}
+
+	err = r.exportRepo.CompleteJob(ctx, job.ID, claimedAt, messages, size, fileRef, time.Now().Add(exportRetention))
+	if errors.Is(err, repository.ErrNotFound) {
+		slog.Warn("export_claim_lost", "job_id", job.ID)
+		if err := r.blobs.Delete(ctx, fileRef); err != nil && !errors.Is(err, blobstore.ErrNotFound) {
+			slog.Error("export_file_delete_failed", "job_id", job.ID, "file_ref", fileRef, "error", err)
+		}
+		return
+	}
+	if err != nil {
+		slog.Error("export_complete_record_failed", "job_id", job.ID, "error", err)
+		return
+	}
+	slog.Info("export_completed", "job_id", job.ID, "org_id", job.OrgID, "messages", messages, "size_bytes", size,
+		"duration_ms", time.Since(start).Milliseconds())
+}
+
+func (r *ExportRunner) write(ctx context.Context, job *model.ExportJob) (int64, int64, string, error) {
+	// The query was checked when the job was created.
+	filter, err := search.Parse(job.Query, repository.WebhookLogSchema)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("invalid query: %w", err)
+	}
+
+	tmp, err := os.CreateTemp("", "chis-export-*")
+	if err != nil {
+		return 0, 0, "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	messages, err := r.exporter.Write(ctx, tmp, job.Format, repository.ExportFilter{
+		OrgID:  job.OrgID,
+		From:   job.From,
+		To:     job.To,
+		Status: job.MessageStatus,
+		Filter: filter,
+	})
+	if err != nil {
+		return 0, 0, "", err
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, "", err
+	}
+
+	// Each claim writes its own file, so a runner that lost the job can't
+	// overwrite the file of the one that took it over.
+	fileRef := fmt.Sprintf("export-%s-%d.%s", job.ID, job.StartedAt.UnixMicro(), job.Format)
+	if err := r.blobs.Put(ctx, fileRef, tmp, size); err != nil {
+		return 0, 0, "", fmt.Errorf("upload: %w", err)
+	}
+	return messages, size, fileRef, nil
+}
+
+// deleteExpired removes each expired job's file, then the job. A job whose
+// file can't be deleted is kept and tried again on the next pass, so no file
+// is left behind without a job pointing to it.
+func (r *ExportRunner) deleteExpired(ctx context.Context) {
+	jobs, err := r.exportRepo.FindExpiredJobs(ctx, time.Now())
+	if err != nil {
+		slog.Error("export_expiry_failed", "error", err)
+		return
+	}
+	for _, job := range jobs {
+		if job.FileRef != nil {
+			if err := r.blobs.Delete(ctx, *job.FileRef); err != nil && !errors.Is(err, blobstore.ErrNotFound) {
+				slog.Error("export_file_delete_failed", "job_id", job.ID, "file_ref", *job.FileRef, "error", err)
+				continue
+			}
+		}
+		if err := r.exportRepo.DeleteJob(ctx, job.ID); err != nil {
+			slog.Error("export_job_delete_failed", "job_id", job.ID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/queue"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+)
+
+const rateSyncInterval = time.Minute
+
+// RateSyncer copies every org's delivery rate cap into the queue. Caps are
+// written when an admin changes them; this brings them back if Redis lost
+// them or was unreachable at the time.
+type RateSyncer struct {
+	orgRepo repository.OrganizationRepository
+	queue   *queue.Queue
+}
+
+func NewRateSyncer(orgRepo repository.OrganizationRepository, queue *queue.Queue) *RateSyncer {
+	return &RateSyncer{
+		orgRepo: orgRepo,
+		queue:   queue,
+	}
+}
+
+func (s *RateSyncer) Start(ctx context.Context) {
+	ticker := time.NewTicker(rateSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		rates, err := s.orgRepo.FindDeliveryRates(ctx)
+		if err != nil {
+			slog.Error("org_rates_lookup_failed", "error", err)
+		} else if err := s.queue.SyncOrgRates(ctx, rates); err != nil {
+			slog.Error("org_rate_sync_failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
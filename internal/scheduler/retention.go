@@ -0,0 +1,173 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/blobstore"
+	"github.com/bilalabdelkadir/chis/internal/metrics"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+)
+
+const (
+	retentionInterval = time.Hour
+	// retentionBatchSize keeps each delete short, so it holds its locks only
+	// briefly and never stalls deliveries writing to the same tables.
+	retentionBatchSize = 1000
+	// retentionBatchPause spaces batches out so a large backlog is worked
+	// off gradually instead of saturating the database.
+	retentionBatchPause = 100 * time.Millisecond
+	// orphanGracePeriod leaves a purged message's blob alone for a while, so
+	// a new message with the same payload can pick it up again instead. It
+	// also covers the moment between ingest claiming a blob and saving the
+	// message that refers to it.
+	orphanGracePeriod = time.Hour
+)
+
+// RetentionPurger deletes messages, and their attempts, once they are older
+// than their org's retention. Orgs under legal hold are skipped. The hourly
+// delivery rollups are kept, so the dashboard's history outlives the logs.
+// Offloaded payloads are deleted from the blob store once no message refers
+// to them; blobs is nil when there is no blob store.
+type RetentionPurger struct {
+	orgRepo     repository.OrganizationRepository
+	messageRepo repository.MessageRepository
+	blobs       blobstore.Store
+}
+
+func NewRetentionPurger(orgRepo repository.OrganizationRepository, messageRepo repository.MessageRepository,
+	blobs blobstore.Store,
+) *RetentionPurger {
+	return &RetentionPurger{
+		orgRepo:     orgRepo,
+		messageRepo: messageRepo,
+		blobs:       blobs,
+	}
+}
+
+func (p *RetentionPurger) Start(ctx context.Context) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *RetentionPurger) purge(ctx context.Context) {
+	policies, err := p.orgRepo.FindRetentionPolicies(ctx)
+	if err != nil {
+		slog.Error("retention_policies_lookup_failed", "error", err)
+		return
+	}
+
+	start := time.Now()
+	failed := false
+	for _, policy := range policies {
+		if err := p.purgeOrg(ctx, policy); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("retention_purge_failed", "org_id", policy.OrgID, "error", err)
+			failed = true
+		}
+	}
+	if p.blobs != nil {
+		if err := p.purgePayloads(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("retention_payload_purge_failed", "error", err)
+			failed = true
+		}
+	}
+	if !failed {
+		metrics.RetentionLastPurge.SetToCurrentTime()
+	}
+	slog.Debug("retention_purge_completed", "orgs", len(policies), "duration_ms", time.Since(start).Milliseconds())
+}
+
+func (p *RetentionPurger) purgeOrg(ctx context.Context, policy repository.RetentionPolicy) error {
+	before := time.Now().AddDate(0, 0, -policy.RetentionDays)
+
+	var messages, attempts int64
+	defer func() {
+		if messages > 0 {
+			slog.Info("retention_purged", "org_id", policy.OrgID, "retention_days", policy.RetentionDays,
+				"messages", messages, "attempts", attempts)
+		}
+	}()
+
+	for {
+		m, a, err := p.messageRepo.PurgeBefore(ctx, policy.OrgID, before, retentionBatchSize)
+		if err != nil {
+			return err
+		}
+		messages += m
+		attempts += a
+		metrics.RetentionMessagesPurgedTotal.Add(float64(m))
+		metrics.RetentionAttemptsPurgedTotal.Add(float64(a))
+
+		if m < retentionBatchSize {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retentionBatchPause):
+		}
+	}
+}
+
+// purgePayloads deletes the blobs that purged messages left behind. A key is
+// forgotten only after its blob is gone, so a failed delete is retried on the
+// next run. Keys a new message claimed in the meantime are left alone.
+func (p *RetentionPurger) purgePayloads(ctx context.Context) error {
+	var purged int64
+	defer func() {
+		if purged > 0 {
+			slog.Info("retention_payloads_purged", "payloads", purged)
+		}
+	}()
+
+	for {
+		refs, err := p.messageRepo.FindOrphanedPayloads(ctx, time.Now().Add(-orphanGracePeriod), retentionBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, ref := range refs {
+			deleted, err := p.messageRepo.PurgeOrphanedPayload(ctx, ref, func(ctx context.Context) error {
+				if err := p.blobs.Delete(ctx, ref); err != nil && !errors.Is(err, blobstore.ErrNotFound) {
+					return err
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if deleted {
+				purged++
+				metrics.RetentionPayloadsPurgedTotal.Inc()
+			}
+		}
+
+		if len(refs) < retentionBatchSize {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retentionBatchPause):
+		}
+	}
+}
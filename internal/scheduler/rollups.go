@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/repository"
+)
+
+const (
+	rollupInterval = time.Minute
+	// rollupLookback is how many closed hours are recomputed along with the
+	// current one, for attempts whose message changed state after the hour
+	// ended.
+	rollupLookback = time.Hour
+	// rollupCatchUpStep bounds each refresh while catching up on the hours
+	// missed while the scheduler was down.
+	rollupCatchUpStep = 24 * time.Hour
+)
+
+// RollupRefresher keeps the hourly delivery rollups behind the dashboard
+// time series current. It refreshes from where the last refresh ended, so
+// hours missed while the scheduler was down are caught up.
+type RollupRefresher struct {
+	analyticsRepo repository.AnalyticsRepository
+}
+
+func NewRollupRefresher(analyticsRepo repository.AnalyticsRepository) *RollupRefresher {
+	return &RollupRefresher{
+		analyticsRepo: analyticsRepo,
+	}
+}
+
+func (r *RollupRefresher) Start(ctx context.Context) {
+	ticker := time.NewTicker(rollupInterval)
+	defer ticker.Stop()
+
+	for {
+		r.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *RollupRefresher) refresh(ctx context.Context) {
+	progress, err := r.analyticsRepo.FindRollupProgress(ctx)
+	if err != nil {
+		slog.Error("rollup_progress_lookup_failed", "error", err)
+		return
+	}
+
+	hour := time.Now().UTC().Truncate(time.Hour)
+	from := hour
+	if progress.Before(from) {
+		from = progress.UTC().Truncate(time.Hour)
+	}
+	from = from.Add(-rollupLookback)
+	end := hour.Add(time.Hour)
+
+	for from.Before(end) {
+		to := from.Add(rollupCatchUpStep)
+		if to.After(end) {
+			to = end
+		}
+
+		start := time.Now()
+		rows, err := r.analyticsRepo.RefreshRollups(ctx, from, to)
+		if err != nil {
+			slog.Error("rollup_refresh_failed", "from", from, "to", to, "error", err)
+			return
+		}
+		slog.Debug("rollup_refreshed", "from", from, "to", to, "rows", rows, "duration_ms", time.Since(start).Milliseconds())
+
+		if ctx.Err() != nil {
+			return
+		}
+		from = to
+	}
+}
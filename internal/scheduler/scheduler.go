@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/bilalabdelkadir/chis/internal/logstream"
 	"github.com/bilalabdelkadir/chis/internal/queue"
 	"github.com/bilalabdelkadir/chis/internal/repository"
 )
@@ -12,14 +13,16 @@ import (
 type Scheduler struct {
 	messageRepo repository.MessageRepository
 	queue       *queue.Queue
+	logStream   *logstream.Stream
 }
 
 func NewScheduler(messageRepo repository.MessageRepository,
-	queue *queue.Queue,
+	queue *queue.Queue, logStream *logstream.Stream,
 ) *Scheduler {
 	return &Scheduler{
 		messageRepo: messageRepo,
 		queue:       queue,
+		logStream:   logStream,
 	}
 }
 
@@ -46,10 +49,11 @@ func (s *Scheduler) processRetries(ctx context.Context) error {
 
 	for _, msg := range messages {
 		slog.Info("scheduler_requeue", "message_id", msg.ID, "org_id", msg.OrgID, "attempt_count", msg.AttemptCount)
-		s.queue.Push(ctx, msg.ID.String())
+		s.queue.Push(ctx, msg.OrgID, msg.ID.String(), msg.Priority)
 
 		msg.Status = "pending"
 		s.messageRepo.Update(ctx, msg)
+		s.logStream.PublishStatus(ctx, msg, msg.Status)
 	}
 	return nil
 }
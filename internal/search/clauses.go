@@ -0,0 +1,277 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// statusClass matches values such as 5xx.
+var statusClass = regexp.MustCompile(`^[1-9]xx$`)
+
+func fieldClause(name string, field Field, op Op, value string, quoted bool) (clause, error) {
+	col := field.Column
+
+	switch field.Kind {
+	case Enum, Exact, UUID:
+		if op != Eq && op != Ne {
+			return nil, fmt.Errorf("%s only supports : and !=", name)
+		}
+		values := splitValues(value, quoted)
+		cast := ""
+		for i, v := range values {
+			switch field.Kind {
+			case Enum:
+				v = strings.ToLower(v)
+				if !slices.Contains(field.Values, v) {
+					return nil, fmt.Errorf("%s must be one of: %s", name, strings.Join(field.Values, ", "))
+				}
+			case UUID:
+				id, err := uuid.Parse(v)
+				if err != nil {
+					return nil, fmt.Errorf("%s must be an ID", name)
+				}
+				v = id.String()
+				cast = "::uuid[]"
+			}
+			values[i] = v
+		}
+		return func(arg func(any) string) string {
+			cond := fmt.Sprintf("%s = ANY(%s%s)", col, arg(values), cast)
+			if op == Ne {
+				return "NOT COALESCE(" + cond + ", false)"
+			}
+			return cond
+		}, nil
+
+	case Text:
+		if op != Eq && op != Ne {
+			return nil, fmt.Errorf("%s only supports : and !=", name)
+		}
+		return textClause(col, op, value), nil
+
+	case Number, Duration:
+		parse := parseNumber
+		if field.Kind == Duration {
+			parse = parseDuration
+		}
+		if op != Eq && op != Ne {
+			n, err := parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			return func(arg func(any) string) string {
+				return fmt.Sprintf("%s %s %s", col, op, arg(n))
+			}, nil
+		}
+
+		type span struct{ lo, hi int64 }
+		var spans []span
+		for _, v := range splitValues(value, quoted) {
+			if field.Kind == Number && statusClass.MatchString(v) {
+				lo := int64(v[0]-'0') * 100
+				spans = append(spans, span{lo, lo + 99})
+				continue
+			}
+			if lo, hi, ok := strings.Cut(v, ".."); ok {
+				l, err := parse(lo)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+				h, err := parse(hi)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+				spans = append(spans, span{l, h})
+				continue
+			}
+			n, err := parse(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			spans = append(spans, span{n, n})
+		}
+		return func(arg func(any) string) string {
+			conds := make([]string, len(spans))
+			for i, s := range spans {
+				if s.lo == s.hi {
+					conds[i] = fmt.Sprintf("%s = %s", col, arg(s.lo))
+				} else {
+					conds[i] = fmt.Sprintf("%s BETWEEN %s AND %s", col, arg(s.lo), arg(s.hi))
+				}
+			}
+			cond := "(" + strings.Join(conds, " OR ") + ")"
+			if op == Ne {
+				return "NOT COALESCE(" + cond + ", false)"
+			}
+			return cond
+		}, nil
+
+	case Time:
+		t, day, err := parseTime(value, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		// A date on its own means the whole day.
+		if day && (op == Eq || op == Ne) {
+			return func(arg func(any) string) string {
+				cond := fmt.Sprintf("(%s >= %s AND %s < %s)", col, arg(t), col, arg(t.Add(24*time.Hour)))
+				if op == Ne {
+					return "NOT COALESCE(" + cond + ", false)"
+				}
+				return cond
+			}, nil
+		}
+		// After a date means after that whole day.
+		if day && op == Gt {
+			t, op = t.Add(24*time.Hour), Ge
+		}
+		if day && op == Le {
+			t, op = t.Add(24*time.Hour), Lt
+		}
+		sqlOp := string(op)
+		if op == Ne {
+			sqlOp = "IS DISTINCT FROM"
+		}
+		return func(arg func(any) string) string {
+			return fmt.Sprintf("%s %s %s", col, sqlOp, arg(t))
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%s cannot be searched", name)
+}
+
+// textClause matches a case-insensitive substring.
+func textClause(col string, op Op, value string) clause {
+	pattern := "%" + escapeLike(value) + "%"
+	return func(arg func(any) string) string {
+		if op == Ne {
+			return fmt.Sprintf("%s NOT ILIKE %s", col, arg(pattern))
+		}
+		return fmt.Sprintf("%s ILIKE %s", col, arg(pattern))
+	}
+}
+
+// payloadClause matches a value at a dotted path with JSONB containment,
+// which GIN indexes serve, or compares a number with a JSON path query.
+// The bare payload field takes a JSON object the payload must contain.
+func payloadClause(col, name string, op Op, value string, quoted bool) (clause, error) {
+	var doc []byte
+	if name == "payload" {
+		if op != Eq && op != Ne {
+			return nil, fmt.Errorf("payload only supports : and != with a JSON object")
+		}
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(value), &obj); err != nil {
+			return nil, fmt.Errorf(`payload takes a JSON object, as in payload:'{"type":"order.created"}'`)
+		}
+		doc = []byte(value)
+	} else {
+		keys := strings.Split(strings.TrimPrefix(name, "payload."), ".")
+		if slices.Contains(keys, "") {
+			return nil, fmt.Errorf("invalid payload path %q", name)
+		}
+
+		if op != Eq && op != Ne {
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil || quoted {
+				return nil, fmt.Errorf("%s %s needs a number", name, op)
+			}
+			path := "$"
+			for _, k := range keys {
+				quotedKey, _ := json.Marshal(k)
+				path += "." + string(quotedKey)
+			}
+			path += fmt.Sprintf(" %s %s", op, strconv.FormatFloat(n, 'f', -1, 64))
+			return func(arg func(any) string) string {
+				return fmt.Sprintf("%s @@ %s::jsonpath", col, arg(path))
+			}, nil
+		}
+
+		doc = jsonValue(value, quoted)
+		for i := len(keys) - 1; i >= 0; i-- {
+			doc, _ = json.Marshal(map[string]json.RawMessage{keys[i]: doc})
+		}
+	}
+
+	contains := string(doc)
+	return func(arg func(any) string) string {
+		if op == Ne {
+			return fmt.Sprintf("NOT COALESCE(%s @> %s::jsonb, false)", col, arg(contains))
+		}
+		return fmt.Sprintf("%s @> %s::jsonb", col, arg(contains))
+	}, nil
+}
+
+// jsonValue reads an unquoted value as a JSON number, boolean or null when
+// it is one, and anything else as a string.
+func jsonValue(value string, quoted bool) json.RawMessage {
+	if !quoted {
+		switch value {
+		case "true", "false", "null":
+			return json.RawMessage(value)
+		}
+		if _, err := strconv.ParseFloat(value, 64); err == nil && json.Valid([]byte(value)) {
+			return json.RawMessage(value)
+		}
+	}
+	b, _ := json.Marshal(value)
+	return b
+}
+
+func splitValues(value string, quoted bool) []string {
+	if quoted {
+		return []string{value}
+	}
+	return strings.Split(value, ",")
+}
+
+func parseNumber(s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	return n, nil
+}
+
+// parseDuration reads milliseconds or a Go duration.
+func parseDuration(s string) (int64, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration such as 250ms or 2s", s)
+	}
+	return d.Milliseconds(), nil
+}
+
+// parseTime reads a timestamp, a date or a negative duration from now, and
+// reports whether it was a date.
+func parseTime(s string, now time.Time) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, true, nil
+	}
+	if strings.HasPrefix(s, "-") {
+		if d, err := time.ParseDuration(s); err == nil {
+			return now.Add(d), false, nil
+		}
+	}
+	if s == "now" {
+		return now, false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("%q is not a time such as 2026-01-02, 2026-01-02T15:04:05Z or -24h", s)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
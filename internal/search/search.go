@@ -0,0 +1,304 @@
+// Package search parses the query language of the webhook log search, for
+// example
+//
+//	status:failed,retry code:5xx duration>2s created>=-24h data.customer_id = "cus_123"
+//
+// Terms are ANDed. A term is a field, an operator (:, =, !=, >, >=, <, <=)
+// and a value, quoted if it contains spaces. Words without a field match
+// the schema's free text column. Dotted names that are not fields are paths
+// into the JSON payload.
+//
+// Field names are looked up in a Schema and values are passed as query
+// arguments, so nothing the user types ends up in the SQL text.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	maxQueryLength = 1000
+	maxTerms       = 20
+)
+
+type Kind int
+
+const (
+	// Enum values must be one of the field's Values.
+	Enum Kind = iota
+	// Exact values match the column exactly.
+	Exact
+	// Text values match a case-insensitive substring.
+	Text
+	// Number values are integers, classes such as 5xx or ranges such as
+	// 400..499.
+	Number
+	// Duration values are milliseconds or Go durations such as 1.5s, and
+	// compare against a column of milliseconds.
+	Duration
+	// Time values are RFC 3339 timestamps, dates, or durations back from
+	// now such as -24h.
+	Time
+	UUID
+)
+
+type Field struct {
+	Kind Kind
+	// Column is the SQL expression the field compares.
+	Column string
+	Values []string
+}
+
+// Schema is what a query may filter on.
+type Schema struct {
+	// Fields are keyed by lower case name; aliases are extra entries.
+	Fields map[string]Field
+	// FreeText is the column bare words are matched against as Text.
+	FreeText string
+	// Payload is the JSONB column payload paths query, empty to disallow
+	// them.
+	Payload string
+}
+
+type Op string
+
+const (
+	Eq Op = "="
+	Ne Op = "!="
+	Gt Op = ">"
+	Ge Op = ">="
+	Lt Op = "<"
+	Le Op = "<="
+)
+
+// ops is ordered so two-character operators are tried first.
+var ops = []struct {
+	token string
+	op    Op
+}{
+	{"!=", Ne}, {">=", Ge}, {"<=", Le}, {":", Eq}, {"=", Eq}, {">", Gt}, {"<", Lt},
+}
+
+// clause renders one condition, calling arg to add each query argument and
+// get its placeholder.
+type clause func(arg func(any) string) string
+
+// Filter is a parsed query. The zero value matches everything.
+type Filter struct {
+	clauses []clause
+	columns []string
+}
+
+// Empty reports whether the filter has no conditions.
+func (f *Filter) Empty() bool {
+	return f == nil || len(f.clauses) == 0
+}
+
+// References reports whether any condition uses a column containing s,
+// such as a table alias, so callers can leave out joins nothing needs.
+func (f *Filter) References(s string) bool {
+	if f == nil {
+		return false
+	}
+	for _, c := range f.columns {
+		if strings.Contains(c, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// SQL returns the conditions ANDed together, or "" when there are none,
+// with their arguments appended to args and numbered after them.
+func (f *Filter) SQL(args []any) (string, []any) {
+	if f.Empty() {
+		return "", args
+	}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	conds := make([]string, len(f.clauses))
+	for i, c := range f.clauses {
+		conds[i] = c(arg)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// Parse reads q against the schema. Its errors are meant for the user.
+func Parse(q string, schema Schema) (*Filter, error) {
+	if len(q) > maxQueryLength {
+		return nil, fmt.Errorf("query must be at most %d characters", maxQueryLength)
+	}
+
+	p := &parser{s: q, schema: schema, filter: &Filter{}}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		if err := p.term(); err != nil {
+			return nil, err
+		}
+		if len(p.filter.clauses) > maxTerms {
+			return nil, fmt.Errorf("query must have at most %d terms", maxTerms)
+		}
+	}
+	return p.filter, nil
+}
+
+type parser struct {
+	s      string
+	pos    int
+	schema Schema
+	filter *Filter
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *parser) skipSpace() {
+	for !p.eof() && isSpace(p.s[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *parser) term() error {
+	if c := p.s[p.pos]; c == '"' || c == '\'' {
+		word, err := p.quoted()
+		if err != nil {
+			return err
+		}
+		return p.freeText(word)
+	}
+
+	start := p.pos
+	name := p.ident()
+	p.skipSpace()
+	op, ok := p.op()
+	if name == "" || !ok {
+		p.pos = start
+		word := p.word()
+		if strings.EqualFold(word, "AND") {
+			return nil
+		}
+		return p.freeText(word)
+	}
+
+	p.skipSpace()
+	if p.eof() {
+		return fmt.Errorf("missing value for %s", name)
+	}
+	quoted := false
+	var value string
+	if c := p.s[p.pos]; c == '"' || c == '\'' {
+		var err error
+		if value, err = p.quoted(); err != nil {
+			return err
+		}
+		quoted = true
+	} else {
+		value = p.word()
+	}
+
+	return p.field(name, op, value, quoted)
+}
+
+func (p *parser) ident() string {
+	start := p.pos
+	for !p.eof() {
+		c := p.s[p.pos]
+		if c != '_' && c != '.' && c != '-' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && !('0' <= c && c <= '9') {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *parser) op() (Op, bool) {
+	for _, o := range ops {
+		if strings.HasPrefix(p.s[p.pos:], o.token) {
+			p.pos += len(o.token)
+			return o.op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) word() string {
+	start := p.pos
+	for !p.eof() && !isSpace(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// quoted reads a string in single or double quotes, where a backslash
+// escapes the next character.
+func (p *parser) quoted() (string, error) {
+	quote := p.s[p.pos]
+	start := p.pos
+	p.pos++
+
+	var b strings.Builder
+	for !p.eof() {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == '\\' && !p.eof():
+			b.WriteByte(p.s[p.pos])
+			p.pos++
+		case c == quote:
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated quote at position %d", start+1)
+}
+
+func (p *parser) add(column string, c clause) {
+	p.filter.columns = append(p.filter.columns, column)
+	p.filter.clauses = append(p.filter.clauses, c)
+}
+
+func (p *parser) freeText(word string) error {
+	if p.schema.FreeText == "" {
+		return errors.New("search terms need a field, as in field:value")
+	}
+	if word == "" {
+		return nil
+	}
+	p.add(p.schema.FreeText, textClause(p.schema.FreeText, Eq, word))
+	return nil
+}
+
+func (p *parser) field(name string, op Op, value string, quoted bool) error {
+	field, ok := p.schema.Fields[strings.ToLower(name)]
+	if !ok {
+		if p.schema.Payload != "" && (name == "payload" || strings.Contains(name, ".")) {
+			c, err := payloadClause(p.schema.Payload, name, op, value, quoted)
+			if err != nil {
+				return err
+			}
+			p.add(p.schema.Payload, c)
+			return nil
+		}
+		return fmt.Errorf("unknown field %q; quote free text that contains a colon", name)
+	}
+
+	c, err := fieldClause(name, field, op, value, quoted)
+	if err != nil {
+		return err
+	}
+	p.add(field.Column, c)
+	return nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
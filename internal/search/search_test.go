@@ -0,0 +1,247 @@
+package search_test
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/search"
+)
+
+var schema = search.Schema{
+	Fields: map[string]search.Field{
+		"status":   {Kind: search.Enum, Column: "m.status", Values: []string{"pending", "success", "failed"}},
+		"event":    {Kind: search.Exact, Column: "m.event_type"},
+		"url":      {Kind: search.Text, Column: "m.url"},
+		"code":     {Kind: search.Number, Column: "da.status_code"},
+		"duration": {Kind: search.Duration, Column: "da.duration_ms"},
+		"created":  {Kind: search.Time, Column: "m.created_at"},
+		"endpoint": {Kind: search.UUID, Column: "m.endpoint_id"},
+	},
+	FreeText: "m.url",
+	Payload:  "m.payload",
+}
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseSQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantSQL  string
+		wantArgs []any
+	}{
+		{"empty", "", "", nil},
+		{"blank", "   \t ", "", nil},
+
+		{"enum", "status:failed", "m.status = ANY($2)", []any{[]string{"failed"}}},
+		{"enum list", "status:failed,pending", "m.status = ANY($2)", []any{[]string{"failed", "pending"}}},
+		{"enum case", "STATUS:Failed", "m.status = ANY($2)", []any{[]string{"failed"}}},
+		{"enum equals", "status = failed", "m.status = ANY($2)", []any{[]string{"failed"}}},
+		{"enum not", "status!=failed", "NOT COALESCE(m.status = ANY($2), false)", []any{[]string{"failed"}}},
+		{"exact quoted keeps commas", `event:"a,b"`, "m.event_type = ANY($2)", []any{[]string{"a,b"}}},
+		{"uuid", "endpoint:6F9619FF-8B86-D011-B42D-00CF4FC964FF", "m.endpoint_id = ANY($2::uuid[])",
+			[]any{[]string{"6f9619ff-8b86-d011-b42d-00cf4fc964ff"}}},
+
+		{"number", "code:404", "(da.status_code = $2)", []any{int64(404)}},
+		{"class", "code:5xx", "(da.status_code BETWEEN $2 AND $3)", []any{int64(500), int64(599)}},
+		{"range", "code:400..499", "(da.status_code BETWEEN $2 AND $3)", []any{int64(400), int64(499)}},
+		{"number list", "code:200,5xx", "(da.status_code = $2 OR da.status_code BETWEEN $3 AND $4)",
+			[]any{int64(200), int64(500), int64(599)}},
+		{"number not", "code!=2xx", "NOT COALESCE((da.status_code BETWEEN $2 AND $3), false)", []any{int64(200), int64(299)}},
+		{"number compare", "code>=500", "da.status_code >= $2", []any{int64(500)}},
+		{"duration go", "duration>1.5s", "da.duration_ms > $2", []any{int64(1500)}},
+		{"duration ms", "duration<250", "da.duration_ms < $2", []any{int64(250)}},
+		{"duration range", "duration:1s..2s", "(da.duration_ms BETWEEN $2 AND $3)", []any{int64(1000), int64(2000)}},
+
+		{"time", "created>=2026-01-02T15:04:05Z", "m.created_at >= $2",
+			[]any{time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)}},
+		{"date is the whole day", "created:2026-01-02", "(m.created_at >= $2 AND m.created_at < $3)",
+			[]any{date("2026-01-02"), date("2026-01-03")}},
+		{"after a date", "created>2026-01-02", "m.created_at >= $2", []any{date("2026-01-03")}},
+		{"up to a date", "created<=2026-01-02", "m.created_at < $2", []any{date("2026-01-03")}},
+		{"time not", "created!=2026-01-02T00:00:00Z", "m.created_at IS DISTINCT FROM $2", []any{date("2026-01-02")}},
+
+		{"text", "url:example", "m.url ILIKE $2", []any{"%example%"}},
+		{"text not", "url!=example", "m.url NOT ILIKE $2", []any{"%example%"}},
+		{"text escapes like", `url:"50%_off\\"`, "m.url ILIKE $2", []any{`%50\%\_off\\%`}},
+		{"double quotes", `url:"a b"`, "m.url ILIKE $2", []any{"%a b%"}},
+		{"single quotes", `url:'a "b"'`, "m.url ILIKE $2", []any{`%a "b"%`}},
+		{"escaped quote", `url:"say \"hi\""`, "m.url ILIKE $2", []any{`%say "hi"%`}},
+		{"free text", "hooks.example.com", "m.url ILIKE $2", []any{"%hooks.example.com%"}},
+		{"quoted free text", `"a: b"`, "m.url ILIKE $2", []any{"%a: b%"}},
+		{"empty quoted free text", `""`, "", nil},
+
+		{"and", "status:failed AND code:5xx", "m.status = ANY($2) AND (da.status_code BETWEEN $3 AND $4)",
+			[]any{[]string{"failed"}, int64(500), int64(599)}},
+		{"implicit and", "status:failed code:5xx", "m.status = ANY($2) AND (da.status_code BETWEEN $3 AND $4)",
+			[]any{[]string{"failed"}, int64(500), int64(599)}},
+		{"lower case and", "url:a and url:b", "m.url ILIKE $2 AND m.url ILIKE $3", []any{"%a%", "%b%"}},
+
+		{"payload string", `data.customer_id = "cus_123"`, "m.payload @> $2::jsonb",
+			[]any{`{"data":{"customer_id":"cus_123"}}`}},
+		{"payload number", "data.amount:42", "m.payload @> $2::jsonb", []any{`{"data":{"amount":42}}`}},
+		{"payload quoted number", `data.amount:"42"`, "m.payload @> $2::jsonb", []any{`{"data":{"amount":"42"}}`}},
+		{"payload bool", "data.live:true", "m.payload @> $2::jsonb", []any{`{"data":{"live":true}}`}},
+		{"payload null", "data.deleted:null", "m.payload @> $2::jsonb", []any{`{"data":{"deleted":null}}`}},
+		{"payload top level", "payload.type:invoice.paid", "m.payload @> $2::jsonb", []any{`{"type":"invoice.paid"}`}},
+		{"payload not", "data.live!=true", "NOT COALESCE(m.payload @> $2::jsonb, false)", []any{`{"data":{"live":true}}`}},
+		{"payload compare", "data.amount>=10.5", "m.payload @@ $2::jsonpath", []any{`$."data"."amount" >= 10.5`}},
+		{"payload object", `payload:'{"type":"order.created"}'`, "m.payload @> $2::jsonb",
+			[]any{`{"type":"order.created"}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := search.Parse(tt.query, schema)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.query, err)
+			}
+			// Conditions are numbered after the caller's arguments.
+			sql, args := filter.SQL([]any{"org"})
+			if sql != tt.wantSQL {
+				t.Errorf("SQL = %q, want %q", sql, tt.wantSQL)
+			}
+			if want := append([]any{"org"}, tt.wantArgs...); !reflect.DeepEqual(args, want) {
+				t.Errorf("args = %#v, want %#v", args, want)
+			}
+			if filter.Empty() != (tt.wantSQL == "") {
+				t.Errorf("Empty() = %t", filter.Empty())
+			}
+		})
+	}
+}
+
+func TestParseRelativeTime(t *testing.T) {
+	filter, err := search.Parse("created>=-24h", schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql, args := filter.SQL(nil)
+	if sql != "m.created_at >= $1" {
+		t.Fatalf("SQL = %q", sql)
+	}
+	got := args[0].(time.Time)
+	if d := time.Since(got) - 24*time.Hour; d < 0 || d > time.Minute {
+		t.Errorf("-24h resolved to %s", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		schema  search.Schema
+		wantErr string
+	}{
+		{"unknown field", "nope:1", schema, `unknown field "nope"`},
+		{"enum value", "status:bogus", schema, "status must be one of: pending, success, failed"},
+		{"enum operator", "status>failed", schema, "status only supports : and !="},
+		{"text operator", "url>a", schema, "url only supports : and !="},
+		{"number", "code:abc", schema, `code: "abc" is not a number`},
+		{"class compare", "code>5xx", schema, `code: "5xx" is not a number`},
+		{"range end", "code:400..x", schema, `code: "x" is not a number`},
+		{"duration", "duration>soon", schema, `duration: "soon" is not a duration`},
+		{"time", "created>yesterday", schema, `created: "yesterday" is not a time`},
+		{"future offset", "created>24h", schema, `created: "24h" is not a time`},
+		{"uuid", "endpoint:abc", schema, "endpoint must be an ID"},
+		{"missing value", "code:", schema, "missing value for code"},
+		{"missing value after space", "code: ", schema, "missing value for code"},
+		{"unterminated", `url:"abc`, schema, "unterminated quote at position 5"},
+		{"unterminated free text", `'abc`, schema, "unterminated quote at position 1"},
+		{"payload compare string", `data.amount>"5"`, schema, "data.amount > needs a number"},
+		{"payload compare word", "data.amount>five", schema, "data.amount > needs a number"},
+		{"payload path", "data..id:1", schema, `invalid payload path "data..id"`},
+		{"payload not object", "payload:1", schema, "payload takes a JSON object"},
+		{"payload operator", `payload>'{}'`, schema, "payload only supports : and != with a JSON object"},
+		{"payload disabled", "data.id:1", search.Schema{Fields: schema.Fields}, `unknown field "data.id"`},
+		{"free text disabled", "hooks", search.Schema{Fields: schema.Fields}, "search terms need a field"},
+		{"too long", "url:" + strings.Repeat("a", 1000), schema, "query must be at most 1000 characters"},
+		{"too many terms", strings.Repeat("url:a ", 21), schema, "query must have at most 20 terms"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := search.Parse(tt.query, tt.schema)
+			if err == nil {
+				t.Fatalf("Parse(%q) succeeded, want error %q", tt.query, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse(%q) error = %q, want %q", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// sqlText is everything the SQL may contain: names, placeholders, casts,
+// keywords and operators. Names must also be columns of the schema.
+var (
+	sqlText = regexp.MustCompile(`^(?:[a-z_.]+|\$\d+|::[a-z\[\]]+|[A-Z]+|[()=<>!@,]+|\s+)*$`)
+	sqlName = regexp.MustCompile(`(?:^|[^:a-z_.])([a-z_.]+)`)
+)
+
+// checkParameterized fails if anything but the schema's own SQL made it into
+// the query text, or the placeholders don't match the arguments.
+func checkParameterized(t *testing.T, query string) {
+	t.Helper()
+	filter, err := search.Parse(query, schema)
+	if err != nil {
+		return
+	}
+	sql, args := filter.SQL(nil)
+	if !sqlText.MatchString(sql) {
+		t.Fatalf("Parse(%q) put unexpected text in the SQL: %q", query, sql)
+	}
+	columns := map[string]bool{schema.FreeText: true, schema.Payload: true, "false": true}
+	for _, f := range schema.Fields {
+		columns[f.Column] = true
+	}
+	for _, m := range sqlName.FindAllStringSubmatch(sql, -1) {
+		if !columns[m[1]] {
+			t.Fatalf("Parse(%q) put %q in the SQL: %q", query, m[1], sql)
+		}
+	}
+	if got := strings.Count(sql, "$"); got != len(args) {
+		t.Fatalf("Parse(%q) SQL %q has %d placeholders for %d args", query, sql, got, len(args))
+	}
+}
+
+func TestUserInputOnlyInArgs(t *testing.T) {
+	hostile := []string{
+		`url:"'; DROP TABLE messages; --"`,
+		`'; DELETE FROM messages --`,
+		`event:"x') OR ('1'='1"`,
+		`status!=failed url:"%' OR 1=1 --"`,
+		`data.id:"1' OR '1'='1"`,
+		`data.a-b_c.d:"$1"`,
+		`payload:'{"a": "'; --"}'`,
+		`data.amount>1e3`,
+		`created:"2026-01-02"`,
+		`code:1..2,3`,
+	}
+	for _, q := range hostile {
+		checkParameterized(t, q)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, q := range []string{
+		"status:failed,pending code:5xx duration>2s created>=-24h",
+		`data.customer_id = "cus_123" AND url!='a\'b'`,
+		`payload:'{"type":"order.created"}' data.amount<=10`,
+		`"quoted: text" endpoint:6f9619ff-8b86-d011-b42d-00cf4fc964ff`,
+	} {
+		f.Add(q)
+	}
+	f.Fuzz(func(t *testing.T, q string) {
+		checkParameterized(t, q)
+	})
+}
@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/events"
+	"github.com/bilalabdelkadir/chis/internal/metrics"
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+)
+
+// recoveredAfterFailures is how long a failure streak must be before the
+// success that ends it is announced, so one-off errors stay quiet.
+const recoveredAfterFailures = 5
+
+// recordHealth updates the endpoint's failure streak and disables it once
+// it has failed continuously for longer than disableAfter.
+func (w *Worker) recordHealth(ctx context.Context, endpoint *model.Endpoint, success bool) {
+	health, err := w.endpointRepo.RecordDelivery(ctx, endpoint.ID, success)
+	if err != nil {
+		slog.Error("endpoint_health_update_failed", "endpoint_id", endpoint.ID, "error", err)
+		return
+	}
+	updated := health.Endpoint
+	if success {
+		if health.PreviousFailures >= recoveredAfterFailures && !endpoint.Operational {
+			w.events.Emit(ctx, endpoint.OrgID, events.EndpointRecovered, events.EndpointRecoveredData{
+				EndpointID:   endpoint.ID,
+				URL:          endpoint.URL,
+				FailingSince: health.PreviousFailingSince,
+			})
+		}
+		return
+	}
+	if w.disableAfter <= 0 || updated.FailingSince == nil {
+		return
+	}
+	if time.Since(*updated.FailingSince) < w.disableAfter {
+		return
+	}
+
+	reason := fmt.Sprintf("failed continuously since %s (%d consecutive failures)",
+		updated.FailingSince.UTC().Format(time.RFC3339), updated.ConsecutiveFailures)
+	if err := w.endpointRepo.Disable(ctx, endpoint.ID, reason); err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			slog.Error("endpoint_disable_failed", "endpoint_id", endpoint.ID, "error", err)
+		}
+		return
+	}
+
+	held, err := w.messageRepo.HoldByEndpoint(ctx, endpoint.ID)
+	if err != nil {
+		slog.Error("endpoint_hold_messages_failed", "endpoint_id", endpoint.ID, "error", err)
+	}
+	for _, msg := range held {
+		w.logStream.PublishStatus(ctx, msg, msg.Status)
+	}
+	slog.Warn("endpoint_disabled", "endpoint_id", endpoint.ID, "org_id", endpoint.OrgID,
+		"consecutive_failures", updated.ConsecutiveFailures, "held_messages", len(held))
+	metrics.EndpointsDisabledTotal.Inc()
+
+	notification := &model.Notification{
+		OrgID:      endpoint.OrgID,
+		Kind:       model.NotificationEndpointDisabled,
+		EndpointID: &endpoint.ID,
+		Summary: fmt.Sprintf("%s was disabled: it %s. %d messages are held until it is enabled again.",
+			endpoint.URL, reason, len(held)),
+	}
+	if err := w.notificationRepo.Create(ctx, notification); err != nil {
+		slog.Error("endpoint_disabled_notification_failed", "endpoint_id", endpoint.ID, "error", err)
+	}
+
+	// A disabled operational endpoint has nowhere to hear about itself.
+	if !endpoint.Operational {
+		w.events.Emit(ctx, endpoint.OrgID, events.EndpointDisabled, events.EndpointDisabledData{
+			EndpointID:          endpoint.ID,
+			URL:                 endpoint.URL,
+			Reason:              reason,
+			FailingSince:        updated.FailingSince,
+			ConsecutiveFailures: updated.ConsecutiveFailures,
+		})
+	}
+}
+
+// emitExhausted tells the org a message ran out of attempts. Events about
+// system events, or about deliveries to operational endpoints, are never
+// emitted so a failing operational endpoint cannot feed itself.
+func (w *Worker) emitExhausted(ctx context.Context, msg *model.Message, endpoint *model.Endpoint, statusCode *int, errorMessage *string) {
+	if msg.EventType != nil || (endpoint != nil && endpoint.Operational) {
+		return
+	}
+	w.events.Emit(ctx, msg.OrgID, events.MessageAttemptExhausted, events.MessageAttemptExhaustedData{
+		MessageID:      msg.ID,
+		EndpointID:     msg.EndpointID,
+		URL:            msg.URL,
+		Attempts:       msg.AttemptCount + 1,
+		LastStatusCode: statusCode,
+		LastError:      errorMessage,
+	})
+}
+
+// hold parks a message for a disabled endpoint without attempting it.
+func (w *Worker) hold(ctx context.Context, msg *model.Message, endpoint *model.Endpoint) {
+	if _, err := w.messageRepo.UpdateStatus(ctx, msg.ID, "held"); err != nil {
+		slog.Error("webhook_hold_failed", "message_id", msg.ID, "endpoint_id", endpoint.ID, "error", err)
+		return
+	}
+	w.logStream.PublishStatus(ctx, msg, "held")
+	slog.Info("webhook_held", "message_id", msg.ID, "org_id", msg.OrgID, "endpoint_id", endpoint.ID)
+}
@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/events"
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/google/uuid"
+)
+
+func TestRecordHealth(t *testing.T) {
+	ago := func(d time.Duration) *time.Time {
+		at := time.Now().Add(-d)
+		return &at
+	}
+
+	tests := []struct {
+		name         string
+		success      bool
+		operational  bool
+		disableAfter time.Duration
+		// previousFailures is the streak before this attempt; failingSince
+		// is the endpoint's streak start after it.
+		previousFailures int
+		failingSince     *time.Time
+		disableErr       error
+
+		wantDisabled bool
+		wantEvents   []string
+	}{
+		{
+			name:         "failing past disable after",
+			disableAfter: time.Hour,
+			failingSince: ago(2 * time.Hour),
+			wantDisabled: true,
+			wantEvents:   []string{events.EndpointDisabled},
+		},
+		{
+			name:         "failing within disable after",
+			disableAfter: time.Hour,
+			failingSince: ago(10 * time.Minute),
+		},
+		{
+			name:         "auto-disable off",
+			failingSince: ago(48 * time.Hour),
+		},
+		{
+			name:         "operational endpoint disabled quietly",
+			operational:  true,
+			disableAfter: time.Hour,
+			failingSince: ago(2 * time.Hour),
+			wantDisabled: true,
+		},
+		{
+			name:         "already disabled elsewhere",
+			disableAfter: time.Hour,
+			failingSince: ago(2 * time.Hour),
+			disableErr:   repository.ErrNotFound,
+		},
+		{
+			name:             "recovers after a long streak",
+			success:          true,
+			previousFailures: recoveredAfterFailures,
+			wantEvents:       []string{events.EndpointRecovered},
+		},
+		{
+			name:             "short streak ends quietly",
+			success:          true,
+			previousFailures: recoveredAfterFailures - 1,
+		},
+		{
+			name:    "success without a streak",
+			success: true,
+		},
+		{
+			name:             "operational endpoint recovers quietly",
+			success:          true,
+			operational:      true,
+			previousFailures: recoveredAfterFailures,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWorker(t, tt.disableAfter)
+			endpoint := &model.Endpoint{ID: uuid.New(), OrgID: uuid.New(), URL: "https://example.com/hook", Operational: tt.operational}
+			updated := *endpoint
+			updated.FailingSince = tt.failingSince
+			updated.ConsecutiveFailures = 12
+			w.endpoints.health = &repository.DeliveryHealth{
+				Endpoint:             &updated,
+				PreviousFailures:     tt.previousFailures,
+				PreviousFailingSince: ago(time.Hour),
+			}
+			w.endpoints.disableErr = tt.disableErr
+			w.endpoints.operational = []*model.Endpoint{{ID: uuid.New(), URL: "https://ops.example.com"}}
+			w.messages.held = []*model.Message{{ID: uuid.New(), OrgID: endpoint.OrgID, Status: "held"}}
+
+			w.recordHealth(context.Background(), endpoint, tt.success)
+
+			if disabled := len(w.endpoints.disabled) == 1; disabled != tt.wantDisabled {
+				t.Errorf("disabled = %v, want %v", disabled, tt.wantDisabled)
+			}
+			if notified := len(w.notifications.created) == 1; notified != tt.wantDisabled {
+				t.Errorf("notified = %v, want %v", notified, tt.wantDisabled)
+			}
+			if got := w.messages.eventTypes(); !reflect.DeepEqual(got, tt.wantEvents) {
+				t.Errorf("events = %v, want %v", got, tt.wantEvents)
+			}
+		})
+	}
+}
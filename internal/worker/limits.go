@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"io"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bilalabdelkadir/chis/internal/config"
+	"github.com/bilalabdelkadir/chis/internal/model"
+)
+
+// Limits bounds how many deliveries run at once, how long each may take
+// and how much of the response is kept.
+type Limits struct {
+	// Concurrency is how many deliveries the worker sends at once.
+	Concurrency int
+
+	// Timeout applies to endpoints without their own; endpoint timeouts
+	// are clamped to [MinTimeout, MaxTimeout].
+	Timeout    time.Duration
+	MinTimeout time.Duration
+	MaxTimeout time.Duration
+
+	ConnectTimeout time.Duration
+	TLSTimeout     time.Duration
+	// ReadTimeout is how long to wait for response headers once the
+	// request has been written.
+	ReadTimeout time.Duration
+
+	MaxResponseBytes int
+
+	// MaxIdleConnsPerHost is how many keep-alive connections are pooled
+	// for each receiving host, and MaxIdleConns across all of them. Idle
+	// connections are dropped after IdleConnTimeout.
+	MaxIdleConnsPerHost int
+	MaxIdleConns        int
+	IdleConnTimeout     time.Duration
+	// DNSCacheTTL is how long resolved addresses are reused. Zero resolves
+	// on every new connection.
+	DNSCacheTTL time.Duration
+
+	// MaxRelayInFlight is how many relayed deliveries may wait on local
+	// servers at once. Zero means no limit.
+	MaxRelayInFlight int
+}
+
+func LimitsFromConfig(cfg *config.Config) Limits {
+	return Limits{
+		Concurrency:      cfg.DeliveryConcurrency,
+		Timeout:          cfg.DeliveryTimeout,
+		MinTimeout:       cfg.DeliveryTimeoutMin,
+		MaxTimeout:       cfg.DeliveryTimeoutMax,
+		ConnectTimeout:   cfg.DeliveryConnectTimeout,
+		TLSTimeout:       cfg.DeliveryTLSTimeout,
+		ReadTimeout:      cfg.DeliveryReadTimeout,
+		MaxResponseBytes: cfg.MaxResponseBytes,
+
+		MaxIdleConnsPerHost: cfg.DeliveryMaxIdleConnsPerHost,
+		MaxIdleConns:        cfg.DeliveryMaxIdleConns,
+		IdleConnTimeout:     cfg.DeliveryIdleConnTimeout,
+		DNSCacheTTL:         cfg.DeliveryDNSCacheTTL,
+
+		MaxRelayInFlight: cfg.RelayMaxInFlight,
+	}
+}
+
+// timeoutFor returns the delivery timeout for the endpoint, which may be nil.
+func (l Limits) timeoutFor(endpoint *model.Endpoint) time.Duration {
+	if endpoint == nil || endpoint.TimeoutMS == nil {
+		return l.Timeout
+	}
+	timeout := time.Duration(*endpoint.TimeoutMS) * time.Millisecond
+	return min(max(timeout, l.MinTimeout), l.MaxTimeout)
+}
+
+// readResponseBody reads at most limit bytes of body and reports whether
+// there was more.
+func readResponseBody(body io.Reader, limit int) ([]byte, bool, error) {
+	b, err := io.ReadAll(io.LimitReader(body, int64(limit)+1))
+	if len(b) > limit {
+		b = b[:limit]
+		// Don't leave half a character at the end.
+		for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+			if r, size := utf8.DecodeLastRune(b); r != utf8.RuneError || size > 1 {
+				break
+			}
+			b = b[:len(b)-1]
+		}
+		return b, true, err
+	}
+	return b, false, err
+}
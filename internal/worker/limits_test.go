@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+)
+
+func TestTimeoutFor(t *testing.T) {
+	limits := Limits{Timeout: 10 * time.Second, MinTimeout: time.Second, MaxTimeout: time.Minute}
+	ms := func(n int) *int { return &n }
+
+	tests := []struct {
+		name     string
+		endpoint *model.Endpoint
+		want     time.Duration
+	}{
+		{"no endpoint", nil, 10 * time.Second},
+		{"endpoint without timeout", &model.Endpoint{}, 10 * time.Second},
+		{"endpoint timeout", &model.Endpoint{TimeoutMS: ms(2500)}, 2500 * time.Millisecond},
+		{"below min", &model.Endpoint{TimeoutMS: ms(10)}, time.Second},
+		{"above max", &model.Endpoint{TimeoutMS: ms(5 * 60 * 1000)}, time.Minute},
+		{"at min", &model.Endpoint{TimeoutMS: ms(1000)}, time.Second},
+		{"at max", &model.Endpoint{TimeoutMS: ms(60 * 1000)}, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := limits.timeoutFor(tt.endpoint); got != tt.want {
+			t.Errorf("%s: timeoutFor = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadResponseBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		limit         int
+		want          string
+		wantTruncated bool
+	}{
+		{"empty", "", 8, "", false},
+		{"under limit", "hello", 8, "hello", false},
+		{"at limit", "12345678", 8, "12345678", false},
+		{"over limit", "123456789", 8, "12345678", true},
+		// "é" is two bytes; cutting after its first byte drops it whole.
+		{"splits two byte rune", "1234567é", 8, "1234567", true},
+		// "€" is three bytes and only one of them fits.
+		{"splits three byte rune", "123456€", 7, "123456", true},
+		{"rune ends at limit", "123456é9", 8, "123456é", true},
+		{"trailing invalid byte dropped", "1234567\xff9", 8, "1234567", true},
+	}
+	for _, tt := range tests {
+		got, truncated, err := readResponseBody(strings.NewReader(tt.body), tt.limit)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if string(got) != tt.want || truncated != tt.wantTruncated {
+			t.Errorf("%s: got %q, %v; want %q, %v", tt.name, got, truncated, tt.want, tt.wantTruncated)
+		}
+		if !utf8.Valid(got) && utf8.ValidString(tt.body) {
+			t.Errorf("%s: truncated body %q is not valid UTF-8", tt.name, got)
+		}
+	}
+}
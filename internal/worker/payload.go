@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/pkg/helper"
+)
+
+// openPayload returns a fresh reader over the message payload, streaming it
+// from the blob store when it was offloaded at ingestion.
+func (w *Worker) openPayload(ctx context.Context, msg *model.Message) (io.ReadCloser, error) {
+	if msg.PayloadRef == nil {
+		return io.NopCloser(bytes.NewReader(msg.Payload)), nil
+	}
+	if w.blobs == nil {
+		return nil, errors.New("payload is offloaded but no blob store is configured")
+	}
+
+	rc, err := w.blobs.Get(ctx, *msg.PayloadRef)
+	if err != nil {
+		return nil, fmt.Errorf("fetch payload %s: %w", *msg.PayloadRef, err)
+	}
+	return rc, nil
+}
+
+// signRequest signs req with the org's scheme. It fails rather than send
+// the webhook unsigned when the scheme's secret or key can't be used, so the
+// attempt is recorded as failed and retried.
+func (w *Worker) signRequest(ctx context.Context, req *http.Request, msg *model.Message, endpoint *model.Endpoint) error {
+	org, err := w.orgRepo.FindByID(ctx, msg.OrgID)
+	if err != nil {
+		slog.Error("webhook_signing_secret_lookup_failed", "message_id", msg.ID, "org_id", msg.OrgID, "error", err)
+		return fmt.Errorf("load signing secret: %w", err)
+	}
+
+	signers, err := w.signers(ctx, org, endpoint)
+	if err != nil {
+		return err
+	}
+
+	msgID := "msg_" + msg.ID.String()
+	timestamp := time.Now().Unix()
+	open := func() (io.ReadCloser, error) { return w.openPayload(ctx, msg) }
+
+	sig, err := helper.SignWebhook(msgID, timestamp, open, signers...)
+	if err != nil {
+		return err
+	}
+
+	headers := helper.HeadersForProfile(org.SignatureProfile)
+	req.Header.Set(headers.ID, msgID)
+	req.Header.Set(headers.Timestamp, fmt.Sprintf("%d", timestamp))
+	req.Header.Set(headers.Signature, sig)
+	return nil
+}
+
+// signers returns the signers for the org's signature scheme. "both" sends
+// HMAC and Ed25519 side by side so receivers can migrate between them.
+// HMAC uses the endpoint's own secret when it has one, else the org secret.
+// Every signer the scheme calls for must be available.
+func (w *Worker) signers(ctx context.Context, org *model.Organization, endpoint *model.Endpoint) ([]helper.WebhookSigner, error) {
+	var signers []helper.WebhookSigner
+
+	if org.SignatureScheme != helper.SignatureSchemeEd25519 {
+		secret, previous, previousExpiresAt := org.SigningSecret, org.PreviousSigningSecret, org.PreviousSecretExpiresAt
+		if endpoint != nil && endpoint.SigningSecret != nil {
+			secret, previous, previousExpiresAt = *endpoint.SigningSecret, endpoint.PreviousSigningSecret, endpoint.PreviousSecretExpiresAt
+		}
+
+		secrets := []string{secret}
+		// During a rotation grace period both secrets sign, space-separated
+		// as the Standard Webhooks spec allows.
+		if previous != nil && previousExpiresAt != nil && previousExpiresAt.After(time.Now()) {
+			secrets = append(secrets, *previous)
+		}
+		for _, s := range secrets {
+			if org.SignatureProfile != helper.SignatureProfileStandard {
+				signers = append(signers, helper.HMACSigner{Secret: s})
+				continue
+			}
+			signer, err := helper.NewStandardHMACSigner(s)
+			if err != nil {
+				slog.Error("webhook_signing_secret_invalid", "org_id", org.ID, "error", err)
+				return nil, fmt.Errorf("signing secret is not valid for the standard profile: %w", err)
+			}
+			signers = append(signers, signer)
+		}
+	}
+
+	if org.SignatureScheme == helper.SignatureSchemeEd25519 || org.SignatureScheme == helper.SignatureSchemeBoth {
+		key, err := w.signingKeyRepo.FindActiveByOrgID(ctx, org.ID)
+		if err != nil {
+			slog.Error("webhook_signing_key_lookup_failed", "org_id", org.ID, "error", err)
+			return nil, fmt.Errorf("load Ed25519 signing key: %w", err)
+		}
+		privateKey, err := helper.ParseEd25519PrivateKey(key.PrivateKey)
+		if err != nil {
+			slog.Error("webhook_signing_key_invalid", "org_id", org.ID, "key_id", key.ID, "error", err)
+			return nil, fmt.Errorf("signing key %s is invalid: %w", key.ID, err)
+		}
+		signers = append(signers, helper.Ed25519Signer{PrivateKey: privateKey})
+	}
+
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("signature scheme %q has no signer", org.SignatureScheme)
+	}
+	return signers, nil
+}
+
+// attachBody streams the payload into req, compressing it on the fly when
+// the endpoint asked for a Content-Encoding.
+func (w *Worker) attachBody(ctx context.Context, req *http.Request, msg *model.Message, encoding string) error {
+	open := func() (io.ReadCloser, error) {
+		payload, err := w.openPayload(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		body, err := helper.CompressStream(encoding, payload)
+		if err != nil {
+			payload.Close()
+			return nil, err
+		}
+		return body, nil
+	}
+
+	body, err := open()
+	if err != nil {
+		return err
+	}
+
+	req.Body = body
+	req.GetBody = open
+
+	if encoding != helper.EncodingIdentity {
+		req.Header.Set("Content-Encoding", encoding)
+	} else if msg.PayloadRef == nil {
+		req.ContentLength = int64(len(msg.Payload))
+	} else if msg.PayloadSize != nil {
+		req.ContentLength = int64(*msg.PayloadSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/pkg/helper"
+	"github.com/google/uuid"
+)
+
+func TestDeliverSigning(t *testing.T) {
+	tests := []struct {
+		name    string
+		scheme  string
+		profile string
+		secret  string
+		// wantSent is whether the receiver should see the request at all.
+		wantSent bool
+	}{
+		{"hmac", helper.SignatureSchemeHMAC, helper.SignatureProfileChis, "whsec_" + strings.Repeat("ab", 32), true},
+		{"standard hmac", helper.SignatureSchemeHMAC, helper.SignatureProfileStandard, "whsec_" + strings.Repeat("ab", 32), true},
+		{"standard with unusable secret", helper.SignatureSchemeHMAC, helper.SignatureProfileStandard, "whsec_not-hex", false},
+		{"ed25519 without a key", helper.SignatureSchemeEd25519, helper.SignatureProfileChis, "whsec_" + strings.Repeat("ab", 32), false},
+		{"both without a key", helper.SignatureSchemeBoth, helper.SignatureProfileChis, "whsec_" + strings.Repeat("ab", 32), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			var signature atomic.Value
+			headers := helper.HeadersForProfile(tt.profile)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				signature.Store(r.Header.Get(headers.Signature))
+				w.Write([]byte(`{"received":true}`))
+			}))
+			defer srv.Close()
+
+			w := newTestWorker(t, 0)
+			orgID := uuid.New()
+			w.orgs.org = &model.Organization{
+				ID:               orgID,
+				SigningSecret:    tt.secret,
+				SignatureScheme:  tt.scheme,
+				SignatureProfile: tt.profile,
+			}
+			endpoint := &model.Endpoint{ID: uuid.New(), OrgID: orgID, URL: srv.URL, ContentEncoding: helper.EncodingIdentity}
+			w.endpoints.endpoints[endpoint.ID] = endpoint
+			msg := &model.Message{
+				ID:         uuid.New(),
+				OrgID:      orgID,
+				EndpointID: &endpoint.ID,
+				Method:     http.MethodPost,
+				URL:        srv.URL,
+				Payload:    []byte(`{"hello":"world"}`),
+			}
+
+			w.deliver(context.Background(), msg)
+
+			if sent := hits.Load() > 0; sent != tt.wantSent {
+				t.Fatalf("sent = %v, want %v", sent, tt.wantSent)
+			}
+			if len(w.attempts.created) != 1 {
+				t.Fatalf("recorded %d attempts, want 1", len(w.attempts.created))
+			}
+			attempt := w.attempts.created[0]
+
+			if tt.wantSent {
+				if sig, _ := signature.Load().(string); sig == "" {
+					t.Errorf("request had no %s header", headers.Signature)
+				}
+				if w.messages.statuses[msg.ID] != "success" {
+					t.Errorf("status = %q, want success", w.messages.statuses[msg.ID])
+				}
+				if len(w.endpoints.recorded) != 1 {
+					t.Errorf("health recorded %d times, want 1", len(w.endpoints.recorded))
+				}
+				return
+			}
+
+			// Refused: the attempt fails with the signing error, the message
+			// is retried, and the endpoint isn't blamed for it.
+			if attempt.ErrorMessage == nil || attempt.StatusCode != nil {
+				t.Errorf("attempt = status %v, error %v; want only an error", attempt.StatusCode, attempt.ErrorMessage)
+			}
+			if len(w.messages.updated) != 1 || w.messages.updated[0].Status != "retry" {
+				t.Errorf("message updates = %+v, want one retry", w.messages.updated)
+			}
+			if len(w.endpoints.recorded) != 0 {
+				t.Errorf("health recorded %v, want nothing", w.endpoints.recorded)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/relay"
+)
+
+// relayTimeout bounds how long a relayed delivery waits for the developer's
+// local server to answer.
+const relayTimeout = 30 * time.Second
+
+// errRelayBusy fails a relayed delivery straight away when the worker is
+// already waiting on as many local servers as it allows; it is retried.
+var errRelayBusy = errors.New("too many relayed deliveries in flight, retrying later")
+
+// relayRequest sends a signed request through the endpoint's `chis listen`
+// session and turns the local server's answer into an http.Response so it
+// is recorded like any other attempt.
+func (w *Worker) relayRequest(ctx context.Context, endpoint *model.Endpoint, msg *model.Message, req *http.Request) (*http.Response, error) {
+	if w.relay == nil {
+		return nil, errors.New("relay endpoints are not supported by this worker")
+	}
+	if cap(w.relaySlots) > 0 {
+		select {
+		case w.relaySlots <- struct{}{}:
+			defer func() { <-w.relaySlots }()
+		default:
+			return nil, errRelayBusy
+		}
+	}
+
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	res, err := w.relay.Forward(ctx, endpoint.ID, &relay.Request{
+		MessageID: msg.ID.String(),
+		Method:    req.Method,
+		URL:       req.URL.String(),
+		Headers:   req.Header.Clone(),
+		Body:      body,
+	}, relayTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if res.Error != "" {
+		return nil, errors.New(res.Error)
+	}
+
+	return &http.Response{
+		StatusCode: res.StatusCode,
+		Header:     res.Headers,
+		Body:       io.NopCloser(bytes.NewReader(res.Body)),
+	}, nil
+}
@@ -0,0 +1,147 @@
+package worker
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/internal/metrics"
+)
+
+// maxDNSCacheEntries is how large the DNS cache may grow before expired
+// entries are swept.
+const maxDNSCacheEntries = 1024
+
+// NewHTTPClient returns the client deliveries are sent with. Its transport
+// is shared by every attempt, so connections to a busy host stay open
+// between deliveries instead of paying for a new TLS handshake each time.
+func NewHTTPClient(limits Limits) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   limits.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	if limits.DNSCacheTTL > 0 {
+		transport.DialContext = newDNSCache(limits.DNSCacheTTL).dialContext(dialer)
+	}
+	transport.ForceAttemptHTTP2 = true
+	// The global cap keeps thousands of receivers from each holding a full
+	// per-host pool open.
+	transport.MaxIdleConns = limits.MaxIdleConns
+	transport.MaxIdleConnsPerHost = limits.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = limits.IdleConnTimeout
+	transport.TLSHandshakeTimeout = limits.TLSTimeout
+	transport.ResponseHeaderTimeout = limits.ReadTimeout
+
+	// Each attempt also runs under the endpoint's own timeout; this is
+	// the backstop.
+	return &http.Client{
+		Transport: transport,
+		Timeout:   limits.MaxTimeout,
+	}
+}
+
+// connTrace counts connection reuse and TLS handshakes per attempt.
+var connTrace = &httptrace.ClientTrace{
+	GotConn: func(info httptrace.GotConnInfo) {
+		metrics.DeliveryConnectionsTotal.WithLabelValues(strconv.FormatBool(info.Reused)).Inc()
+	},
+	TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+		result := "ok"
+		if err != nil {
+			result = "error"
+		}
+		metrics.DeliveryTLSHandshakesTotal.WithLabelValues(result).Inc()
+	},
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// dnsCache remembers resolved addresses for ttl. Failed lookups are not
+// cached.
+type dnsCache struct {
+	resolver *net.Resolver
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+func newDNSCache(ttl time.Duration) *dnsCache {
+	return &dnsCache{
+		resolver: net.DefaultResolver,
+		ttl:      ttl,
+		entries:  make(map[string]dnsEntry),
+	}
+}
+
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		metrics.DeliveryDNSLookupsTotal.WithLabelValues("hit").Inc()
+		return entry.addrs, nil
+	}
+
+	metrics.DeliveryDNSLookupsTotal.WithLabelValues("miss").Inc()
+	addrs, err := c.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if len(c.entries) >= maxDNSCacheEntries {
+		for h, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, h)
+			}
+		}
+	}
+	c.entries[host] = dnsEntry{addrs: addrs, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+
+	return addrs, nil
+}
+
+// dialContext resolves through the cache and tries each address in turn.
+func (c *dnsCache) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		addrs, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		var firstErr error
+		for _, ip := range addrs {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		return nil, firstErr
+	}
+}
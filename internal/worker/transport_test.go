@@ -0,0 +1,118 @@
+package worker
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	// deliveriesInFlight matches a worker running with a busy queue.
+	deliveriesInFlight = 32
+	// deliveryGap stands in for the worker's queue and database work
+	// between deliveries, during which the connection sits idle.
+	deliveryGap = time.Millisecond
+)
+
+// BenchmarkDelivery compares the delivery transport with the stock
+// http.Client it replaced, against a local TLS receiver. conns/op is how
+// many new connections, and so TLS handshakes, each delivery cost.
+func BenchmarkDelivery(b *testing.B) {
+	for _, http2 := range []bool{false, true} {
+		var conns atomic.Int64
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			w.Write([]byte(`{"received":true}`))
+		}))
+		srv.EnableHTTP2 = http2
+		srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				conns.Add(1)
+			}
+		}
+		srv.StartTLS()
+		trust := srv.Client().Transport.(*http.Transport).TLSClientConfig
+
+		// What the worker used before: the default transport, which keeps
+		// two idle connections per host.
+		stock := &http.Client{Timeout: 10 * time.Second, Transport: withTLS(http.DefaultTransport, trust)}
+
+		// The defaults from .env.example.
+		tuned := NewHTTPClient(Limits{
+			Timeout:             10 * time.Second,
+			MinTimeout:          time.Second,
+			MaxTimeout:          60 * time.Second,
+			ConnectTimeout:      5 * time.Second,
+			TLSTimeout:          5 * time.Second,
+			ReadTimeout:         10 * time.Second,
+			MaxResponseBytes:    64 << 10,
+			MaxIdleConnsPerHost: 64,
+			MaxIdleConns:        2048,
+			IdleConnTimeout:     90 * time.Second,
+			DNSCacheTTL:         30 * time.Second,
+		})
+		tuned.Transport = withTLS(tuned.Transport, trust)
+
+		proto := "http1"
+		if http2 {
+			proto = "http2"
+		}
+		for _, c := range []struct {
+			name   string
+			client *http.Client
+		}{{"stock", stock}, {"tuned", tuned}} {
+			b.Run(proto+"/"+c.name, func(b *testing.B) {
+				conns.Store(0)
+				deliver(b, c.client, srv.URL)
+				b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+				c.client.CloseIdleConnections()
+			})
+		}
+		srv.Close()
+	}
+}
+
+func withTLS(rt http.RoundTripper, cfg *tls.Config) http.RoundTripper {
+	transport := rt.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg.Clone()
+	return transport
+}
+
+// deliver posts b.N payloads to url, deliveriesInFlight at a time.
+func deliver(b *testing.B, client *http.Client, url string) {
+	payload := bytes.Repeat([]byte("x"), 1024)
+	var (
+		wg   sync.WaitGroup
+		next atomic.Int64
+	)
+
+	b.ResetTimer()
+	for range deliveriesInFlight {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for next.Add(1) <= int64(b.N) {
+				resp, err := client.Post(url, "application/json", bytes.NewReader(payload))
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					b.Errorf("unexpected status %d", resp.StatusCode)
+					return
+				}
+				time.Sleep(deliveryGap)
+			}
+		}()
+	}
+	wg.Wait()
+}
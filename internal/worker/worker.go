@@ -1,17 +1,20 @@
 package worker
 
 import (
-	"bytes"
 	"context"
-	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
+	"net/http/httptrace"
+	"sync"
 	"time"
 
+	"github.com/bilalabdelkadir/chis/internal/blobstore"
+	"github.com/bilalabdelkadir/chis/internal/events"
+	"github.com/bilalabdelkadir/chis/internal/logstream"
 	"github.com/bilalabdelkadir/chis/internal/metrics"
 	"github.com/bilalabdelkadir/chis/internal/model"
 	"github.com/bilalabdelkadir/chis/internal/queue"
+	"github.com/bilalabdelkadir/chis/internal/relay"
 	"github.com/bilalabdelkadir/chis/internal/repository"
 	"github.com/bilalabdelkadir/chis/pkg/helper"
 	"github.com/google/uuid"
@@ -23,28 +26,65 @@ const (
 )
 
 type Worker struct {
-	messageRepo repository.MessageRepository
-	attemptRepo repository.DeliveryAttemptRepository
-	orgRepo     repository.OrganizationRepository
-	queue       *queue.Queue
-	httpClient  *http.Client
+	messageRepo      repository.MessageRepository
+	attemptRepo      repository.DeliveryAttemptRepository
+	orgRepo          repository.OrganizationRepository
+	endpointRepo     repository.EndpointRepository
+	signingKeyRepo   repository.SigningKeyRepository
+	notificationRepo repository.NotificationRepository
+	usageRepo        repository.UsageRepository
+	blobs            blobstore.Store
+	queue            *queue.Queue
+	relay            *relay.Relay
+	logStream        *logstream.Stream
+	events           *events.Emitter
+	disableAfter     time.Duration
+	limits           Limits
+	httpClient       *http.Client
+	relaySlots       chan struct{}
 }
 
 func NewWorker(messageRepo repository.MessageRepository, attemptRepo repository.DeliveryAttemptRepository,
-	orgRepo repository.OrganizationRepository, queue *queue.Queue,
+	orgRepo repository.OrganizationRepository, endpointRepo repository.EndpointRepository,
+	signingKeyRepo repository.SigningKeyRepository, notificationRepo repository.NotificationRepository,
+	usageRepo repository.UsageRepository, blobs blobstore.Store, queue *queue.Queue,
+	relay *relay.Relay, logStream *logstream.Stream, disableAfter time.Duration, limits Limits,
 ) *Worker {
 	return &Worker{
-		messageRepo: messageRepo,
-		attemptRepo: attemptRepo,
-		orgRepo:     orgRepo,
-		queue:       queue,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		messageRepo:      messageRepo,
+		attemptRepo:      attemptRepo,
+		orgRepo:          orgRepo,
+		endpointRepo:     endpointRepo,
+		signingKeyRepo:   signingKeyRepo,
+		notificationRepo: notificationRepo,
+		usageRepo:        usageRepo,
+		blobs:            blobs,
+		queue:            queue,
+		relay:            relay,
+		logStream:        logStream,
+		events:           events.NewEmitter(messageRepo, endpointRepo, queue),
+		disableAfter:     disableAfter,
+		limits:           limits,
+		httpClient:       NewHTTPClient(limits),
+		relaySlots:       make(chan struct{}, limits.MaxRelayInFlight),
 	}
 }
 
+// Start delivers messages from the queue, Concurrency at a time, until ctx
+// is cancelled.
 func (w *Worker) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range max(w.limits.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *Worker) run(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -63,6 +103,10 @@ func (w *Worker) Start(ctx context.Context) {
 			if err != nil {
 				continue
 			}
+			if message.Status == "cancelled" {
+				slog.Info("webhook_skipped_cancelled", "message_id", message.ID, "org_id", message.OrgID)
+				continue
+			}
 
 			w.deliver(ctx, message)
 
@@ -71,40 +115,60 @@ func (w *Worker) Start(ctx context.Context) {
 }
 
 func (w *Worker) deliver(ctx context.Context, msg *model.Message) {
-	req, err := http.NewRequestWithContext(
-		ctx,
-		msg.Method,
-		msg.URL,
-		bytes.NewReader(msg.Payload),
-	)
+	encoding := helper.EncodingIdentity
+	var endpoint *model.Endpoint
+	if msg.EndpointID != nil {
+		e, err := w.endpointRepo.FindByID(ctx, *msg.EndpointID)
+		if err != nil {
+			slog.Error("webhook_endpoint_lookup_failed", "message_id", msg.ID, "endpoint_id", *msg.EndpointID, "error", err)
+		} else {
+			endpoint = e
+			encoding = endpoint.ContentEncoding
+		}
+	}
+
+	if endpoint != nil && endpoint.DisabledAt != nil {
+		w.hold(ctx, msg, endpoint)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, msg.Method, msg.URL, nil)
 	if err != nil {
-		_, err = w.messageRepo.UpdateStatus(ctx, msg.ID, "failed")
+		if _, err := w.messageRepo.UpdateStatus(ctx, msg.ID, "failed"); err == nil {
+			w.logStream.PublishStatus(ctx, msg, "failed")
+		}
 		return
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	msgID := "msg_" + msg.ID.String()
-	secret, secretErr := w.orgRepo.GetSigningSecret(ctx, msg.OrgID)
-	if secretErr != nil {
-		slog.Error("webhook_signing_secret_lookup_failed", "message_id", msg.ID, "org_id", msg.OrgID, "error", secretErr)
-	} else {
-		sig := helper.SignWebhookPayload(msgID, secret, msg.Payload)
-		req.Header.Set("X-Webhook-ID", sig.MessageID)
-		req.Header.Set("X-Webhook-Timestamp", fmt.Sprintf("%d", sig.Timestamp))
-		req.Header.Set("X-Webhook-Signature", sig.Signature)
+	// The signature always covers the uncompressed payload.
+	payloadErr := w.signRequest(ctx, req, msg, endpoint)
+	if payloadErr == nil {
+		payloadErr = w.attachBody(ctx, req, msg, encoding)
 	}
 
 	slog.Info("webhook_delivering", "message_id", msg.ID, "org_id", msg.OrgID, "url", msg.URL)
 
 	start := time.Now()
-	resp, err := w.httpClient.Do(req)
+	var resp *http.Response
+	if payloadErr != nil {
+		slog.Error("webhook_payload_unavailable", "message_id", msg.ID, "org_id", msg.OrgID, "error", payloadErr)
+		err = payloadErr
+	} else if endpoint != nil && endpoint.Relay {
+		resp, err = w.relayRequest(ctx, endpoint, msg, req)
+	} else {
+		reqCtx, cancel := context.WithTimeout(ctx, w.limits.timeoutFor(endpoint))
+		defer cancel()
+		resp, err = w.httpClient.Do(req.WithContext(httptrace.WithClientTrace(reqCtx, connTrace)))
+	}
 	duration := time.Since(start)
 
 	var (
 		statusCode   *int
 		errorMessage *string
 		responseBody *string
+		truncated    bool
 		durationMS   *int
 		success      bool
 	)
@@ -122,7 +186,8 @@ func (w *Worker) deliver(ctx context.Context, msg *model.Message) {
 		code := resp.StatusCode
 		statusCode = &code
 
-		body, _ := io.ReadAll(resp.Body)
+		var body []byte
+		body, truncated, _ = readResponseBody(resp.Body, w.limits.MaxResponseBytes)
 		if len(body) > 0 {
 			bodyStr := string(body)
 			responseBody = &bodyStr
@@ -132,21 +197,27 @@ func (w *Worker) deliver(ctx context.Context, msg *model.Message) {
 	}
 
 	attempt := &model.DeliveryAttempt{
-		MessageID:     msg.ID,
-		AttemptNumber: msg.AttemptCount + 1,
-		StatusCode:    statusCode,
-		ErrorMessage:  errorMessage,
-		DurationMS:    durationMS,
-		ResponseBody:  responseBody,
+		MessageID:         msg.ID,
+		AttemptNumber:     msg.AttemptCount + 1,
+		StatusCode:        statusCode,
+		ErrorMessage:      errorMessage,
+		DurationMS:        durationMS,
+		ResponseBody:      responseBody,
+		ResponseTruncated: truncated,
 	}
 
 	_ = w.attemptRepo.Create(ctx, attempt)
+	if err := w.usageRepo.Record(ctx, msg.OrgID, 0, 0, 1); err != nil {
+		slog.Error("usage_record_failed", "message_id", msg.ID, "org_id", msg.OrgID, "error", err)
+	}
 
 	if success {
 		slog.Info("webhook_delivered", "message_id", msg.ID, "org_id", msg.OrgID, "status_code", *statusCode, "duration_ms", *durationMS)
 		_, err = w.messageRepo.UpdateStatus(ctx, msg.ID, "success")
 		metrics.WebhooksDeliveredTotal.WithLabelValues("success").Inc()
 		metrics.WebhookDeliveryDuration.Observe(float64(ms))
+		msg.AttemptCount++
+		w.logStream.PublishAttempt(ctx, msg, "success", attempt)
 	} else {
 		if errorMessage != nil {
 			slog.Warn("webhook_failed", "message_id", msg.ID, "org_id", msg.OrgID, "error", *errorMessage)
@@ -165,11 +236,22 @@ func (w *Worker) deliver(ctx context.Context, msg *model.Message) {
 			err = w.messageRepo.Update(ctx, updatedData)
 			metrics.WebhooksDeliveredTotal.WithLabelValues("failed").Inc()
 			metrics.WebhookDeliveryDuration.Observe(float64(ms))
+			msg.AttemptCount, msg.NextRetryAt = updatedData.AttemptCount, &nextRetry
+			w.logStream.PublishAttempt(ctx, msg, "retry", attempt)
 		} else {
 			msg.Status = "failed"
 			err = w.messageRepo.Update(ctx, msg)
 			metrics.WebhooksDeliveredTotal.WithLabelValues("dead_letter").Inc()
 			metrics.WebhookDeliveryDuration.Observe(float64(ms))
+			w.emitExhausted(ctx, msg, endpoint, statusCode, errorMessage)
+			msg.AttemptCount++
+			w.logStream.PublishAttempt(ctx, msg, "failed", attempt)
 		}
 	}
+
+	// Signing and payload problems are ours, not the endpoint's, and a
+	// relayed attempt only says how the developer's local server is doing.
+	if endpoint != nil && !endpoint.Relay && payloadErr == nil {
+		w.recordHealth(ctx, endpoint, success)
+	}
 }
@@ -0,0 +1,201 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bilalabdelkadir/chis/internal/logstream"
+	"github.com/bilalabdelkadir/chis/internal/model"
+	"github.com/bilalabdelkadir/chis/internal/queue"
+	"github.com/bilalabdelkadir/chis/internal/repository"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// The fakes embed the repository interfaces, so a test that reaches a
+// method they don't implement panics instead of passing by accident.
+
+type fakeMessages struct {
+	repository.MessageRepository
+	mu       sync.Mutex
+	created  []*model.Message
+	statuses map[uuid.UUID]string
+	updated  []model.Message
+	held     []*model.Message
+}
+
+func (f *fakeMessages) Create(_ context.Context, msg *model.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	msg.ID = uuid.New()
+	f.created = append(f.created, msg)
+	return nil
+}
+
+func (f *fakeMessages) UpdateStatus(_ context.Context, id uuid.UUID, status string) (*model.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.statuses == nil {
+		f.statuses = map[uuid.UUID]string{}
+	}
+	f.statuses[id] = status
+	return &model.Message{ID: id, Status: status}, nil
+}
+
+func (f *fakeMessages) Update(_ context.Context, msg *model.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updated = append(f.updated, *msg)
+	return nil
+}
+
+func (f *fakeMessages) HoldByEndpoint(context.Context, uuid.UUID) ([]*model.Message, error) {
+	return f.held, nil
+}
+
+// eventTypes lists the event types of the messages created, in order.
+func (f *fakeMessages) eventTypes() []string {
+	var types []string
+	for _, msg := range f.created {
+		if msg.EventType != nil {
+			types = append(types, *msg.EventType)
+		}
+	}
+	return types
+}
+
+type fakeEndpoints struct {
+	repository.EndpointRepository
+	endpoints   map[uuid.UUID]*model.Endpoint
+	operational []*model.Endpoint
+	health      *repository.DeliveryHealth
+	recorded    []bool
+	disableErr  error
+	disabled    []string
+}
+
+func (f *fakeEndpoints) FindByID(_ context.Context, id uuid.UUID) (*model.Endpoint, error) {
+	if e, ok := f.endpoints[id]; ok {
+		return e, nil
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (f *fakeEndpoints) FindOperational(context.Context, uuid.UUID) ([]*model.Endpoint, error) {
+	return f.operational, nil
+}
+
+func (f *fakeEndpoints) RecordDelivery(_ context.Context, _ uuid.UUID, success bool) (*repository.DeliveryHealth, error) {
+	f.recorded = append(f.recorded, success)
+	if f.health == nil {
+		return &repository.DeliveryHealth{Endpoint: &model.Endpoint{}}, nil
+	}
+	return f.health, nil
+}
+
+func (f *fakeEndpoints) Disable(_ context.Context, _ uuid.UUID, reason string) error {
+	if f.disableErr != nil {
+		return f.disableErr
+	}
+	f.disabled = append(f.disabled, reason)
+	return nil
+}
+
+type fakeAttempts struct {
+	repository.DeliveryAttemptRepository
+	created []*model.DeliveryAttempt
+}
+
+func (f *fakeAttempts) Create(_ context.Context, attempt *model.DeliveryAttempt) error {
+	f.created = append(f.created, attempt)
+	return nil
+}
+
+type fakeOrgs struct {
+	repository.OrganizationRepository
+	org *model.Organization
+}
+
+func (f *fakeOrgs) FindByID(context.Context, uuid.UUID) (*model.Organization, error) {
+	if f.org == nil {
+		return nil, repository.ErrNotFound
+	}
+	return f.org, nil
+}
+
+type fakeSigningKeys struct {
+	repository.SigningKeyRepository
+	key *model.SigningKey
+}
+
+func (f *fakeSigningKeys) FindActiveByOrgID(context.Context, uuid.UUID) (*model.SigningKey, error) {
+	if f.key == nil {
+		return nil, repository.ErrNotFound
+	}
+	return f.key, nil
+}
+
+type fakeNotifications struct {
+	repository.NotificationRepository
+	created []*model.Notification
+}
+
+func (f *fakeNotifications) Create(_ context.Context, n *model.Notification) error {
+	f.created = append(f.created, n)
+	return nil
+}
+
+type fakeUsage struct {
+	repository.UsageRepository
+}
+
+func (fakeUsage) Record(context.Context, uuid.UUID, int64, int64, int64) error {
+	return nil
+}
+
+// testWorker is a worker on fake repositories and miniredis.
+type testWorker struct {
+	*Worker
+	messages      *fakeMessages
+	endpoints     *fakeEndpoints
+	attempts      *fakeAttempts
+	orgs          *fakeOrgs
+	signingKeys   *fakeSigningKeys
+	notifications *fakeNotifications
+}
+
+func newTestWorker(t *testing.T, disableAfter time.Duration) *testWorker {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	tw := &testWorker{
+		messages:      &fakeMessages{},
+		endpoints:     &fakeEndpoints{endpoints: map[uuid.UUID]*model.Endpoint{}},
+		attempts:      &fakeAttempts{},
+		orgs:          &fakeOrgs{},
+		signingKeys:   &fakeSigningKeys{},
+		notifications: &fakeNotifications{},
+	}
+	limits := Limits{
+		Concurrency:         1,
+		Timeout:             5 * time.Second,
+		MinTimeout:          time.Second,
+		MaxTimeout:          10 * time.Second,
+		ConnectTimeout:      time.Second,
+		TLSTimeout:          time.Second,
+		ReadTimeout:         5 * time.Second,
+		MaxResponseBytes:    1024,
+		MaxIdleConnsPerHost: 4,
+		MaxIdleConns:        16,
+		IdleConnTimeout:     time.Second,
+	}
+	tw.Worker = NewWorker(tw.messages, tw.attempts, tw.orgs, tw.endpoints, tw.signingKeys,
+		tw.notifications, fakeUsage{}, nil, queue.NewQueue(rdb, "webhooks", 1, 0),
+		nil, logstream.New(rdb), disableAfter, limits)
+	return tw
+}
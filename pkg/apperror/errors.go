@@ -55,6 +55,20 @@ func Conflict(message string) *AppError {
 	}
 }
 
+func PayloadTooLarge(message string) *AppError {
+	return &AppError{
+		Code:    http.StatusRequestEntityTooLarge,
+		Message: message,
+	}
+}
+
+func PaymentRequired(message string) *AppError {
+	return &AppError{
+		Code:    http.StatusPaymentRequired,
+		Message: message,
+	}
+}
+
 func ValidationFailed(details []shared.FieldError) *AppError {
 	return &AppError{
 		Code:    http.StatusUnprocessableEntity,
@@ -70,6 +84,13 @@ func Internal(message string) *AppError {
 	}
 }
 
+func ServiceUnavailable(message string) *AppError {
+	return &AppError{
+		Code:    http.StatusServiceUnavailable,
+		Message: message,
+	}
+}
+
 func (e *AppError) WithContext(r *http.Request) *AppError {
 	e.Path = r.URL.Path
 	e.Timestamp = time.Now().UTC()
@@ -0,0 +1,312 @@
+// Package client is the Go SDK for the Chis public API.
+//
+//	c := client.New("https://api.example.com", os.Getenv("CHIS_API_KEY"))
+//	res, err := c.Send(ctx, &client.SendRequest{URL: url, Payload: event})
+//
+// Code that sends webhooks should depend on the API interface so tests can
+// swap in a Fake.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultMaxRetries = 3
+	defaultBaseDelay  = 500 * time.Millisecond
+	maxDelay          = 10 * time.Second
+)
+
+// API is implemented by Client and Fake.
+type API interface {
+	Send(ctx context.Context, req *SendRequest) (*SendResult, error)
+	SendBatch(ctx context.Context, reqs []SendRequest) ([]BatchResult, error)
+	GetMessage(ctx context.Context, id uuid.UUID) (*Message, error)
+	ReplayMessage(ctx context.Context, id uuid.UUID) (*SendResult, error)
+	CancelMessage(ctx context.Context, id uuid.UUID) error
+
+	CreateEndpoint(ctx context.Context, req *CreateEndpointRequest) (*Endpoint, error)
+	ListEndpoints(ctx context.Context) ([]Endpoint, error)
+	GetEndpoint(ctx context.Context, id uuid.UUID) (*Endpoint, error)
+	UpdateEndpoint(ctx context.Context, id uuid.UUID, req *UpdateEndpointRequest) (*Endpoint, error)
+	DeleteEndpoint(ctx context.Context, id uuid.UUID) error
+	GetEndpointHealth(ctx context.Context, id uuid.UUID) (*EndpointHealth, error)
+	DisableEndpoint(ctx context.Context, id uuid.UUID) (*Endpoint, error)
+	EnableEndpoint(ctx context.Context, id uuid.UUID, replayHeld bool) (*EnableEndpointResult, error)
+}
+
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	maxRetries int
+	baseDelay  time.Duration
+}
+
+var _ API = (*Client)(nil)
+
+type Option func(*Client)
+
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithMaxRetries sets how many times a failed request is retried. Zero
+// disables retries.
+func WithMaxRetries(n int) Option {
+	return func(c *Client) { c.maxRetries = n }
+}
+
+// WithRetryDelay sets the first retry delay; later retries back off
+// exponentially from it.
+func WithRetryDelay(d time.Duration) Option {
+	return func(c *Client) { c.baseDelay = d }
+}
+
+func New(baseURL, apiKey string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: DefaultMaxRetries,
+		baseDelay:  defaultBaseDelay,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Send queues one webhook. A random idempotency key is generated when none
+// is set, so retries never queue the message twice.
+func (c *Client) Send(ctx context.Context, req *SendRequest) (*SendResult, error) {
+	body := *req
+	if body.IdempotencyKey == "" {
+		body.IdempotencyKey = uuid.NewString()
+	}
+
+	var res SendResult
+	if err := c.do(ctx, http.MethodPost, "/webhook/send", body, &res, true); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SendBatch queues up to 100 webhooks in one request. Each result reports
+// the message or the error for the request at the same index.
+func (c *Client) SendBatch(ctx context.Context, reqs []SendRequest) ([]BatchResult, error) {
+	messages := make([]SendRequest, len(reqs))
+	for i, req := range reqs {
+		if req.IdempotencyKey == "" {
+			req.IdempotencyKey = uuid.NewString()
+		}
+		messages[i] = req
+	}
+
+	var res struct {
+		Results []BatchResult `json:"results"`
+	}
+	body := map[string][]SendRequest{"messages": messages}
+	if err := c.do(ctx, http.MethodPost, "/webhook/send/batch", body, &res, true); err != nil {
+		return nil, err
+	}
+	return res.Results, nil
+}
+
+func (c *Client) GetMessage(ctx context.Context, id uuid.UUID) (*Message, error) {
+	var msg Message
+	if err := c.do(ctx, http.MethodGet, "/webhook/messages/"+id.String(), nil, &msg, true); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// ReplayMessage queues a copy of a delivered, failed or cancelled message.
+// It is not retried, since every successful call creates a new message.
+func (c *Client) ReplayMessage(ctx context.Context, id uuid.UUID) (*SendResult, error) {
+	var res SendResult
+	if err := c.do(ctx, http.MethodPost, "/webhook/messages/"+id.String()+"/replay", nil, &res, false); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) CancelMessage(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, http.MethodPost, "/webhook/messages/"+id.String()+"/cancel", nil, nil, false)
+}
+
+func (c *Client) CreateEndpoint(ctx context.Context, req *CreateEndpointRequest) (*Endpoint, error) {
+	var e Endpoint
+	if err := c.do(ctx, http.MethodPost, "/webhook/endpoints", req, &e, false); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (c *Client) ListEndpoints(ctx context.Context) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	if err := c.do(ctx, http.MethodGet, "/webhook/endpoints", nil, &endpoints, true); err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (c *Client) GetEndpoint(ctx context.Context, id uuid.UUID) (*Endpoint, error) {
+	var e Endpoint
+	if err := c.do(ctx, http.MethodGet, "/webhook/endpoints/"+id.String(), nil, &e, true); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (c *Client) UpdateEndpoint(ctx context.Context, id uuid.UUID, req *UpdateEndpointRequest) (*Endpoint, error) {
+	var e Endpoint
+	if err := c.do(ctx, http.MethodPatch, "/webhook/endpoints/"+id.String(), req, &e, true); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (c *Client) DeleteEndpoint(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, http.MethodDelete, "/webhook/endpoints/"+id.String(), nil, nil, true)
+}
+
+func (c *Client) GetEndpointHealth(ctx context.Context, id uuid.UUID) (*EndpointHealth, error) {
+	var h EndpointHealth
+	if err := c.do(ctx, http.MethodGet, "/webhook/endpoints/"+id.String()+"/health", nil, &h, true); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// DisableEndpoint stops deliveries; new and pending messages are held.
+func (c *Client) DisableEndpoint(ctx context.Context, id uuid.UUID) (*Endpoint, error) {
+	var e Endpoint
+	if err := c.do(ctx, http.MethodPost, "/webhook/endpoints/"+id.String()+"/disable", nil, &e, false); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// EnableEndpoint resumes deliveries. Held messages are retried when
+// replayHeld is true and marked failed otherwise.
+func (c *Client) EnableEndpoint(ctx context.Context, id uuid.UUID, replayHeld bool) (*EnableEndpointResult, error) {
+	var res EnableEndpointResult
+	req := map[string]bool{"replayHeld": replayHeld}
+	if err := c.do(ctx, http.MethodPost, "/webhook/endpoints/"+id.String()+"/enable", req, &res, false); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// do sends the request and decodes a 2xx response into out. Requests marked
+// retryable are retried on network errors, 408, 429 and 5xx responses.
+func (c *Client) do(ctx context.Context, method, path string, in, out any, retryable bool) error {
+	var payload []byte
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("client: encode request: %w", err)
+		}
+		payload = b
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, payload)
+		if err == nil && resp.StatusCode < 300 {
+			defer resp.Body.Close()
+			if out == nil || resp.StatusCode == http.StatusNoContent {
+				return nil
+			}
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return fmt.Errorf("client: decode response: %w", err)
+			}
+			return nil
+		}
+
+		var retryAfter time.Duration
+		if err == nil {
+			err = decodeError(resp)
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+
+		if !retryable || attempt >= c.maxRetries || !shouldRetry(ctx, err) {
+			return err
+		}
+
+		delay := c.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-API-Key", c.apiKey)
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return c.httpClient.Do(req)
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.baseDelay << attempt
+	if d <= 0 || d > maxDelay {
+		d = maxDelay
+	}
+	// Full jitter keeps many clients from retrying in lockstep.
+	return time.Duration(rand.Int64N(int64(d)) + 1)
+}
+
+func shouldRetry(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return true // network error
+	}
+	switch apiErr.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func parseRetryAfter(v string) time.Duration {
+	secs, err := strconv.Atoi(v)
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
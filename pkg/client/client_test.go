@@ -0,0 +1,350 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/pkg/client"
+	"github.com/google/uuid"
+)
+
+// recorder answers each request with the next response in its list, and
+// the last one once the list runs out.
+type recorder struct {
+	mu        sync.Mutex
+	responses []func(w http.ResponseWriter)
+	requests  []*http.Request
+	bodies    []map[string]any
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+	rec.requests = append(rec.requests, r)
+	rec.bodies = append(rec.bodies, body)
+
+	i := min(len(rec.requests), len(rec.responses)) - 1
+	rec.responses[i](w)
+}
+
+func reply(status int, body string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+func newClient(t *testing.T, rec *recorder, opts ...client.Option) *client.Client {
+	t.Helper()
+	srv := httptest.NewServer(rec)
+	t.Cleanup(srv.Close)
+	opts = append([]client.Option{client.WithRetryDelay(time.Millisecond)}, opts...)
+	return client.New(srv.URL, "chis_test_key", opts...)
+}
+
+const sent = `{"messageId":"7d3c4bd2-8f4b-4e0b-9a43-8b7d38d3c1a1","status":"pending"}`
+
+func TestSendRetriesWithSameIdempotencyKey(t *testing.T) {
+	rec := &recorder{responses: []func(http.ResponseWriter){
+		reply(http.StatusServiceUnavailable, `{"message":"unavailable"}`),
+		reply(http.StatusBadGateway, `bad gateway`),
+		reply(http.StatusOK, sent),
+	}}
+	c := newClient(t, rec)
+
+	res, err := c.Send(context.Background(), &client.SendRequest{URL: "https://example.com/hook", Payload: map[string]int{"n": 1}})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if res.MessageID.String() != "7d3c4bd2-8f4b-4e0b-9a43-8b7d38d3c1a1" || res.Status != "pending" {
+		t.Errorf("Send = %+v", res)
+	}
+
+	if len(rec.requests) != 3 {
+		t.Fatalf("got %d requests, want 3", len(rec.requests))
+	}
+	key, _ := rec.bodies[0]["idempotencyKey"].(string)
+	if _, err := uuid.Parse(key); err != nil {
+		t.Fatalf("idempotency key %q is not a generated UUID", key)
+	}
+	for i, r := range rec.requests {
+		if got := rec.bodies[i]["idempotencyKey"]; got != key {
+			t.Errorf("request %d idempotency key = %v, want %s", i, got, key)
+		}
+		if got := r.Header.Get("X-API-Key"); got != "chis_test_key" {
+			t.Errorf("request %d X-API-Key = %q", i, got)
+		}
+	}
+}
+
+func TestSendKeepsCallerIdempotencyKey(t *testing.T) {
+	rec := &recorder{responses: []func(http.ResponseWriter){reply(http.StatusOK, sent)}}
+	c := newClient(t, rec)
+
+	req := &client.SendRequest{URL: "https://example.com/hook", Payload: 1, IdempotencyKey: "order-42"}
+	if _, err := c.Send(context.Background(), req); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got := rec.bodies[0]["idempotencyKey"]; got != "order-42" {
+		t.Errorf("idempotency key = %v, want order-42", got)
+	}
+	if req.IdempotencyKey != "order-42" {
+		t.Errorf("Send changed the caller's request")
+	}
+}
+
+func TestRetryWaitsForRetryAfter(t *testing.T) {
+	rec := &recorder{responses: []func(http.ResponseWriter){
+		func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "1")
+			reply(http.StatusTooManyRequests, `{"message":"rate limit exceeded"}`)(w)
+		},
+		reply(http.StatusOK, sent),
+	}}
+	c := newClient(t, rec)
+
+	start := time.Now()
+	if _, err := c.Send(context.Background(), &client.SendRequest{URL: "https://example.com/hook", Payload: 1}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least the 1s Retry-After", elapsed)
+	}
+	if len(rec.requests) != 2 {
+		t.Errorf("got %d requests, want 2", len(rec.requests))
+	}
+}
+
+func TestRetryBacksOffUntilMaxRetries(t *testing.T) {
+	rec := &recorder{responses: []func(http.ResponseWriter){
+		reply(http.StatusInternalServerError, `{"message":"failed to save message"}`),
+	}}
+	c := newClient(t, rec, client.WithMaxRetries(2))
+
+	_, err := c.GetMessage(context.Background(), uuid.New())
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("GetMessage error = %v, want a 500 *client.Error", err)
+	}
+	if len(rec.requests) != 3 {
+		t.Errorf("got %d requests, want 1 plus 2 retries", len(rec.requests))
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name   string
+		status int
+		call   func(c *client.Client) error
+	}{
+		{"client error", http.StatusUnprocessableEntity, func(c *client.Client) error {
+			_, err := c.Send(context.Background(), &client.SendRequest{URL: "x", Payload: 1})
+			return err
+		}},
+		{"replay", http.StatusServiceUnavailable, func(c *client.Client) error {
+			_, err := c.ReplayMessage(context.Background(), id)
+			return err
+		}},
+		{"cancel", http.StatusServiceUnavailable, func(c *client.Client) error {
+			return c.CancelMessage(context.Background(), id)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{responses: []func(http.ResponseWriter){reply(tt.status, `{}`)}}
+			if err := tt.call(newClient(t, rec)); err == nil {
+				t.Fatal("expected an error")
+			}
+			if len(rec.requests) != 1 {
+				t.Errorf("got %d requests, want 1", len(rec.requests))
+			}
+		})
+	}
+}
+
+func TestRetryStopsWhenContextIsDone(t *testing.T) {
+	rec := &recorder{responses: []func(http.ResponseWriter){
+		func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "60")
+			reply(http.StatusTooManyRequests, `{}`)(w)
+		},
+	}}
+	c := newClient(t, rec)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.Send(ctx, &client.SendRequest{URL: "https://example.com/hook", Payload: 1})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestErrorDecoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		is      error
+		message string
+		details int
+	}{
+		{"validation", http.StatusUnprocessableEntity,
+			`{"message":"Validation failed","path":"/webhook/send","timestamp":"2026-01-02T03:04:05Z","details":[{"field":"url","message":"url is required"}]}`,
+			client.ErrValidation, "Validation failed", 1},
+		{"quota", http.StatusPaymentRequired, `{"message":"monthly message quota exceeded"}`,
+			client.ErrQuotaExceeded, "monthly message quota exceeded", 0},
+		{"not found", http.StatusNotFound, `{"message":"message not found"}`, client.ErrNotFound, "message not found", 0},
+		{"conflict", http.StatusConflict, `{"message":"message is still being delivered"}`,
+			client.ErrConflict, "message is still being delivered", 0},
+		{"unauthorized", http.StatusUnauthorized, ``, client.ErrUnauthorized, "", 0},
+		{"not json", http.StatusForbidden, `forbidden`, client.ErrForbidden, "forbidden", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{responses: []func(http.ResponseWriter){reply(tt.status, tt.body)}}
+			_, err := newClient(t, rec).ReplayMessage(context.Background(), uuid.New())
+
+			if !errors.Is(err, tt.is) {
+				t.Fatalf("error = %v, want errors.Is %v", err, tt.is)
+			}
+			if errors.Is(err, client.ErrBadRequest) {
+				t.Errorf("error %v matches ErrBadRequest too", err)
+			}
+			var apiErr *client.Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error %T is not a *client.Error", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Message != tt.message || len(apiErr.Details) != tt.details {
+				t.Errorf("error = %+v", apiErr)
+			}
+		})
+	}
+}
+
+func TestFakeSend(t *testing.T) {
+	ctx := context.Background()
+	f := client.NewFake()
+
+	first, err := f.Send(ctx, &client.SendRequest{URL: "https://example.com/hook", Payload: 1, IdempotencyKey: "k"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	again, err := f.Send(ctx, &client.SendRequest{URL: "https://example.com/hook", Payload: 2, IdempotencyKey: "k"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if again.MessageID != first.MessageID {
+		t.Errorf("same idempotency key queued a second message")
+	}
+	if n := len(f.Messages()); n != 1 {
+		t.Errorf("got %d messages, want 1", n)
+	}
+
+	_, err = f.Send(ctx, &client.SendRequest{URL: "https://example.com/hook", Payload: 1, Priority: "urgent"})
+	if !errors.Is(err, client.ErrValidation) {
+		t.Errorf("bad priority error = %v, want ErrValidation", err)
+	}
+
+	results, err := f.SendBatch(ctx, []client.SendRequest{
+		{URL: "https://example.com/hook", Payload: 1},
+		{Payload: 1},
+	})
+	if err != nil {
+		t.Fatalf("SendBatch: %v", err)
+	}
+	if results[0].MessageID == nil || results[0].Error != nil {
+		t.Errorf("results[0] = %+v, want a message", results[0])
+	}
+	if results[1].Error == nil || results[1].Error.Code != http.StatusUnprocessableEntity {
+		t.Errorf("results[1] = %+v, want a 422", results[1])
+	}
+}
+
+func TestFakeReplayAndCancel(t *testing.T) {
+	ctx := context.Background()
+	f := client.NewFake()
+
+	res, _ := f.Send(ctx, &client.SendRequest{URL: "https://example.com/hook", Payload: 1})
+	if _, err := f.ReplayMessage(ctx, res.MessageID); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("replaying a pending message: %v, want ErrConflict", err)
+	}
+
+	if err := f.SetStatus(res.MessageID, "success"); err != nil {
+		t.Fatalf("SetStatus: %v", err)
+	}
+	replay, err := f.ReplayMessage(ctx, res.MessageID)
+	if err != nil {
+		t.Fatalf("ReplayMessage: %v", err)
+	}
+	msg, err := f.GetMessage(ctx, replay.MessageID)
+	if err != nil {
+		t.Fatalf("GetMessage: %v", err)
+	}
+	if msg.ReplayedFrom == nil || *msg.ReplayedFrom != res.MessageID {
+		t.Errorf("replay ReplayedFrom = %v, want %s", msg.ReplayedFrom, res.MessageID)
+	}
+
+	if err := f.CancelMessage(ctx, replay.MessageID); err != nil {
+		t.Fatalf("CancelMessage: %v", err)
+	}
+	if err := f.CancelMessage(ctx, replay.MessageID); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("cancelling twice: %v, want ErrConflict", err)
+	}
+	if _, err := f.GetMessage(ctx, uuid.New()); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("unknown message: %v, want ErrNotFound", err)
+	}
+}
+
+func TestFakeDisableHoldsMessages(t *testing.T) {
+	ctx := context.Background()
+	f := client.NewFake()
+	const url = "https://example.com/hook"
+
+	e, err := f.CreateEndpoint(ctx, &client.CreateEndpointRequest{URL: url})
+	if err != nil {
+		t.Fatalf("CreateEndpoint: %v", err)
+	}
+	if _, err := f.CreateEndpoint(ctx, &client.CreateEndpointRequest{URL: url}); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("duplicate endpoint: %v, want ErrConflict", err)
+	}
+
+	f.Send(ctx, &client.SendRequest{URL: url, Payload: 1})
+	f.Send(ctx, &client.SendRequest{URL: url, Payload: 2})
+	if _, err := f.DisableEndpoint(ctx, e.ID); err != nil {
+		t.Fatalf("DisableEndpoint: %v", err)
+	}
+	health, err := f.GetEndpointHealth(ctx, e.ID)
+	if err != nil {
+		t.Fatalf("GetEndpointHealth: %v", err)
+	}
+	if health.Status != "disabled" || health.HeldMessages != 2 {
+		t.Errorf("health = %+v, want disabled with 2 held", health)
+	}
+
+	res, err := f.EnableEndpoint(ctx, e.ID, true)
+	if err != nil {
+		t.Fatalf("EnableEndpoint: %v", err)
+	}
+	if res.HeldMessages != 2 || !res.Replayed || res.Endpoint.DisabledAt != nil {
+		t.Errorf("EnableEndpoint = %+v", res)
+	}
+	for _, msg := range f.Messages() {
+		if msg.Status != "retry" {
+			t.Errorf("message %s is %s, want retry", msg.ID, msg.Status)
+		}
+	}
+	if _, err := f.EnableEndpoint(ctx, e.ID, true); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("enabling twice: %v, want ErrConflict", err)
+	}
+}
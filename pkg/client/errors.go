@@ -0,0 +1,58 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/pkg/shared"
+)
+
+// Error is a non-2xx API response, decoded from the server's error body.
+// Compare with errors.Is against the sentinels below to branch on the
+// status code.
+type Error struct {
+	StatusCode int                 `json:"-"`
+	Message    string              `json:"message"`
+	Path       string              `json:"path"`
+	Timestamp  time.Time           `json:"timestamp"`
+	Details    []shared.FieldError `json:"details,omitempty"`
+}
+
+var (
+	ErrBadRequest      = &Error{StatusCode: http.StatusBadRequest}
+	ErrUnauthorized    = &Error{StatusCode: http.StatusUnauthorized}
+	ErrQuotaExceeded   = &Error{StatusCode: http.StatusPaymentRequired}
+	ErrForbidden       = &Error{StatusCode: http.StatusForbidden}
+	ErrNotFound        = &Error{StatusCode: http.StatusNotFound}
+	ErrConflict        = &Error{StatusCode: http.StatusConflict}
+	ErrPayloadTooLarge = &Error{StatusCode: http.StatusRequestEntityTooLarge}
+	ErrValidation      = &Error{StatusCode: http.StatusUnprocessableEntity}
+	ErrRateLimited     = &Error{StatusCode: http.StatusTooManyRequests}
+)
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("chis: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("chis: %d %s", e.StatusCode, e.Message)
+}
+
+// Is matches any Error with the same status code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.StatusCode == e.StatusCode
+}
+
+func decodeError(resp *http.Response) error {
+	defer resp.Body.Close()
+
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err := json.Unmarshal(body, apiErr); err != nil && len(body) > 0 {
+		apiErr.Message = string(body)
+	}
+	return apiErr
+}
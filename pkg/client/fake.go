@@ -0,0 +1,401 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Fake is an in-memory API for unit tests. It follows the server's rules
+// for idempotency keys, replay and cancel, but never delivers anything:
+// use SetStatus to move a message along.
+type Fake struct {
+	mu          sync.Mutex
+	messages    map[uuid.UUID]*Message
+	order       []uuid.UUID
+	idempotency map[string]uuid.UUID
+	endpoints   map[uuid.UUID]*Endpoint
+	orgID       uuid.UUID
+}
+
+var _ API = (*Fake)(nil)
+
+func NewFake() *Fake {
+	return &Fake{
+		messages:    make(map[uuid.UUID]*Message),
+		idempotency: make(map[string]uuid.UUID),
+		endpoints:   make(map[uuid.UUID]*Endpoint),
+		orgID:       uuid.New(),
+	}
+}
+
+// Messages returns every message queued so far, oldest first.
+func (f *Fake) Messages() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := make([]Message, 0, len(f.order))
+	for _, id := range f.order {
+		out = append(out, *f.messages[id])
+	}
+	return out
+}
+
+// SetStatus simulates delivery progress, e.g. "success" or "failed".
+func (f *Fake) SetStatus(id uuid.UUID, status string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	msg, ok := f.messages[id]
+	if !ok {
+		return fakeError(http.StatusNotFound, "message not found")
+	}
+	msg.Status = status
+	msg.UpdatedAt = time.Now()
+	return nil
+}
+
+func (f *Fake) Send(ctx context.Context, req *SendRequest) (*SendResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.send(req)
+}
+
+func (f *Fake) SendBatch(ctx context.Context, reqs []SendRequest) ([]BatchResult, error) {
+	if len(reqs) == 0 || len(reqs) > 100 {
+		return nil, fakeError(http.StatusUnprocessableEntity, "Validation failed")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	results := make([]BatchResult, len(reqs))
+	for i := range reqs {
+		res, err := f.send(&reqs[i])
+		if err != nil {
+			apiErr := err.(*Error)
+			results[i].Error = &BatchItemError{Code: apiErr.StatusCode, Message: apiErr.Message}
+			continue
+		}
+		results[i].MessageID = &res.MessageID
+		results[i].Status = res.Status
+	}
+	return results, nil
+}
+
+func (f *Fake) send(req *SendRequest) (*SendResult, error) {
+	if req.URL == "" || req.Payload == nil {
+		return nil, fakeError(http.StatusUnprocessableEntity, "Validation failed")
+	}
+
+	if req.IdempotencyKey != "" {
+		if id, ok := f.idempotency[req.IdempotencyKey]; ok {
+			msg := f.messages[id]
+			return &SendResult{MessageID: msg.ID, Status: msg.Status}, nil
+		}
+	}
+
+	payload, err := json.Marshal(req.Payload)
+	if err != nil {
+		return nil, fakeError(http.StatusBadRequest, err.Error())
+	}
+
+	method := req.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	priority := req.Priority
+	switch priority {
+	case "":
+		priority = PriorityNormal
+	case PriorityHigh, PriorityNormal, PriorityLow:
+	default:
+		return nil, fakeError(http.StatusUnprocessableEntity, "Validation failed")
+	}
+
+	now := time.Now()
+	msg := &Message{
+		ID:        uuid.New(),
+		Method:    method,
+		URL:       req.URL,
+		Status:    "pending",
+		Priority:  priority,
+		Payload:   payload,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if req.EventType != "" {
+		eventType := req.EventType
+		msg.EventType = &eventType
+	}
+	if req.IdempotencyKey != "" {
+		key := req.IdempotencyKey
+		msg.IdempotencyKey = &key
+		f.idempotency[key] = msg.ID
+	}
+	f.store(msg)
+
+	return &SendResult{MessageID: msg.ID, Status: msg.Status}, nil
+}
+
+func (f *Fake) store(msg *Message) {
+	f.messages[msg.ID] = msg
+	f.order = append(f.order, msg.ID)
+}
+
+func (f *Fake) GetMessage(ctx context.Context, id uuid.UUID) (*Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	msg, ok := f.messages[id]
+	if !ok {
+		return nil, fakeError(http.StatusNotFound, "webhook log not found")
+	}
+	cp := *msg
+	return &cp, nil
+}
+
+func (f *Fake) ReplayMessage(ctx context.Context, id uuid.UUID) (*SendResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	orig, ok := f.messages[id]
+	if !ok {
+		return nil, fakeError(http.StatusNotFound, "message not found")
+	}
+	if orig.Status == "pending" || orig.Status == "retry" {
+		return nil, fakeError(http.StatusConflict, "message is still being delivered")
+	}
+
+	now := time.Now()
+	replay := &Message{
+		ID:           uuid.New(),
+		Method:       orig.Method,
+		URL:          orig.URL,
+		Status:       "retry",
+		Payload:      orig.Payload,
+		EventType:    orig.EventType,
+		ReplayedFrom: &orig.ID,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		NextRetryAt:  &now,
+	}
+	f.store(replay)
+
+	return &SendResult{MessageID: replay.ID, Status: replay.Status}, nil
+}
+
+func (f *Fake) CancelMessage(ctx context.Context, id uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	msg, ok := f.messages[id]
+	if !ok {
+		return fakeError(http.StatusNotFound, "message not found")
+	}
+	if msg.Status != "pending" && msg.Status != "retry" && msg.Status != "held" {
+		return fakeError(http.StatusConflict, "message has already been delivered or cancelled")
+	}
+	msg.Status = "cancelled"
+	msg.NextRetryAt = nil
+	msg.UpdatedAt = time.Now()
+	return nil
+}
+
+func (f *Fake) CreateEndpoint(ctx context.Context, req *CreateEndpointRequest) (*Endpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if req.URL == "" {
+		return nil, fakeError(http.StatusUnprocessableEntity, "Validation failed")
+	}
+	for _, e := range f.endpoints {
+		if e.URL == req.URL {
+			return nil, fakeError(http.StatusConflict, "endpoint already exists")
+		}
+	}
+
+	encoding := req.ContentEncoding
+	if encoding == "" {
+		encoding = "identity"
+	}
+
+	now := time.Now()
+	e := &Endpoint{
+		ID:              uuid.New(),
+		OrgID:           f.orgID,
+		URL:             req.URL,
+		ContentEncoding: encoding,
+		Relay:           req.Relay,
+		Operational:     req.Operational,
+		TimeoutMS:       req.TimeoutMS,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	f.endpoints[e.ID] = e
+
+	cp := *e
+	return &cp, nil
+}
+
+func (f *Fake) ListEndpoints(ctx context.Context) ([]Endpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := make([]Endpoint, 0, len(f.endpoints))
+	for _, e := range f.endpoints {
+		out = append(out, *e)
+	}
+	return out, nil
+}
+
+func (f *Fake) GetEndpoint(ctx context.Context, id uuid.UUID) (*Endpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e, ok := f.endpoints[id]
+	if !ok {
+		return nil, fakeError(http.StatusNotFound, "endpoint not found")
+	}
+	cp := *e
+	return &cp, nil
+}
+
+func (f *Fake) UpdateEndpoint(ctx context.Context, id uuid.UUID, req *UpdateEndpointRequest) (*Endpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e, ok := f.endpoints[id]
+	if !ok {
+		return nil, fakeError(http.StatusNotFound, "endpoint not found")
+	}
+	if req.ContentEncoding == "" && req.Relay == nil && req.Operational == nil && req.TimeoutMS == nil {
+		return nil, fakeError(http.StatusBadRequest, "nothing to update")
+	}
+	if req.ContentEncoding != "" {
+		e.ContentEncoding = req.ContentEncoding
+	}
+	if req.Relay != nil {
+		e.Relay = *req.Relay
+	}
+	if req.Operational != nil {
+		e.Operational = *req.Operational
+	}
+	if req.TimeoutMS != nil {
+		if *req.TimeoutMS == 0 {
+			e.TimeoutMS = nil
+		} else {
+			e.TimeoutMS = req.TimeoutMS
+		}
+	}
+	e.UpdatedAt = time.Now()
+
+	cp := *e
+	return &cp, nil
+}
+
+func (f *Fake) DeleteEndpoint(ctx context.Context, id uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.endpoints[id]; !ok {
+		return fakeError(http.StatusNotFound, "endpoint not found")
+	}
+	delete(f.endpoints, id)
+	return nil
+}
+
+// GetEndpointHealth reports what the fake knows: status and held messages.
+func (f *Fake) GetEndpointHealth(ctx context.Context, id uuid.UUID) (*EndpointHealth, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e, ok := f.endpoints[id]
+	if !ok {
+		return nil, fakeError(http.StatusNotFound, "endpoint not found")
+	}
+	h := &EndpointHealth{
+		Status:         "healthy",
+		DisabledAt:     e.DisabledAt,
+		DisabledReason: e.DisabledReason,
+		Since:          time.Now().Add(-24 * time.Hour),
+	}
+	if e.DisabledAt != nil {
+		h.Status = "disabled"
+	}
+	for _, msg := range f.messages {
+		if msg.URL == e.URL && msg.Status == "held" {
+			h.HeldMessages++
+		}
+	}
+	return h, nil
+}
+
+func (f *Fake) DisableEndpoint(ctx context.Context, id uuid.UUID) (*Endpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e, ok := f.endpoints[id]
+	if !ok {
+		return nil, fakeError(http.StatusNotFound, "endpoint not found")
+	}
+	if e.DisabledAt != nil {
+		return nil, fakeError(http.StatusConflict, "endpoint is already disabled")
+	}
+	now := time.Now()
+	reason := "disabled manually"
+	e.DisabledAt = &now
+	e.DisabledReason = &reason
+	for _, msg := range f.messages {
+		if msg.URL == e.URL && (msg.Status == "pending" || msg.Status == "retry") {
+			msg.Status = "held"
+			msg.NextRetryAt = nil
+		}
+	}
+
+	cp := *e
+	return &cp, nil
+}
+
+func (f *Fake) EnableEndpoint(ctx context.Context, id uuid.UUID, replayHeld bool) (*EnableEndpointResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e, ok := f.endpoints[id]
+	if !ok {
+		return nil, fakeError(http.StatusNotFound, "endpoint not found")
+	}
+	if e.DisabledAt == nil {
+		return nil, fakeError(http.StatusConflict, "endpoint is not disabled")
+	}
+	e.DisabledAt = nil
+	e.DisabledReason = nil
+
+	res := &EnableEndpointResult{Replayed: replayHeld}
+	now := time.Now()
+	for _, msg := range f.messages {
+		if msg.URL != e.URL || msg.Status != "held" {
+			continue
+		}
+		res.HeldMessages++
+		if replayHeld {
+			msg.Status = "retry"
+			msg.AttemptCount = 0
+			msg.NextRetryAt = &now
+		} else {
+			msg.Status = "failed"
+		}
+	}
+	res.Endpoint = *e
+	return res, nil
+}
+
+func fakeError(code int, message string) *Error {
+	return &Error{StatusCode: code, Message: message, Timestamp: time.Now().UTC()}
+}
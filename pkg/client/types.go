@@ -0,0 +1,135 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SendRequest struct {
+	URL     string `json:"url"`
+	Method  string `json:"method,omitempty"` // defaults to POST
+	Payload any    `json:"payload"`
+	// IdempotencyKey dedupes sends for the org. Send and SendBatch fill
+	// in a random key when it is empty.
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	// Priority is PriorityHigh, PriorityNormal (the default) or PriorityLow.
+	Priority string `json:"priority,omitempty"`
+	// EventType names what the payload describes, e.g. order.created.
+	EventType string `json:"eventType,omitempty"`
+}
+
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+type SendResult struct {
+	MessageID uuid.UUID `json:"messageId"`
+	Status    string    `json:"status"`
+}
+
+// BatchResult has MessageID and Status set on success and Error otherwise.
+type BatchResult struct {
+	MessageID *uuid.UUID      `json:"messageId,omitempty"`
+	Status    string          `json:"status,omitempty"`
+	Error     *BatchItemError `json:"error,omitempty"`
+}
+
+type BatchItemError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type Message struct {
+	ID             uuid.UUID       `json:"id"`
+	Method         string          `json:"method"`
+	URL            string          `json:"url"`
+	Status         string          `json:"status"`
+	Priority       string          `json:"priority"`
+	Payload        json.RawMessage `json:"payload"`
+	PayloadRef     *string         `json:"payloadRef,omitempty"`
+	IdempotencyKey *string         `json:"idempotencyKey,omitempty"`
+	ReplayedFrom   *uuid.UUID      `json:"replayedFrom,omitempty"`
+	EventType      *string         `json:"eventType,omitempty"`
+	// APIKeyID is the key that sent or replayed the message.
+	APIKeyID         *uuid.UUID        `json:"apiKeyId,omitempty"`
+	AttemptCount     int               `json:"attemptCount"`
+	CreatedAt        time.Time         `json:"createdAt"`
+	UpdatedAt        time.Time         `json:"updatedAt"`
+	NextRetryAt      *time.Time        `json:"nextRetryAt"`
+	DeliveryAttempts []DeliveryAttempt `json:"deliveryAttempts"`
+}
+
+type DeliveryAttempt struct {
+	ID            uuid.UUID `json:"id"`
+	AttemptNumber int       `json:"attemptNumber"`
+	StatusCode    *int      `json:"statusCode"`
+	ResponseBody  *string   `json:"responseBody"`
+	// ResponseTruncated is set when only the start of the body was kept.
+	ResponseTruncated bool      `json:"responseTruncated"`
+	ErrorMessage      *string   `json:"errorMessage"`
+	DurationMS        *int      `json:"durationMs"`
+	AttemptedAt       time.Time `json:"attemptedAt"`
+}
+
+type Endpoint struct {
+	ID              uuid.UUID `json:"id"`
+	OrgID           uuid.UUID `json:"orgId"`
+	URL             string    `json:"url"`
+	ContentEncoding string    `json:"contentEncoding"`
+	Relay           bool      `json:"relay"`
+	// Operational endpoints receive system events such as
+	// message.attempt.exhausted.
+	Operational bool `json:"operational"`
+	// TimeoutMS overrides the platform delivery timeout when set.
+	TimeoutMS *int `json:"timeoutMs"`
+	// DisabledAt is set once the endpoint has been disabled, by hand or
+	// after failing continuously; its messages are held until it is enabled.
+	DisabledAt          *time.Time `json:"disabledAt"`
+	DisabledReason      *string    `json:"disabledReason"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	FailingSince        *time.Time `json:"failingSince"`
+	LastSuccessAt       *time.Time `json:"lastSuccessAt"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	UpdatedAt           time.Time  `json:"updatedAt"`
+}
+
+type EndpointHealth struct {
+	Status              string     `json:"status"` // healthy, failing or disabled
+	SuccessRate         *float64   `json:"successRate"`
+	Attempts            int        `json:"attempts"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastSuccessAt       *time.Time `json:"lastSuccessAt"`
+	FailingSince        *time.Time `json:"failingSince"`
+	DisabledAt          *time.Time `json:"disabledAt"`
+	DisabledReason      *string    `json:"disabledReason"`
+	HeldMessages        int        `json:"heldMessages"`
+	Since               time.Time  `json:"since"`
+}
+
+type EnableEndpointResult struct {
+	Endpoint     Endpoint `json:"endpoint"`
+	HeldMessages int64    `json:"heldMessages"`
+	Replayed     bool     `json:"replayed"`
+}
+
+type CreateEndpointRequest struct {
+	URL             string `json:"url"`
+	ContentEncoding string `json:"contentEncoding,omitempty"` // identity, gzip or zstd
+	// Relay delivers through a `chis listen` session instead of HTTP.
+	Relay       bool `json:"relay,omitempty"`
+	Operational bool `json:"operational,omitempty"`
+	TimeoutMS   *int `json:"timeoutMs,omitempty"`
+}
+
+// UpdateEndpointRequest changes only the fields that are set.
+type UpdateEndpointRequest struct {
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+	Relay           *bool  `json:"relay,omitempty"`
+	Operational     *bool  `json:"operational,omitempty"`
+	// TimeoutMS of 0 goes back to the platform default.
+	TimeoutMS *int `json:"timeoutMs,omitempty"`
+}
@@ -0,0 +1,65 @@
+package helper
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingIdentity = "identity"
+	EncodingGzip     = "gzip"
+	EncodingZstd     = "zstd"
+)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// NewCompressWriter wraps w so that everything written is encoded for the
+// given Content-Encoding. Close flushes the encoder but does not close w.
+func NewCompressWriter(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case "", EncodingIdentity:
+		return nopWriteCloser{w}, nil
+	case EncodingGzip:
+		return gzip.NewWriter(w), nil
+	case EncodingZstd:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("zstd payload: %w", err)
+		}
+		return zw, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
+
+// CompressStream returns a reader that yields r encoded for the given
+// Content-Encoding. Closing the returned reader closes r.
+func CompressStream(encoding string, r io.ReadCloser) (io.ReadCloser, error) {
+	if encoding == "" || encoding == EncodingIdentity {
+		return r, nil
+	}
+
+	pr, pw := io.Pipe()
+	cw, err := NewCompressWriter(encoding, pw)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		_, err := io.Copy(cw, r)
+		if closeErr := cw.Close(); err == nil {
+			err = closeErr
+		}
+		r.Close()
+		pw.CloseWithError(err)
+	}()
+
+	return pr, nil
+}
@@ -63,3 +63,12 @@ func GenerateSecureToken() (string, error) {
 	}
 	return hex.EncodeToString(randomBytes), nil
 }
+
+// PayloadLimit returns the effective payload size limit for an org. An org
+// override can only tighten the platform-wide maximum.
+func PayloadLimit(platformMax int, orgMax *int) int {
+	if orgMax != nil && *orgMax < platformMax {
+		return *orgMax
+	}
+	return platformMax
+}
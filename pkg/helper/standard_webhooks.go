@@ -0,0 +1,91 @@
+package helper
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Signature profiles pick the header names and HMAC key handling used on
+// outgoing webhooks. "standard" follows the Standard Webhooks spec so that
+// its reference verification libraries work unchanged.
+const (
+	SignatureProfileChis     = "chis"
+	SignatureProfileStandard = "standard"
+
+	secretPrefix = "whsec_"
+)
+
+// WebhookHeaders are the header names a signature profile sends.
+type WebhookHeaders struct {
+	ID        string
+	Timestamp string
+	Signature string
+}
+
+var (
+	ChisWebhookHeaders = WebhookHeaders{
+		ID:        "X-Webhook-ID",
+		Timestamp: "X-Webhook-Timestamp",
+		Signature: "X-Webhook-Signature",
+	}
+	StandardWebhookHeaders = WebhookHeaders{
+		ID:        "webhook-id",
+		Timestamp: "webhook-timestamp",
+		Signature: "webhook-signature",
+	}
+)
+
+func HeadersForProfile(profile string) WebhookHeaders {
+	if profile == SignatureProfileStandard {
+		return StandardWebhookHeaders
+	}
+	return ChisWebhookHeaders
+}
+
+// SigningSecretKey returns the raw HMAC key behind a "whsec_<hex>" signing
+// secret. Standard Webhooks signs with these bytes rather than the string.
+func SigningSecretKey(secret string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimPrefix(secret, secretPrefix))
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("invalid signing secret")
+	}
+	return key, nil
+}
+
+// StandardSigningSecret re-encodes a "whsec_<hex>" secret as the
+// "whsec_<base64>" form Standard Webhooks libraries expect.
+func StandardSigningSecret(secret string) (string, error) {
+	key, err := SigningSecretKey(secret)
+	if err != nil {
+		return "", err
+	}
+	return secretPrefix + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// StandardHMACSigner signs with the decoded key as the spec requires.
+type StandardHMACSigner struct {
+	Key []byte
+}
+
+func NewStandardHMACSigner(secret string) (StandardHMACSigner, error) {
+	key, err := SigningSecretKey(secret)
+	if err != nil {
+		return StandardHMACSigner{}, err
+	}
+	return StandardHMACSigner{Key: key}, nil
+}
+
+func (s StandardHMACSigner) Sign(msgID string, timestamp int64, body io.Reader) (string, error) {
+	mac := hmac.New(sha256.New, s.Key)
+	fmt.Fprintf(mac, "%s.%d.", msgID, timestamp)
+	if _, err := io.Copy(mac, body); err != nil {
+		return "", fmt.Errorf("read payload: %w", err)
+	}
+	return "v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
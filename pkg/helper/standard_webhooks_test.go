@@ -0,0 +1,71 @@
+package helper
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// The signing example from the Standard Webhooks spec, which its reference
+// libraries are tested against.
+const (
+	specSecret    = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+	specMsgID     = "msg_p5jXN8AQM9LWM0D4loKWxJek"
+	specTimestamp = 1614265330
+	specBody      = `{"test": 2432232314}`
+	specSignature = "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="
+)
+
+func specKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(specSecret, "whsec_"))
+	if err != nil {
+		t.Fatalf("decode spec secret: %v", err)
+	}
+	return key
+}
+
+func TestStandardHMACSignerSpecVector(t *testing.T) {
+	sig, err := StandardHMACSigner{Key: specKey(t)}.Sign(specMsgID, specTimestamp, strings.NewReader(specBody))
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if sig != specSignature {
+		t.Errorf("Sign = %q, want %q", sig, specSignature)
+	}
+}
+
+// Secrets are stored as "whsec_<hex>"; signing with one must match the spec
+// once it is re-encoded in the form the reference libraries take.
+func TestStandardSigningSecretSpecVector(t *testing.T) {
+	secret := "whsec_" + hex.EncodeToString(specKey(t))
+
+	standard, err := StandardSigningSecret(secret)
+	if err != nil {
+		t.Fatalf("StandardSigningSecret: %v", err)
+	}
+	if standard != specSecret {
+		t.Errorf("StandardSigningSecret = %q, want %q", standard, specSecret)
+	}
+
+	signer, err := NewStandardHMACSigner(secret)
+	if err != nil {
+		t.Fatalf("NewStandardHMACSigner: %v", err)
+	}
+	sig, err := signer.Sign(specMsgID, specTimestamp, strings.NewReader(specBody))
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if sig != specSignature {
+		t.Errorf("Sign = %q, want %q", sig, specSignature)
+	}
+}
+
+func TestNewStandardHMACSignerRejectsInvalidSecret(t *testing.T) {
+	for _, secret := range []string{"", "whsec_", "whsec_not-hex"} {
+		if _, err := NewStandardHMACSigner(secret); err == nil {
+			t.Errorf("NewStandardHMACSigner(%q) succeeded, want an error", secret)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"time"
 )
 
@@ -31,3 +32,20 @@ func SignWebhookPayloadWithTimestamp(msgID, secret string, body []byte, timestam
 		Signature: "v1," + sig,
 	}
 }
+
+// SignWebhookStream signs a payload read from body without holding it in
+// memory. It produces the same signature as SignWebhookPayloadWithTimestamp.
+func SignWebhookStream(msgID, secret string, body io.Reader, timestamp int64) (*WebhookSignature, error) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s.%d.", msgID, timestamp)
+	if _, err := io.Copy(mac, body); err != nil {
+		return nil, fmt.Errorf("read payload: %w", err)
+	}
+	sig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return &WebhookSignature{
+		MessageID: msgID,
+		Timestamp: timestamp,
+		Signature: "v1," + sig,
+	}, nil
+}
@@ -0,0 +1,75 @@
+package webhookverify
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultMaxBodyBytes caps how much of a request Middleware reads.
+const DefaultMaxBodyBytes = 10 << 20
+
+// Middleware rejects requests whose signature does not verify with 401.
+// Verified requests reach next with the decoded body and Content-Encoding
+// removed, so handlers can read the payload as sent.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := readBody(r)
+		if err != nil {
+			http.Error(w, "invalid webhook body", http.StatusBadRequest)
+			return
+		}
+
+		if err := v.Verify(r.Header, body); err != nil {
+			http.Error(w, "invalid webhook signature", http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		r.Header.Del("Content-Encoding")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readBody reads the request body, undoing the gzip or zstd encoding
+// endpoints can opt into. Signatures always cover the decoded payload.
+func readBody(r *http.Request) ([]byte, error) {
+	raw := io.LimitReader(r.Body, DefaultMaxBodyBytes+1)
+	defer r.Body.Close()
+
+	var body io.Reader
+	switch encoding := r.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+		body = raw
+	case "gzip":
+		gr, err := gzip.NewReader(raw)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		body = gr
+	case "zstd":
+		zr, err := zstd.NewReader(raw)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		body = zr
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(body, DefaultMaxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > DefaultMaxBodyBytes {
+		return nil, fmt.Errorf("body exceeds %d bytes", DefaultMaxBodyBytes)
+	}
+	return payload, nil
+}
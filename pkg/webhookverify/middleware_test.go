@@ -0,0 +1,102 @@
+package webhookverify_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/pkg/helper"
+	"github.com/bilalabdelkadir/chis/pkg/webhookverify"
+	"github.com/klauspost/compress/zstd"
+)
+
+func gzipped(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(data))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func zstded(t *testing.T, data string) []byte {
+	t.Helper()
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("zstd: %v", err)
+	}
+	defer zw.Close()
+	return zw.EncodeAll([]byte(data), nil)
+}
+
+func TestMiddleware(t *testing.T) {
+	secret := newSecret(t)
+	v, err := webhookverify.New(webhookverify.WithSecret(secret))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	var got []byte
+	var encoding string
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = io.ReadAll(r.Body)
+		encoding = r.Header.Get("Content-Encoding")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	now := time.Now().Unix()
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		headers  http.Header
+		want     int
+	}{
+		{"identity", "", []byte(body), signChis(t, now, helper.HMACSigner{Secret: secret}), http.StatusNoContent},
+		{"gzip", "gzip", gzipped(t, body), signChis(t, now, helper.HMACSigner{Secret: secret}), http.StatusNoContent},
+		{"zstd", "zstd", zstded(t, body), signChis(t, now, helper.HMACSigner{Secret: secret}), http.StatusNoContent},
+		{"bad signature", "gzip", gzipped(t, body), signChis(t, now, helper.HMACSigner{Secret: newSecret(t)}),
+			http.StatusUnauthorized},
+		{"stale", "", []byte(body), signChis(t, now-3600, helper.HMACSigner{Secret: secret}), http.StatusUnauthorized},
+		{"unsigned", "", []byte(body), http.Header{}, http.StatusUnauthorized},
+		{"corrupt gzip", "gzip", []byte(body), signChis(t, now, helper.HMACSigner{Secret: secret}), http.StatusBadRequest},
+		{"unknown encoding", "br", []byte(body), signChis(t, now, helper.HMACSigner{Secret: secret}), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, encoding = nil, ""
+			r := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(tt.body))
+			for name, values := range tt.headers {
+				r.Header[name] = values
+			}
+			if tt.encoding != "" {
+				r.Header.Set("Content-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.want != http.StatusNoContent {
+				if got != nil {
+					t.Error("handler ran for a rejected request")
+				}
+				return
+			}
+			if string(got) != body {
+				t.Errorf("handler read %q, want %q", got, body)
+			}
+			if encoding != "" {
+				t.Errorf("Content-Encoding %q was left on the request", encoding)
+			}
+		})
+	}
+}
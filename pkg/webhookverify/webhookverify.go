@@ -0,0 +1,194 @@
+// Package webhookverify checks the signatures on webhooks delivered by Chis.
+//
+// It accepts both header profiles the server can send (X-Webhook-* and the
+// Standard Webhooks webhook-* headers), HMAC signing secrets in either
+// "whsec_" form, and Ed25519 public keys for the asymmetric scheme.
+// Deliveries under either profile verify with either form of the secret.
+package webhookverify
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTolerance is how far a webhook timestamp may drift from the
+// receiver's clock before it is rejected as a replay.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrMissingHeaders   = errors.New("webhookverify: missing webhook headers")
+	ErrInvalidTimestamp = errors.New("webhookverify: invalid timestamp")
+	ErrTimestampTooOld  = errors.New("webhookverify: timestamp too old")
+	ErrTimestampTooNew  = errors.New("webhookverify: timestamp too new")
+	ErrNoMatch          = errors.New("webhookverify: no matching signature")
+)
+
+type headerNames struct {
+	id, timestamp, signature string
+	// standard profiles sign with the decoded key, ours with the secret string.
+	standard bool
+}
+
+var profiles = []headerNames{
+	{"webhook-id", "webhook-timestamp", "webhook-signature", true},
+	{"X-Webhook-ID", "X-Webhook-Timestamp", "X-Webhook-Signature", false},
+}
+
+type Verifier struct {
+	keys       [][]byte
+	publicKeys []ed25519.PublicKey
+	tolerance  time.Duration
+	now        func() time.Time
+}
+
+type Option func(*Verifier) error
+
+// WithSecret adds an HMAC signing secret in the "whsec_<hex>" form the Chis
+// dashboard shows. Add both the old and new secret while rolling out a
+// rotation.
+func WithSecret(secret string) Option {
+	return func(v *Verifier) error {
+		key, err := hex.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+		if err != nil || len(key) == 0 {
+			return errors.New("webhookverify: invalid signing secret, use WithStandardSecret for the base64 form")
+		}
+		v.keys = append(v.keys, key)
+		return nil
+	}
+}
+
+// WithStandardSecret adds an HMAC signing secret in the "whsec_<base64>"
+// form Standard Webhooks libraries use.
+func WithStandardSecret(secret string) Option {
+	return func(v *Verifier) error {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+		if err != nil || len(key) == 0 {
+			return errors.New("webhookverify: invalid standard signing secret")
+		}
+		v.keys = append(v.keys, key)
+		return nil
+	}
+}
+
+// WithPublicKey adds an Ed25519 public key ("whpk_<base64>") published at
+// /public/orgs/{orgId}/signing-keys.
+func WithPublicKey(publicKey string) Option {
+	return func(v *Verifier) error {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(publicKey, "whpk_"))
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return errors.New("webhookverify: invalid ed25519 public key")
+		}
+		v.publicKeys = append(v.publicKeys, ed25519.PublicKey(key))
+		return nil
+	}
+}
+
+// WithTolerance overrides DefaultTolerance.
+func WithTolerance(d time.Duration) Option {
+	return func(v *Verifier) error {
+		v.tolerance = d
+		return nil
+	}
+}
+
+func New(opts ...Option) (*Verifier, error) {
+	v := &Verifier{
+		tolerance: DefaultTolerance,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		if err := opt(v); err != nil {
+			return nil, err
+		}
+	}
+	if len(v.keys) == 0 && len(v.publicKeys) == 0 {
+		return nil, errors.New("webhookverify: no secret or public key configured")
+	}
+	return v, nil
+}
+
+// Verify checks a webhook signed with a single HMAC secret.
+func Verify(secret string, headers http.Header, body []byte) error {
+	v, err := New(WithSecret(secret))
+	if err != nil {
+		return err
+	}
+	return v.Verify(headers, body)
+}
+
+// Verify checks the headers of a delivery against its decoded body. The
+// body must be the payload after any Content-Encoding has been reversed.
+// It succeeds if any signature in the header matches any configured key.
+func (v *Verifier) Verify(headers http.Header, body []byte) error {
+	names, ok := detectProfile(headers)
+	if !ok {
+		return ErrMissingHeaders
+	}
+	msgID := headers.Get(names.id)
+
+	timestamp, err := strconv.ParseInt(headers.Get(names.timestamp), 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	sent := time.Unix(timestamp, 0)
+	now := v.now()
+	if now.Sub(sent) > v.tolerance {
+		return ErrTimestampTooOld
+	}
+	if sent.Sub(now) > v.tolerance {
+		return ErrTimestampTooNew
+	}
+
+	signedContent := []byte(fmt.Sprintf("%s.%d.%s", msgID, timestamp, body))
+
+	for _, entry := range strings.Fields(headers.Get(names.signature)) {
+		version, encoded, found := strings.Cut(entry, ",")
+		if !found {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+
+		switch version {
+		case "v1":
+			for _, key := range v.keys {
+				if !names.standard {
+					key = []byte("whsec_" + hex.EncodeToString(key))
+				}
+				mac := hmac.New(sha256.New, key)
+				mac.Write(signedContent)
+				if hmac.Equal(mac.Sum(nil), sig) {
+					return nil
+				}
+			}
+		case "v1a":
+			for _, key := range v.publicKeys {
+				if ed25519.Verify(key, signedContent, sig) {
+					return nil
+				}
+			}
+		}
+	}
+
+	return ErrNoMatch
+}
+
+func detectProfile(headers http.Header) (headerNames, bool) {
+	for _, names := range profiles {
+		if headers.Get(names.id) != "" && headers.Get(names.timestamp) != "" && headers.Get(names.signature) != "" {
+			return names, true
+		}
+	}
+	return headerNames{}, false
+}
@@ -0,0 +1,268 @@
+package webhookverify_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bilalabdelkadir/chis/pkg/helper"
+	"github.com/bilalabdelkadir/chis/pkg/webhookverify"
+)
+
+const body = `{"type":"order.created","data":{"id":"ord_123"}}`
+
+// signStandard signs body the way the worker does for orgs on the standard
+// profile and returns the headers it would send.
+func signStandard(t *testing.T, secret string, timestamp int64) http.Header {
+	t.Helper()
+	signer, err := helper.NewStandardHMACSigner(secret)
+	if err != nil {
+		t.Fatalf("NewStandardHMACSigner: %v", err)
+	}
+	open := func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader([]byte(body))), nil }
+	sig, err := helper.SignWebhook("msg_1", timestamp, open, signer)
+	if err != nil {
+		t.Fatalf("SignWebhook: %v", err)
+	}
+
+	names := helper.HeadersForProfile(helper.SignatureProfileStandard)
+	headers := http.Header{}
+	headers.Set(names.ID, "msg_1")
+	headers.Set(names.Timestamp, strconv.FormatInt(timestamp, 10))
+	headers.Set(names.Signature, sig)
+	return headers
+}
+
+func TestVerifyStandardProfileRoundTrip(t *testing.T) {
+	secret, err := helper.GenerateSigningSecret()
+	if err != nil {
+		t.Fatalf("GenerateSigningSecret: %v", err)
+	}
+	standard, err := helper.StandardSigningSecret(secret)
+	if err != nil {
+		t.Fatalf("StandardSigningSecret: %v", err)
+	}
+	other, err := helper.GenerateSigningSecret()
+	if err != nil {
+		t.Fatalf("GenerateSigningSecret: %v", err)
+	}
+
+	now := time.Now().Unix()
+	tests := []struct {
+		name    string
+		secret  webhookverify.Option
+		headers http.Header
+		body    string
+		want    error
+	}{
+		{"dashboard secret", webhookverify.WithSecret(secret), signStandard(t, secret, now), body, nil},
+		{"standard secret", webhookverify.WithStandardSecret(standard), signStandard(t, secret, now), body, nil},
+		{"other secret", webhookverify.WithSecret(other), signStandard(t, secret, now), body, webhookverify.ErrNoMatch},
+		{"tampered body", webhookverify.WithSecret(secret), signStandard(t, secret, now), body + " ", webhookverify.ErrNoMatch},
+		{"stale timestamp", webhookverify.WithSecret(secret), signStandard(t, secret, now-3600), body, webhookverify.ErrTimestampTooOld},
+		{"missing headers", webhookverify.WithSecret(secret), http.Header{}, body, webhookverify.ErrMissingHeaders},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := webhookverify.New(tt.secret)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if err := v.Verify(tt.headers, []byte(tt.body)); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyStandardSpecVector(t *testing.T) {
+	v, err := webhookverify.New(
+		webhookverify.WithStandardSecret("whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"),
+		// The vector's timestamp is from 2021.
+		webhookverify.WithTolerance(100*365*24*time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	headers := http.Header{}
+	headers.Set("webhook-id", "msg_p5jXN8AQM9LWM0D4loKWxJek")
+	headers.Set("webhook-timestamp", "1614265330")
+	headers.Set("webhook-signature", "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE=")
+	if err := v.Verify(headers, []byte(`{"test": 2432232314}`)); err != nil {
+		t.Errorf("Verify = %v, want nil", err)
+	}
+}
+
+// signChis signs body the way the worker does for orgs on the default
+// profile, with every signer given, and returns the headers it would send.
+func signChis(t *testing.T, timestamp int64, signers ...helper.WebhookSigner) http.Header {
+	t.Helper()
+	open := func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader([]byte(body))), nil }
+	sig, err := helper.SignWebhook("msg_1", timestamp, open, signers...)
+	if err != nil {
+		t.Fatalf("SignWebhook: %v", err)
+	}
+
+	headers := http.Header{}
+	headers.Set(helper.ChisWebhookHeaders.ID, "msg_1")
+	headers.Set(helper.ChisWebhookHeaders.Timestamp, strconv.FormatInt(timestamp, 10))
+	headers.Set(helper.ChisWebhookHeaders.Signature, sig)
+	return headers
+}
+
+func newSecret(t *testing.T) string {
+	t.Helper()
+	secret, err := helper.GenerateSigningSecret()
+	if err != nil {
+		t.Fatalf("GenerateSigningSecret: %v", err)
+	}
+	return secret
+}
+
+func newKeyPair(t *testing.T) (string, helper.Ed25519Signer) {
+	t.Helper()
+	pub, priv, err := helper.GenerateEd25519KeyPair()
+	if err != nil {
+		t.Fatalf("GenerateEd25519KeyPair: %v", err)
+	}
+	key, err := helper.ParseEd25519PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("ParseEd25519PrivateKey: %v", err)
+	}
+	return pub, helper.Ed25519Signer{PrivateKey: key}
+}
+
+func TestVerifyChisProfile(t *testing.T) {
+	secret, other := newSecret(t), newSecret(t)
+	standard, err := helper.StandardSigningSecret(secret)
+	if err != nil {
+		t.Fatalf("StandardSigningSecret: %v", err)
+	}
+	pub, signer := newKeyPair(t)
+	otherPub, _ := newKeyPair(t)
+
+	now := time.Now().Unix()
+	hmacSigner := helper.HMACSigner{Secret: secret}
+	tests := []struct {
+		name    string
+		opts    []webhookverify.Option
+		headers http.Header
+		want    error
+	}{
+		{"hmac", []webhookverify.Option{webhookverify.WithSecret(secret)}, signChis(t, now, hmacSigner), nil},
+		{"hmac with standard secret", []webhookverify.Option{webhookverify.WithStandardSecret(standard)},
+			signChis(t, now, hmacSigner), nil},
+		{"hmac with other secret", []webhookverify.Option{webhookverify.WithSecret(other)},
+			signChis(t, now, hmacSigner), webhookverify.ErrNoMatch},
+		{"rotation", []webhookverify.Option{webhookverify.WithSecret(other), webhookverify.WithSecret(secret)},
+			signChis(t, now, hmacSigner), nil},
+		{"ed25519", []webhookverify.Option{webhookverify.WithPublicKey(pub)}, signChis(t, now, signer), nil},
+		{"ed25519 with other key", []webhookverify.Option{webhookverify.WithPublicKey(otherPub)},
+			signChis(t, now, signer), webhookverify.ErrNoMatch},
+		{"ed25519 key for hmac signature", []webhookverify.Option{webhookverify.WithPublicKey(pub)},
+			signChis(t, now, hmacSigner), webhookverify.ErrNoMatch},
+		{"both schemes, hmac secret", []webhookverify.Option{webhookverify.WithSecret(secret)},
+			signChis(t, now, hmacSigner, signer), nil},
+		{"both schemes, public key", []webhookverify.Option{webhookverify.WithPublicKey(pub)},
+			signChis(t, now, hmacSigner, signer), nil},
+		{"both schemes, neither", []webhookverify.Option{webhookverify.WithSecret(other), webhookverify.WithPublicKey(otherPub)},
+			signChis(t, now, hmacSigner, signer), webhookverify.ErrNoMatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := webhookverify.New(tt.opts...)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if err := v.Verify(tt.headers, []byte(body)); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySkipsMalformedSignatures(t *testing.T) {
+	secret := newSecret(t)
+	headers := signChis(t, time.Now().Unix(), helper.HMACSigner{Secret: secret})
+	sig := headers.Get(helper.ChisWebhookHeaders.Signature)
+	headers.Set(helper.ChisWebhookHeaders.Signature, "v2,abc v1 v1,!!! "+sig)
+
+	v, err := webhookverify.New(webhookverify.WithSecret(secret))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := v.Verify(headers, []byte(body)); err != nil {
+		t.Errorf("Verify = %v, want nil", err)
+	}
+}
+
+func TestVerifyTolerance(t *testing.T) {
+	secret := newSecret(t)
+	v, err := webhookverify.New(webhookverify.WithSecret(secret), webhookverify.WithTolerance(time.Minute))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	now := time.Now().Unix()
+	tests := []struct {
+		name    string
+		headers http.Header
+		want    error
+	}{
+		{"within tolerance", signChis(t, now-30, helper.HMACSigner{Secret: secret}), nil},
+		{"ahead within tolerance", signChis(t, now+30, helper.HMACSigner{Secret: secret}), nil},
+		{"too old", signChis(t, now-120, helper.HMACSigner{Secret: secret}), webhookverify.ErrTimestampTooOld},
+		{"too new", signChis(t, now+120, helper.HMACSigner{Secret: secret}), webhookverify.ErrTimestampTooNew},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := v.Verify(tt.headers, []byte(body)); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	headers := signChis(t, now, helper.HMACSigner{Secret: secret})
+	headers.Set(helper.ChisWebhookHeaders.Timestamp, "yesterday")
+	if err := v.Verify(headers, []byte(body)); !errors.Is(err, webhookverify.ErrInvalidTimestamp) {
+		t.Errorf("Verify = %v, want ErrInvalidTimestamp", err)
+	}
+}
+
+func TestSecretForms(t *testing.T) {
+	// Only hex digits, but the base64 form of 24 bytes rather than hex.
+	const standard = "whsec_0123456789abcdef0123456789abcdef"
+	key, _ := base64.StdEncoding.DecodeString(standard[len("whsec_"):])
+	now := time.Now().Unix()
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "msg_1.%d.%s", now, body)
+
+	headers := http.Header{}
+	headers.Set("webhook-id", "msg_1")
+	headers.Set("webhook-timestamp", strconv.FormatInt(now, 10))
+	headers.Set("webhook-signature", "v1,"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+	v, err := webhookverify.New(webhookverify.WithStandardSecret(standard))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := v.Verify(headers, []byte(body)); err != nil {
+		t.Errorf("Verify = %v, want nil", err)
+	}
+
+	if _, err := webhookverify.New(webhookverify.WithSecret("whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw")); err == nil {
+		t.Error("WithSecret accepted a base64 secret")
+	}
+	if _, err := webhookverify.New(webhookverify.WithStandardSecret("whsec_not base64")); err == nil {
+		t.Error("WithStandardSecret accepted an invalid secret")
+	}
+}
@@ -77,11 +77,19 @@ func (HttpMethod) EnumDescriptor() ([]byte, []int) {
 }
 
 type QueueMessageRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
-	Method        HttpMethod             `protobuf:"varint,2,opt,name=method,proto3,enum=delivery.v1.HttpMethod" json:"method,omitempty"`
-	Payload       []byte                 `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
-	OrgId         string                 `protobuf:"bytes,4,opt,name=org_id,json=orgId,proto3" json:"org_id,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Url     string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Method  HttpMethod             `protobuf:"varint,2,opt,name=method,proto3,enum=delivery.v1.HttpMethod" json:"method,omitempty"`
+	Payload []byte                 `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+	OrgId   string                 `protobuf:"bytes,4,opt,name=org_id,json=orgId,proto3" json:"org_id,omitempty"`
+	// Requests repeating an org's idempotency key return the original message.
+	IdempotencyKey string `protobuf:"bytes,5,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	// One of high, normal or low; empty means normal.
+	Priority string `protobuf:"bytes,6,opt,name=priority,proto3" json:"priority,omitempty"`
+	// The API key that sent the message, if any.
+	ApiKeyId string `protobuf:"bytes,7,opt,name=api_key_id,json=apiKeyId,proto3" json:"api_key_id,omitempty"`
+	// What kind of event the payload describes, e.g. order.created.
+	EventType     string `protobuf:"bytes,8,opt,name=event_type,json=eventType,proto3" json:"event_type,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -144,6 +152,34 @@ func (x *QueueMessageRequest) GetOrgId() string {
 	return ""
 }
 
+func (x *QueueMessageRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+func (x *QueueMessageRequest) GetPriority() string {
+	if x != nil {
+		return x.Priority
+	}
+	return ""
+}
+
+func (x *QueueMessageRequest) GetApiKeyId() string {
+	if x != nil {
+		return x.ApiKeyId
+	}
+	return ""
+}
+
+func (x *QueueMessageRequest) GetEventType() string {
+	if x != nil {
+		return x.EventType
+	}
+	return ""
+}
+
 type QueueMessageResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
@@ -200,12 +236,18 @@ var File_proto_delivery_delivery_proto protoreflect.FileDescriptor
 
 const file_proto_delivery_delivery_proto_rawDesc = "" +
 	"\n" +
-	"\x1dproto/delivery/delivery.proto\x12\vdelivery.v1\"\x89\x01\n" +
+	"\x1dproto/delivery/delivery.proto\x12\vdelivery.v1\"\x8b\x02\n" +
 	"\x13QueueMessageRequest\x12\x10\n" +
 	"\x03url\x18\x01 \x01(\tR\x03url\x12/\n" +
 	"\x06method\x18\x02 \x01(\x0e2\x17.delivery.v1.HttpMethodR\x06method\x12\x18\n" +
 	"\apayload\x18\x03 \x01(\fR\apayload\x12\x15\n" +
-	"\x06org_id\x18\x04 \x01(\tR\x05orgId\"M\n" +
+	"\x06org_id\x18\x04 \x01(\tR\x05orgId\x12'\n" +
+	"\x0fidempotency_key\x18\x05 \x01(\tR\x0eidempotencyKey\x12\x1a\n" +
+	"\bpriority\x18\x06 \x01(\tR\bpriority\x12\x1c\n" +
+	"\n" +
+	"api_key_id\x18\a \x01(\tR\bapiKeyId\x12\x1d\n" +
+	"\n" +
+	"event_type\x18\b \x01(\tR\teventType\"M\n" +
 	"\x14QueueMessageResponse\x12\x1d\n" +
 	"\n" +
 	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x16\n" +
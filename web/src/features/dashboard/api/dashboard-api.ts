@@ -1,4 +1,4 @@
-import { apiRequest } from "@/shared/api/api-client";
+import { apiRequest, authHeaders } from "@/shared/api/api-client";
 import type {
   DashboardStats,
   ApiKey,
@@ -6,6 +6,7 @@ import type {
   CreateApiKeyResponse,
   WebhookLog,
   WebhookLogDetail,
+  WebhookLogEvent,
   WebhookLogsParams,
   PaginatedResponse,
   SendWebhookRequest,
@@ -20,12 +21,13 @@ export function fetchDashboardStats(): Promise<DashboardStats> {
   });
 }
 
-export function fetchApiKeys(): Promise<ApiKey[]> {
-  return apiRequest<ApiKey[]>({
+export async function fetchApiKeys(): Promise<ApiKey[]> {
+  const response = await apiRequest<PaginatedResponse<ApiKey>>({
     method: "GET",
-    path: "/api/api-key/list",
+    path: "/api/api-key/list?limit=100",
     auth: true,
   });
+  return response.data;
 }
 
 export function createApiKey(
@@ -61,7 +63,7 @@ export function fetchWebhookLogs(
   const searchParams = new URLSearchParams();
   if (params?.status) searchParams.set("status", params.status);
   if (params?.search) searchParams.set("search", params.search);
-  if (params?.page) searchParams.set("page", String(params.page));
+  if (params?.cursor) searchParams.set("cursor", params.cursor);
   if (params?.limit) searchParams.set("limit", String(params.limit));
 
   const query = searchParams.toString();
@@ -76,6 +78,42 @@ export function fetchWebhookLogs(
 
 const BASE_URL = import.meta.env.VITE_API_URL ?? "";
 
+// streamWebhookLogEvents calls onEvent with each log event until signal is
+// aborted or the connection drops. EventSource cannot send the auth
+// headers, so the stream is read with fetch.
+export async function streamWebhookLogEvents(
+  onEvent: (event: WebhookLogEvent) => void,
+  signal: AbortSignal,
+): Promise<void> {
+  const response = await fetch(`${BASE_URL}/api/webhook-logs/stream`, {
+    headers: { Accept: "text/event-stream", ...authHeaders() },
+    signal,
+  });
+  if (!response.ok || !response.body) {
+    throw new Error(`Log stream failed with status ${response.status}`);
+  }
+
+  const reader = response.body.pipeThrough(new TextDecoderStream()).getReader();
+  let buffer = "";
+  for (;;) {
+    const { value, done } = await reader.read();
+    if (done) return;
+    buffer += value;
+
+    let end: number;
+    while ((end = buffer.indexOf("\n\n")) !== -1) {
+      const block = buffer.slice(0, end);
+      buffer = buffer.slice(end + 2);
+      const data = block
+        .split("\n")
+        .filter((line) => line.startsWith("data: "))
+        .map((line) => line.slice("data: ".length))
+        .join("\n");
+      if (data) onEvent(JSON.parse(data) as WebhookLogEvent);
+    }
+  }
+}
+
 export async function sendTestWebhook(
   apiKey: string,
   request: SendWebhookRequest,
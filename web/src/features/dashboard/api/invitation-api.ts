@@ -5,13 +5,15 @@ import type {
   AcceptInvitationResponse,
   PendingInvitation,
 } from "../types/invitation.types";
+import type { PaginatedResponse } from "../types/dashboard.types";
 
-export function fetchInvitations(): Promise<Invitation[]> {
-  return apiRequest<Invitation[]>({
+export async function fetchInvitations(): Promise<Invitation[]> {
+  const response = await apiRequest<PaginatedResponse<Invitation>>({
     method: "GET",
-    path: "/api/invitations/",
+    path: "/api/invitations/?limit=100",
     auth: true,
   });
+  return response.data;
 }
 
 export function createInvitation(
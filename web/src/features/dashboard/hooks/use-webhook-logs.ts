@@ -1,19 +1,29 @@
 import { useEffect, useState, useRef } from "react";
 import { ApiRequestError } from "@/shared/api/api-error";
 import { useOrg } from "@/shared/context/org-context";
-import { fetchWebhookLogs } from "../api/dashboard-api";
-import type { WebhookLog } from "../types/dashboard.types";
+import { fetchWebhookLogs, streamWebhookLogEvents } from "../api/dashboard-api";
+import type { WebhookLog, WebhookLogEvent } from "../types/dashboard.types";
+
+// How long to wait before reconnecting a dropped live stream.
+const LIVE_RETRY_MS = 5000;
 
 export function useWebhookLogs() {
   const { currentOrg } = useOrg();
   const [logs, setLogs] = useState<WebhookLog[]>([]);
   const [isLoading, setIsLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
-  const [page, setPage] = useState(1);
-  const [totalPages, setTotalPages] = useState(1);
+  // cursors[i] fetches page i + 1; the first page needs none.
+  const [cursors, setCursors] = useState<string[]>([""]);
+  const [nextCursor, setNextCursor] = useState<string | null>(null);
+  const page = cursors.length;
   const [statusFilter, setStatusFilter] = useState<string>("");
   const [search, setSearch] = useState("");
   const [debouncedSearch, setDebouncedSearch] = useState("");
+  const [isLive, setIsLive] = useState(false);
+  // Bumped to reload the current page without showing the loading state.
+  const [refreshKey, setRefreshKey] = useState(0);
+  const refreshTimer = useRef<ReturnType<typeof setTimeout> | null>(null);
+  const logsRef = useRef<WebhookLog[]>([]);
 
   // Debounce search input
   useEffect(() => {
@@ -30,29 +40,33 @@ export function useWebhookLogs() {
       isFirstRender.current = false;
       return;
     }
-    setPage(1);
+    setCursors((c) => (c.length === 1 ? c : [""]));
   }, [statusFilter, debouncedSearch]);
 
   // Fetch logs
+  const lastQuery = useRef("");
   useEffect(() => {
     if (!currentOrg) return;
 
     let cancelled = false;
+    const query = JSON.stringify([cursors, statusFilter, debouncedSearch, currentOrg.id]);
+    const isRefresh = query === lastQuery.current;
+    lastQuery.current = query;
 
     async function load() {
-      setIsLoading(true);
+      if (!isRefresh) setIsLoading(true);
       setError(null);
 
       try {
         const response = await fetchWebhookLogs({
-          page,
+          cursor: cursors[cursors.length - 1] || undefined,
           limit: 20,
           status: statusFilter || undefined,
           search: debouncedSearch || undefined,
         });
         if (!cancelled) {
           setLogs(response.data);
-          setTotalPages(response.totalPages);
+          setNextCursor(response.nextCursor);
         }
       } catch (err) {
         if (!cancelled) {
@@ -71,10 +85,74 @@ export function useWebhookLogs() {
     return () => {
       cancelled = true;
     };
-  }, [page, statusFilter, debouncedSearch, currentOrg?.id]);
+  }, [cursors, statusFilter, debouncedSearch, currentOrg?.id, refreshKey]);
+
+  useEffect(() => {
+    logsRef.current = logs;
+  }, [logs]);
+
+  // Live updates: rows on screen are updated in place, and new messages
+  // reload the first page, at most once a second.
+  useEffect(() => {
+    if (!currentOrg) return;
+
+    const controller = new AbortController();
+    let retry: ReturnType<typeof setTimeout> | undefined;
+
+    function onEvent(event: WebhookLogEvent) {
+      const known = logsRef.current.some((log) => log.id === event.messageId);
+      if (!known) {
+        if (page === 1 && !refreshTimer.current) {
+          refreshTimer.current = setTimeout(() => {
+            refreshTimer.current = null;
+            setRefreshKey((k) => k + 1);
+          }, 1000);
+        }
+        return;
+      }
+      setLogs((current) =>
+        current.map((log) => {
+          if (log.id !== event.messageId) return log;
+          const updated = { ...log, status: event.status as WebhookLog["status"] };
+          if (event.attempt) {
+            updated.statusCode = event.attempt.statusCode ?? 0;
+            updated.responseTimeMs = event.attempt.durationMs ?? 0;
+            updated.attemptedAt = event.timestamp;
+          }
+          return updated;
+        }),
+      );
+    }
+
+    function connect() {
+      streamWebhookLogEvents(onEvent, controller.signal)
+        .catch(() => undefined)
+        .finally(() => {
+          setIsLive(false);
+          if (!controller.signal.aborted) {
+            retry = setTimeout(connect, LIVE_RETRY_MS);
+          }
+        });
+      setIsLive(true);
+    }
+
+    connect();
+    return () => {
+      controller.abort();
+      clearTimeout(retry);
+      if (refreshTimer.current) {
+        clearTimeout(refreshTimer.current);
+        refreshTimer.current = null;
+      }
+    };
+  }, [currentOrg?.id, page]);
+
+  function nextPage() {
+    if (nextCursor) setCursors((c) => [...c, nextCursor]);
+  }
 
-  function goToPage(p: number) {
-    setPage(p);
+  function previousPage() {
+    setCursors((c) => (c.length > 1 ? c.slice(0, -1) : c));
   }
 
   return {
@@ -82,8 +160,10 @@ export function useWebhookLogs() {
     isLoading,
     error,
     page,
-    totalPages,
-    goToPage,
+    hasNextPage: nextCursor !== null,
+    isLive,
+    nextPage,
+    previousPage,
     statusFilter,
     setStatusFilter,
     search,
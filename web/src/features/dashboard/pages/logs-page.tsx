@@ -38,8 +38,10 @@ export function LogsPage() {
     isLoading,
     error,
     page,
-    totalPages,
-    goToPage,
+    hasNextPage,
+    isLive,
+    nextPage,
+    previousPage,
     statusFilter,
     setStatusFilter,
     search,
@@ -93,6 +95,12 @@ export function LogsPage() {
               <SelectItem value="pending">Pending</SelectItem>
             </SelectContent>
           </Select>
+          {isLive && (
+            <span className="ml-auto flex items-center gap-2 text-xs text-muted-foreground">
+              <span className="size-2 rounded-full bg-green-500 animate-pulse" />
+              Live
+            </span>
+          )}
         </div>
 
         <Card className="border-border/80 overflow-hidden">
@@ -153,25 +161,23 @@ export function LogsPage() {
           </CardContent>
         </Card>
 
-        {totalPages > 1 && (
+        {(page > 1 || hasNextPage) && (
           <div className="flex items-center justify-between mt-4">
-            <p className="text-sm text-muted-foreground">
-              Page {page} of {totalPages}
-            </p>
+            <p className="text-sm text-muted-foreground">Page {page}</p>
             <div className="flex items-center gap-2">
               <Button
                 variant="outline"
                 size="sm"
                 disabled={page <= 1}
-                onClick={() => goToPage(page - 1)}
+                onClick={previousPage}
               >
                 Previous
               </Button>
               <Button
                 variant="outline"
                 size="sm"
-                disabled={page >= totalPages}
-                onClick={() => goToPage(page + 1)}
+                disabled={!hasNextPage}
+                onClick={nextPage}
               >
                 Next
               </Button>
@@ -33,21 +33,40 @@ export interface WebhookLog {
   eventType: string;
   attemptedAt: string;
   responseTimeMs: number;
+  createdAt: string;
+}
+
+export interface WebhookLogEvent {
+  type: "message.status" | "message.attempt";
+  messageId: string;
+  endpointId: string | null;
+  url: string;
+  eventType: string | null;
+  status: string;
+  attemptCount: number;
+  nextRetryAt?: string;
+  attempt?: {
+    attemptNumber: number;
+    statusCode: number | null;
+    durationMs: number | null;
+    errorMessage: string | null;
+  };
+  timestamp: string;
 }
 
 export interface WebhookLogsParams {
   status?: string;
   search?: string;
-  page?: number;
+  cursor?: string;
   limit?: number;
 }
 
 export interface PaginatedResponse<T> {
   data: T[];
-  total: number;
-  page: number;
   limit: number;
-  totalPages: number;
+  nextCursor: string | null;
+  total?: number;
+  totalEstimated?: boolean;
 }
 
 export interface DeliveryAttemptDetail {
@@ -16,6 +16,21 @@ export function removeStoredToken(): void {
   localStorage.removeItem(AUTH_TOKEN_KEY);
 }
 
+// authHeaders returns the headers that authenticate a request as the
+// signed-in user, scoped to the selected org.
+export function authHeaders(): Record<string, string> {
+  const headers: Record<string, string> = {};
+  const token = getStoredToken();
+  if (token) {
+    headers['Authorization'] = `Bearer ${token}`;
+  }
+  const orgId = localStorage.getItem(SELECTED_ORG_KEY);
+  if (orgId) {
+    headers['X-Org-ID'] = orgId;
+  }
+  return headers;
+}
+
 interface ApiRequestOptions {
   method: 'GET' | 'POST' | 'PUT' | 'DELETE' | 'PATCH';
   path: string;
@@ -31,19 +46,9 @@ export async function apiRequest<T>({
 }: ApiRequestOptions): Promise<T> {
   const headers: Record<string, string> = {
     'Content-Type': 'application/json',
+    ...(auth ? authHeaders() : {}),
   };
 
-  if (auth) {
-    const token = getStoredToken();
-    if (token) {
-      headers['Authorization'] = `Bearer ${token}`;
-    }
-    const orgId = localStorage.getItem(SELECTED_ORG_KEY);
-    if (orgId) {
-      headers['X-Org-ID'] = orgId;
-    }
-  }
-
   const response = await fetch(`${BASE_URL}${path}`, {
     method,
     headers,